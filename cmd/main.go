@@ -1,17 +1,35 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/actions/workflow-parser/export"
+	_ "github.com/actions/workflow-parser/ghactions"
+	_ "github.com/actions/workflow-parser/gitlab"
+	"github.com/actions/workflow-parser/model"
 	"github.com/actions/workflow-parser/parser"
 )
 
 func main() {
+	if len(os.Args) >= 2 && os.Args[1] == "convert" {
+		convert(os.Args[2:])
+		return
+	}
+	if len(os.Args) == 2 && os.Args[1] == "formats" {
+		formats()
+		return
+	}
+	if len(os.Args) == 2 && os.Args[1] == "schema" {
+		os.Stdout.Write(parser.ModelSchema())
+		return
+	}
 	if len(os.Args) < 2 {
-		fmt.Println("Usage:")
-		fmt.Println("  " + os.Args[0] + " filename.workflow...")
-		os.Exit(1)
+		usage()
 	}
 
 	for _, fn := range os.Args[1:] {
@@ -19,6 +37,15 @@ func main() {
 	}
 }
 
+func usage() {
+	fmt.Println("Usage:")
+	fmt.Println("  " + os.Args[0] + " filename.workflow|filename.workflow.json|filename.yml...")
+	fmt.Println("  " + os.Args[0] + " convert [-to format] [-workflow name]... [-set name=value]... [-o dir] [-f] filename.workflow")
+	fmt.Println("  " + os.Args[0] + " formats")
+	fmt.Println("  " + os.Args[0] + " schema")
+	os.Exit(1)
+}
+
 func parseFile(fn string) {
 	file, err := os.Open(fn)
 	if err != nil {
@@ -26,19 +53,177 @@ func parseFile(fn string) {
 	}
 	defer file.Close()
 
-	config, err := parser.Parse(file)
-
+	config, err := parseConfig(fn, file)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
 	fmt.Println(fn, "is a valid file with", plural(len(config.Actions), "action"), "and", plural(len(config.Workflows), "workflow"))
+	for _, action := range config.Actions {
+		if limits := describeLimits(action); limits != "" {
+			fmt.Printf("  action `%s': %s\n", action.Identifier, limits)
+		}
+	}
+}
+
+// parseConfig parses the file named fn in the format its extension
+// names: YAML, the JSON flavor, or a .workflow file.
+func parseConfig(fn string, file *os.File) (*model.Configuration, error) {
+	switch filepath.Ext(fn) {
+	case ".yml", ".yaml":
+		return parseYAML(file)
+	case ".json":
+		return parseJSON(file)
+	default:
+		return parser.Parse(file, parser.WithFileSystem(parser.DirFileSystem(".")))
+	}
+}
+
+// parseYAML parses a GitHub Actions workflow file, returning the same
+// errors as parser.Parse.
+func parseYAML(file *os.File) (*model.Configuration, error) {
+	result, err := parser.ParseYAML(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return result.Configuration, nil
+}
+
+// parseJSON parses a workflow file in the JSON flavor, returning the same
+// errors as parser.Parse.
+func parseJSON(file *os.File) (*model.Configuration, error) {
+	result, err := parser.ParseJSON(file, parser.WithFileSystem(parser.DirFileSystem(".")))
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return result.Configuration, nil
+}
+
+// convert converts a workflow file with one of the exporters of the
+// export package, and prints what could not be converted exactly.
+func convert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := flags.String("to", "github", "the format to convert to, one of those that the formats command lists")
+	var workflows, settings listFlag
+	flags.Var(&workflows, "workflow", "a workflow to convert, rather than all of them; can be repeated")
+	flags.Var(&settings, "set", "a setting of the format, as name=value; can be repeated")
+	jobs := flags.Bool("jobs", false, "the same as -set jobs=true")
+	runsOn := flags.String("runs-on", "", "the same as -set runs-on=runner")
+	dir := flags.String("o", ".", "the directory to write the files in")
+	force := flags.Bool("f", false, "overwrite existing files")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	opts := export.Options{Workflows: workflows, Settings: make(map[string]string)}
+	for _, setting := range settings {
+		i := strings.Index(setting, "=")
+		if i < 0 {
+			fmt.Printf("-set %s must be name=value\n", setting)
+			os.Exit(1)
+		}
+		opts.Settings[setting[:i]] = setting[i+1:]
+	}
+	if *jobs {
+		opts.Settings["jobs"] = "true"
+	}
+	if *runsOn != "" {
+		opts.Settings["runs-on"] = *runsOn
+	}
+
+	fn := flags.Arg(0)
+	file, err := os.Open(fn)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	config, err := parseConfig(fn, file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	result, err := export.Export(*to, config, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, converted := range result.Files {
+		writeFile(filepath.Join(*dir, filepath.FromSlash(converted.Name)), converted.Content, *force)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Println("note:", warning)
+	}
+}
+
+// formats prints the formats that convert can convert to, and their
+// settings.
+func formats() {
+	for _, e := range export.Exporters() {
+		fmt.Printf("%s\t%s\n", e.Name(), e.Description())
+		for _, s := range e.Settings() {
+			fmt.Printf("  -set %s=...\t%s\n", s.Name, s.Usage)
+		}
+	}
+}
+
+// listFlag is a flag that can be given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// writeFile writes a converted file, unless it already exists and force
+// is false, and exits on failure.
+func writeFile(path string, content []byte, force bool) {
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Println(path, "already exists; use -f to overwrite it")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("wrote", path)
+}
+
+// describeLimits describes the timeout and retries of an action, or
+// returns "" if it has neither.
+func describeLimits(action *model.Action) string {
+	var limits []string
+	if action.Timeout > 0 {
+		limits = append(limits, "timeout "+action.Timeout.String())
+	}
+	if action.Retries > 0 {
+		limits = append(limits, plural(action.Retries, "retry"))
+	}
+	return strings.Join(limits, ", ")
 }
 
 func plural(n int, s string) string {
 	if n == 1 {
 		return fmt.Sprintf("%d %s", n, s)
+	} else if strings.HasSuffix(s, "y") {
+		return fmt.Sprintf("%d %sies", n, strings.TrimSuffix(s, "y"))
 	} else {
 		return fmt.Sprintf("%d %ss", n, s)
 	}
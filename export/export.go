@@ -0,0 +1,191 @@
+// Package export is the registry of exporters, which convert workflows to
+// the files of other CI systems.  Exporters register themselves when
+// their package is imported, as ghactions and gitlab do, so a program
+// that imports them for their side effects can list and run every format
+// by name:
+//
+//	import (
+//		"github.com/actions/workflow-parser/export"
+//		_ "github.com/actions/workflow-parser/ghactions"
+//	)
+//
+//	result, err := export.Export("github", config, export.Options{})
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/actions/workflow-parser/model"
+)
+
+// Exporter converts workflows to the files of another CI system.
+type Exporter interface {
+	// Name is the name of the format, such as "github", which selects it
+	// in Lookup and on the command line.
+	Name() string
+
+	// Description says in a few words what the format is.
+	Description() string
+
+	// Settings are the settings that the exporter understands, which
+	// are passed in Options.Settings.
+	Settings() []Setting
+
+	// Export converts the workflows of config that opts selects.  The
+	// error is for workflows that cannot be converted at all;
+	// conversions that lose something are described by the warnings of
+	// the result.
+	Export(config *model.Configuration, opts Options) (*Result, error)
+}
+
+// Setting is a setting particular to an exporter.
+type Setting struct {
+	Name  string
+	Usage string
+}
+
+// Options are the input of an exporter, besides the configuration.
+type Options struct {
+	// Workflows are the identifiers of the workflows to export, or nil
+	// for all of them.
+	Workflows []string
+
+	// Settings are values for the settings of the exporter, by name.
+	Settings map[string]string
+}
+
+// Result is what an exporter made of a configuration.
+type Result struct {
+	Files []*File
+
+	// Warnings describe what could not be converted exactly, in the
+	// order it was found.
+	Warnings []Warning
+}
+
+// File is a file that an exporter made.
+type File struct {
+	// Name is the path of the file, with slashes, relative to the root
+	// of the repository, such as ".github/workflows/ci.yml".
+	Name     string
+	Workflow *model.Workflow
+	Content  []byte
+}
+
+// Warning describes something about a workflow or action that could not
+// be converted exactly.  Action is "" for a warning about the workflow
+// itself.
+type Warning struct {
+	Workflow string
+	Action   string
+	Message  string
+}
+
+func (w Warning) String() string {
+	if w.Action == "" {
+		return fmt.Sprintf("workflow `%s': %s", w.Workflow, w.Message)
+	}
+	return fmt.Sprintf("workflow `%s', action `%s': %s", w.Workflow, w.Action, w.Message)
+}
+
+var (
+	mu        sync.RWMutex
+	exporters = make(map[string]Exporter)
+)
+
+// Register makes an exporter available by its name.  It panics if an
+// exporter already has the name, since that is a programming error.
+func Register(e Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := e.Name()
+	if _, ok := exporters[name]; ok {
+		panic(fmt.Sprintf("export: Register called twice for %q", name))
+	}
+	exporters[name] = e
+}
+
+// Lookup returns the exporter with name, if one was registered.
+func Lookup(name string) (Exporter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := exporters[name]
+	return e, ok
+}
+
+// Exporters returns the registered exporters, sorted by name.
+func Exporters() []Exporter {
+	mu.RLock()
+	defer mu.RUnlock()
+	ret := make([]Exporter, 0, len(exporters))
+	for _, e := range exporters {
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name() < ret[j].Name() })
+	return ret
+}
+
+// Export runs the exporter with name, after checking that it has every
+// setting in opts.
+func Export(name string, config *model.Configuration, opts Options) (*Result, error) {
+	e, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown format `%s'", name)
+	}
+	known := make(map[string]bool)
+	for _, s := range e.Settings() {
+		known[s.Name] = true
+	}
+	for key := range opts.Settings {
+		if !known[key] {
+			return nil, fmt.Errorf("format `%s' has no setting `%s'", name, key)
+		}
+	}
+	return e.Export(config, opts)
+}
+
+// SelectWorkflows returns the workflows of config that opts selects, in
+// the order of config.  It returns an error for an identifier that no
+// workflow has.
+func (opts Options) SelectWorkflows(config *model.Configuration) ([]*model.Workflow, error) {
+	if opts.Workflows == nil {
+		return config.Workflows, nil
+	}
+	var missing []string
+	for _, id := range opts.Workflows {
+		if config.GetWorkflow(id) == nil {
+			missing = append(missing, "`"+id+"'")
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("there is no workflow %s", strings.Join(missing, ", "))
+	}
+	var ret []*model.Workflow
+	for _, workflow := range config.Workflows {
+		for _, id := range opts.Workflows {
+			if workflow.Identifier == id {
+				ret = append(ret, workflow)
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Bool returns the setting with name as a boolean, which is false if it
+// is not set.
+func (opts Options) Bool(name string) (bool, error) {
+	s, ok := opts.Settings[name]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("setting `%s' must be true or false, got `%s'", name, s)
+	}
+	return b, nil
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/actions/workflow-parser/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// names is an exporter that writes the identifiers of the workflows.
+type names struct{}
+
+func (names) Name() string        { return "test-names" }
+func (names) Description() string { return "the names of the workflows" }
+func (names) Settings() []Setting { return []Setting{{Name: "upper", Usage: "true for capitals"}} }
+
+func (names) Export(config *model.Configuration, opts Options) (*Result, error) {
+	workflows, err := opts.SelectWorkflows(config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := opts.Bool("upper"); err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for _, workflow := range workflows {
+		result.Files = append(result.Files, &File{Name: workflow.Identifier, Workflow: workflow})
+		result.Warnings = append(result.Warnings, Warning{Workflow: workflow.Identifier, Message: "empty"})
+	}
+	return result, nil
+}
+
+// unregister removes the exporter with name, so that a test can register
+// its own without leaking it into the other tests.
+func unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(exporters, name)
+}
+
+func TestRegistry(t *testing.T) {
+	Register(names{})
+	defer unregister("test-names")
+	e, ok := Lookup("test-names")
+	require.True(t, ok)
+	assert.Equal(t, names{}, e)
+	assert.Contains(t, Exporters(), Exporter(names{}))
+	assert.Panics(t, func() { Register(names{}) })
+
+	config := &model.Configuration{Workflows: []*model.Workflow{{Identifier: "a"}, {Identifier: "b"}}}
+	result, err := Export("test-names", config, Options{Workflows: []string{"b"}})
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, "b", result.Files[0].Name)
+	assert.Equal(t, "workflow `b': empty", result.Warnings[0].String())
+
+	_, err = Export("test-names", config, Options{Workflows: []string{"c", "d"}})
+	assert.EqualError(t, err, "there is no workflow `c', `d'")
+	_, err = Export("test-names", config, Options{Settings: map[string]string{"lower": "true"}})
+	assert.EqualError(t, err, "format `test-names' has no setting `lower'")
+	_, err = Export("test-names", config, Options{Settings: map[string]string{"upper": "maybe"}})
+	assert.EqualError(t, err, "setting `upper' must be true or false, got `maybe'")
+	_, err = Export("nothing", config, Options{})
+	assert.EqualError(t, err, "unknown format `nothing'")
+}
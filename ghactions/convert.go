@@ -0,0 +1,444 @@
+// Package ghactions converts workflows to GitHub Actions workflow files,
+// the YAML files under .github/workflows that replaced main.workflow.
+package ghactions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/actions/workflow-parser/export"
+	"github.com/actions/workflow-parser/internal/yaml"
+	"github.com/actions/workflow-parser/model"
+	"github.com/actions/workflow-parser/parser"
+)
+
+// Options change how workflows are converted.  The zero value converts
+// each workflow to a single job that runs on ubuntu-latest.
+type Options struct {
+	// Jobs makes each action a job of its own, with `needs' for the
+	// actions it needs, so that independent actions run in parallel.
+	// Jobs do not share a workspace, so files that one action writes are
+	// not seen by the actions that need it.
+	Jobs bool
+
+	// RunsOn is the runner that jobs run on.
+	RunsOn string
+}
+
+// Result is the result of converting the workflows of a configuration.
+type Result struct {
+	Files []*File
+
+	// Warnings describe what could not be converted exactly, in the
+	// order it was found.
+	Warnings []export.Warning
+}
+
+// File is a workflow converted to a GitHub Actions workflow file.
+type File struct {
+	// Name is the path of the file, such as ".github/workflows/ci.yml".
+	Name     string
+	Workflow *model.Workflow
+	Content  []byte
+}
+
+// checkout is the step that each job starts with, since actions in a
+// main.workflow always ran with the repository checked out.
+const checkout = "actions/checkout@v4"
+
+// githubEvents are the events in main.workflow that GitHub Actions can
+// also run workflows on.
+var githubEvents = map[string]bool{
+	"check_run":                   true,
+	"check_suite":                 true,
+	"create":                      true,
+	"delete":                      true,
+	"deployment":                  true,
+	"deployment_status":           true,
+	"fork":                        true,
+	"gollum":                      true,
+	"issue_comment":               true,
+	"issues":                      true,
+	"label":                       true,
+	"milestone":                   true,
+	"page_build":                  true,
+	"project":                     true,
+	"project_card":                true,
+	"project_column":              true,
+	"public":                      true,
+	"pull_request":                true,
+	"pull_request_review":         true,
+	"pull_request_review_comment": true,
+	"push":                        true,
+	"release":                     true,
+	"repository_dispatch":         true,
+	"status":                      true,
+	"watch":                       true,
+}
+
+// branchFilterEvents are the events that GitHub Actions can filter by
+// branch.
+var branchFilterEvents = map[string]bool{
+	"pull_request": true,
+	"push":         true,
+}
+
+// Convert converts each workflow in config to a GitHub Actions workflow
+// file, named after the workflow.
+//
+// The actions that a workflow resolves become the steps of a single job,
+// in an order that runs each action after those it needs, or become jobs
+// of their own (see Options.Jobs).  Each job first checks out the
+// repository.  `runs' becomes the entrypoint and arguments of the step,
+// `secrets' become environment variables set from secrets, and `if'
+// conditions are rewritten to refer to github.event.  Actions that use
+// actions/bin/filter become `if' conditions on the actions that need them,
+// where the filter can be expressed that way.
+func Convert(config *model.Configuration, opts Options) *Result {
+	if opts.RunsOn == "" {
+		opts.RunsOn = "ubuntu-latest"
+	}
+	c := &converter{config: config, opts: opts, result: &Result{}}
+	names := newNamer()
+	for _, workflow := range config.Workflows {
+		c.workflow = workflow
+		content := []byte(fmt.Sprintf("# Converted from the workflow %q.\n", workflow.Identifier))
+		content = append(content, yaml.Marshal(c.convertWorkflow())...)
+		c.result.Files = append(c.result.Files, &File{
+			Name:     ".github/workflows/" + names.name(workflow.Identifier, "workflow") + ".yml",
+			Workflow: workflow,
+			Content:  content,
+		})
+	}
+	return c.result
+}
+
+type converter struct {
+	config   *model.Configuration
+	opts     Options
+	result   *Result
+	workflow *model.Workflow
+
+	// filters maps each action that uses actions/bin/filter, and can be
+	// converted to a condition, to the condition.
+	filters map[string]string
+}
+
+func (c *converter) warnf(action *model.Action, format string, a ...interface{}) {
+	warning := export.Warning{Workflow: c.workflow.Identifier, Message: fmt.Sprintf(format, a...)}
+	if action != nil {
+		warning.Action = action.Identifier
+	}
+	c.result.Warnings = append(c.result.Warnings, warning)
+}
+
+func (c *converter) convertWorkflow() *yaml.Node {
+	root := yaml.Mapping()
+	root.Set("name", yaml.Scalar(c.workflow.Identifier))
+	root.Set("on", c.convertOn())
+	if len(c.workflow.Env) > 0 {
+		root.Set("env", yaml.StringMap(c.workflow.Env))
+	}
+
+	actions := c.config.OrderedActions(c.workflow)
+	c.filters = make(map[string]string)
+	for _, action := range actions {
+		if cond, ok := c.convertFilter(action); ok {
+			c.filters[action.Identifier] = cond
+		}
+	}
+
+	jobs := yaml.Mapping()
+	if c.opts.Jobs {
+		ids := newNamer()
+		jobIDs := make(map[string]string)
+		for _, action := range actions {
+			if _, ok := c.filters[action.Identifier]; ok {
+				continue
+			}
+			step := c.convertStep(action)
+			if step == nil {
+				continue
+			}
+			job := yaml.Mapping()
+			job.Set("name", yaml.Scalar(action.Identifier))
+			var needs []string
+			for _, need := range c.needs(action) {
+				if id, ok := jobIDs[need]; ok {
+					needs = append(needs, id)
+				}
+			}
+			if len(needs) > 0 {
+				job.Set("needs", yaml.Strings(needs))
+			}
+			moveToJob(job, step, "if")
+			job.Set("runs-on", yaml.Scalar(c.opts.RunsOn))
+			moveToJob(job, step, "timeout-minutes")
+			job.Set("steps", yaml.Sequence(checkoutStep(), step))
+			jobIDs[action.Identifier] = ids.name(action.Identifier, "job")
+			jobs.Set(jobIDs[action.Identifier], job)
+		}
+	} else {
+		steps := yaml.Sequence(checkoutStep())
+		for _, action := range actions {
+			if _, ok := c.filters[action.Identifier]; ok {
+				continue
+			}
+			if step := c.convertStep(action); step != nil {
+				steps.Append(step)
+			}
+		}
+		job := yaml.Mapping()
+		job.Set("runs-on", yaml.Scalar(c.opts.RunsOn))
+		job.Set("steps", steps)
+		jobs.Set(newNamer().name(c.workflow.Identifier, "main"), job)
+	}
+	root.Set("jobs", jobs)
+	return root
+}
+
+// convertOn converts the events of the workflow, and its types and
+// branches, to the value of `on'.  Where nothing filters the events, it
+// is just their names.
+func (c *converter) convertOn() *yaml.Node {
+	on := yaml.Mapping()
+	var names []string
+	filtered := false
+	for _, o := range c.workflow.On {
+		switch o := o.(type) {
+		case *model.OnEvent:
+			name := strings.ToLower(o.Event)
+			if !githubEvents[name] {
+				c.warnf(nil, "GitHub Actions has no `%s' event, so it was left out", name)
+				continue
+			}
+			if on.Get(name) != nil {
+				continue
+			}
+			event := yaml.Mapping()
+			et, _ := parser.LookupEventType(name)
+			if c.workflow.Types != nil && et.ActivityTypes != nil {
+				event.Set("types", yaml.Strings(c.workflow.Types))
+			}
+			if c.workflow.Branches != nil && et.Branches {
+				if branchFilterEvents[name] {
+					event.Set("branches", yaml.Strings(c.workflow.Branches))
+				} else {
+					c.warnf(nil, "GitHub Actions cannot filter `%s' events by branch, so they trigger the workflow for every branch", name)
+				}
+			}
+			filtered = filtered || len(event.Content) > 0
+			names = append(names, name)
+			on.Set(name, event)
+		case *model.OnSchedule:
+			cron := strings.TrimSuffix(strings.TrimPrefix(o.Expression, "schedule("), ")")
+			schedule := on.Get("schedule")
+			if schedule == nil {
+				schedule = yaml.Sequence()
+				on.Set("schedule", schedule)
+			}
+			entry := yaml.Mapping()
+			entry.Set("cron", yaml.Scalar(cron))
+			schedule.Append(entry)
+			filtered = true
+		default:
+			c.warnf(nil, "`%s' is not an event, so it was left out", o)
+		}
+	}
+
+	switch {
+	case len(on.Content) == 0:
+		c.warnf(nil, "none of the events could be converted, so the workflow can only be run by hand")
+		return yaml.Scalar("workflow_dispatch")
+	case filtered:
+		return on
+	case len(names) == 1:
+		return yaml.Scalar(names[0])
+	}
+	return yaml.Strings(names)
+}
+
+// needs returns the actions that action needs, with each filter that was
+// converted to a condition replaced by the actions that it needs.
+func (c *converter) needs(action *model.Action) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	var visit func(ids []string)
+	visit = func(ids []string) {
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if _, ok := c.filters[id]; ok {
+				if filter := c.config.GetAction(id); filter != nil {
+					visit(filter.Needs)
+				}
+				continue
+			}
+			ret = append(ret, id)
+		}
+	}
+	visit(action.Needs)
+	return ret
+}
+
+// condition returns the condition under which action runs: its own `if',
+// and the conditions of the filters that it needs, directly or
+// indirectly.
+func (c *converter) condition(action *model.Action) string {
+	var conds []string
+	if action.If != nil {
+		conds = append(conds, action.If.Format("github.event"))
+	}
+	seen := make(map[string]bool)
+	var visit func(ids []string)
+	visit = func(ids []string) {
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if cond, ok := c.filters[id]; ok {
+				conds = append(conds, cond)
+			}
+			if need := c.config.GetAction(id); need != nil {
+				visit(need.Needs)
+			}
+		}
+	}
+	visit(action.Needs)
+
+	if len(conds) > 1 {
+		for i, cond := range conds {
+			if strings.Contains(cond, "||") {
+				conds[i] = "(" + cond + ")"
+			}
+		}
+	}
+	return strings.Join(conds, " && ")
+}
+
+// convertStep converts an action to a step, or returns nil if it cannot
+// be converted at all.
+func (c *converter) convertStep(action *model.Action) *yaml.Node {
+	step := yaml.Mapping()
+	step.Set("name", yaml.Scalar(action.Identifier))
+	if cond := c.condition(action); cond != "" {
+		step.Set("if", yaml.Scalar(cond))
+	}
+
+	switch uses := action.Uses.(type) {
+	case *model.UsesDockerImage, *model.UsesRepository:
+		step.Set("uses", yaml.Scalar(uses.String()))
+	case *model.UsesPath:
+		step.Set("uses", yaml.Scalar(uses.String()))
+		c.warnf(action, "`%s' must have an action.yml, such as one with `runs: {using: docker, image: Dockerfile}', to be used as an action", uses)
+	default:
+		c.warnf(action, "`uses' is not valid, so the action was left out")
+		return nil
+	}
+
+	var args []string
+	with := yaml.Mapping()
+	if action.Runs != nil {
+		if runs := action.Runs.Split(); len(runs) > 0 {
+			with.Set("entrypoint", yaml.Scalar(runs[0]))
+			args = runs[1:]
+		}
+	}
+	if action.Args != nil {
+		args = append(args, action.Args.Split()...)
+	}
+	if len(args) > 0 {
+		with.Set("args", yaml.Scalar(joinArgs(args)))
+	}
+	if len(with.Content) > 0 {
+		step.Set("with", with)
+	}
+
+	env := make(map[string]string, len(action.Env)+len(action.Secrets))
+	for k, v := range action.Env {
+		env[k] = v
+	}
+	for _, secret := range action.Secrets {
+		env[secret] = "${{ secrets." + secret + " }}"
+	}
+	if len(env) > 0 {
+		step.Set("env", yaml.StringMap(env))
+	}
+
+	if action.Timeout > 0 {
+		minutes := int((action.Timeout + time.Minute - 1) / time.Minute)
+		if action.Timeout%time.Minute != 0 {
+			c.warnf(action, "GitHub Actions measures timeouts in minutes, so `timeout = %q' became %d", action.Timeout, minutes)
+		}
+		step.Set("timeout-minutes", yaml.Int(minutes))
+	}
+	if action.Retries > 0 {
+		c.warnf(action, "GitHub Actions cannot retry a step, so `retries = %d' was left out", action.Retries)
+	}
+	return step
+}
+
+// moveToJob moves key, if it is set, from step to job.
+func moveToJob(job, step *yaml.Node, key string) {
+	for i := 0; i+1 < len(step.Content); i += 2 {
+		if step.Content[i].Value == key {
+			job.Set(key, step.Content[i+1])
+			step.Content = append(step.Content[:i], step.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func checkoutStep() *yaml.Node {
+	step := yaml.Mapping()
+	step.Set("uses", yaml.Scalar(checkout))
+	return step
+}
+
+// joinArgs joins arguments into the string that `with.args' takes, in
+// which arguments are separated by spaces, and double quotes group an
+// argument that has spaces.
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// namer makes names for files and jobs from identifiers, which GitHub
+// Actions allows fewer characters in.
+type namer struct {
+	used map[string]bool
+}
+
+func newNamer() *namer {
+	return &namer{used: make(map[string]bool)}
+}
+
+var nameSeparatorRe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// name returns the identifier in lowercase, with each run of other
+// characters than letters, digits, and underscores replaced by a dash,
+// and a number added if the name was already used.  If nothing is left,
+// it uses fallback instead.
+func (n *namer) name(identifier, fallback string) string {
+	base := strings.Trim(nameSeparatorRe.ReplaceAllString(strings.ToLower(identifier), "-"), "-")
+	if base == "" || base[0] >= '0' && base[0] <= '9' {
+		base = strings.TrimSuffix(fallback+"-"+base, "-")
+	}
+	name := base
+	for i := 2; n.used[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	n.used[name] = true
+	return name
+}
@@ -0,0 +1,355 @@
+package ghactions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/actions/workflow-parser/export"
+	"github.com/actions/workflow-parser/model"
+	"github.com/actions/workflow-parser/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, src string) *model.Configuration {
+	config, err := parser.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	return config
+}
+
+func warnings(result *Result) []string {
+	var ret []string
+	for _, warning := range result.Warnings {
+		ret = append(ret, warning.String())
+	}
+	return ret
+}
+
+const pipeline = `version = 8
+
+workflow "CI / Deploy" {
+  on = ["push", "schedule(0 4 * * *)"]
+  resolves = ["deploy", "lint"]
+  branches = ["main"]
+  env = { STAGE = "prod" }
+}
+
+action "build" {
+  uses = "docker://golang:1.12"
+  runs = ["sh", "-c", "go build ./..."]
+  env = { GO111MODULE = "on" }
+  timeout = "10m"
+}
+
+action "lint" {
+  uses = "docker://golangci/golangci-lint"
+  args = "run --fast"
+}
+
+action "main only" {
+  uses = "actions/bin/filter@master"
+  needs = "build"
+  args = "branch main"
+}
+
+action "deploy" {
+  uses = "actions/aws/cli@v1"
+  needs = "main only"
+  secrets = ["AWS_KEY"]
+  if = "event.pusher.name != 'bot' || event.forced"
+}
+`
+
+func TestConvert(t *testing.T) {
+	result := Convert(parse(t, pipeline), Options{})
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, ".github/workflows/ci-deploy.yml", result.Files[0].Name)
+	assert.Equal(t, "CI / Deploy", result.Files[0].Workflow.Identifier)
+	assert.Equal(t, `# Converted from the workflow "CI / Deploy".
+name: CI / Deploy
+on:
+  push:
+    branches: [main]
+  schedule:
+    - cron: '0 4 * * *'
+env:
+  STAGE: prod
+jobs:
+  ci-deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - name: build
+        uses: docker://golang:1.12
+        with:
+          entrypoint: sh
+          args: -c "go build ./..."
+        env:
+          GO111MODULE: 'on'
+        timeout-minutes: 10
+      - name: deploy
+        if: (github.event.pusher.name != 'bot' || github.event.forced) && github.ref == 'refs/heads/main'
+        uses: actions/aws/cli@v1
+        env:
+          AWS_KEY: ${{ secrets.AWS_KEY }}
+      - name: lint
+        uses: docker://golangci/golangci-lint
+        with:
+          args: run --fast
+`, string(result.Files[0].Content))
+	assert.Empty(t, result.Warnings)
+}
+
+func TestConvertJobs(t *testing.T) {
+	result := Convert(parse(t, pipeline), Options{Jobs: true, RunsOn: "self-hosted"})
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, `# Converted from the workflow "CI / Deploy".
+name: CI / Deploy
+on:
+  push:
+    branches: [main]
+  schedule:
+    - cron: '0 4 * * *'
+env:
+  STAGE: prod
+jobs:
+  build:
+    name: build
+    runs-on: self-hosted
+    timeout-minutes: 10
+    steps:
+      - uses: actions/checkout@v4
+      - name: build
+        uses: docker://golang:1.12
+        with:
+          entrypoint: sh
+          args: -c "go build ./..."
+        env:
+          GO111MODULE: 'on'
+  deploy:
+    name: deploy
+    needs: [build]
+    if: (github.event.pusher.name != 'bot' || github.event.forced) && github.ref == 'refs/heads/main'
+    runs-on: self-hosted
+    steps:
+      - uses: actions/checkout@v4
+      - name: deploy
+        uses: actions/aws/cli@v1
+        env:
+          AWS_KEY: ${{ secrets.AWS_KEY }}
+  lint:
+    name: lint
+    runs-on: self-hosted
+    steps:
+      - uses: actions/checkout@v4
+      - name: lint
+        uses: docker://golangci/golangci-lint
+        with:
+          args: run --fast
+`, string(result.Files[0].Content))
+}
+
+func TestConvertOn(t *testing.T) {
+	cases := []struct {
+		on       string
+		expected string
+		warnings []string
+	}{
+		{`on = "push"`, "on: push\n", nil},
+		{`on = ["push", "pull_request"]`, "on: [push, pull_request]\n", nil},
+		{`on = ["pull_request", "issues"]
+  types = ["opened"]
+  branches = ["main"]`, "on:\n  pull_request:\n    types: [opened]\n    branches: [main]\n  issues:\n    types: [opened]\n", nil},
+		{`on = ["create", "push"]
+  branches = ["main"]`, "on:\n  create: {}\n  push:\n    branches: [main]\n", []string{
+			"workflow `w': GitHub Actions cannot filter `create' events by branch, so they trigger the workflow for every branch",
+		}},
+		{`on = ["member", "push"]`, "on: push\n", []string{
+			"workflow `w': GitHub Actions has no `member' event, so it was left out",
+		}},
+		{`on = "member"`, "on: workflow_dispatch\n", []string{
+			"workflow `w': GitHub Actions has no `member' event, so it was left out",
+			"workflow `w': none of the events could be converted, so the workflow can only be run by hand",
+		}},
+	}
+	for _, tc := range cases {
+		src := "version = 3\nworkflow \"w\" {\n  " + tc.on + "\n}\n"
+		result := Convert(parse(t, src), Options{})
+		require.Len(t, result.Files, 1)
+		content := string(result.Files[0].Content)
+		start := strings.Index(content, "\non")
+		end := strings.Index(content, "\njobs:")
+		assert.Equal(t, tc.expected, content[start+1:end+1], tc.on)
+		assert.Equal(t, tc.warnings, warnings(result), tc.on)
+	}
+}
+
+func TestConvertFilters(t *testing.T) {
+	cases := []struct {
+		args     string
+		expected string
+		warnings []string
+	}{
+		{"tag v1", "github.ref == 'refs/tags/v1'", nil},
+		{"not branch main", "'!(github.ref == ''refs/heads/main'')'", nil},
+		{"ref refs/pull/**", "startsWith(github.ref, 'refs/pull/')", nil},
+		{"tag v*", "startsWith(github.ref, 'refs/tags/v')", []string{
+			"workflow `w', action `f': the `*' at the end of `v*' now also matches `/'",
+		}},
+		{"action opened synchronize", "github.event.action == 'opened' || github.event.action == 'synchronize'", nil},
+		{"actor o'brien", "github.actor == 'o''brien'", nil},
+		{"branch feature/*/fix", "", []string{
+			"workflow `w', action `f': the filter `branch feature/*/fix' could not be converted to a condition, so it was kept as a step, which fails the job, rather than stopping it, when the event does not match",
+		}},
+		{"label bug", "", []string{
+			"workflow `w', action `f': the filter `label bug' could not be converted to a condition, so it was kept as a step, which fails the job, rather than stopping it, when the event does not match",
+		}},
+	}
+	for _, tc := range cases {
+		src := `workflow "w" {
+  on = "push"
+  resolves = "a"
+}
+action "f" {
+  uses = "actions/bin/filter@master"
+  args = "` + tc.args + `"
+}
+action "a" {
+  uses = "./a"
+  needs = "f"
+}
+`
+		result := Convert(parse(t, src), Options{Jobs: true})
+		require.Len(t, result.Files, 1)
+		content := string(result.Files[0].Content)
+		if tc.expected == "" {
+			assert.Contains(t, content, "uses: actions/bin/filter@master", tc.args)
+			assert.Contains(t, content, "needs: [f]", tc.args)
+		} else {
+			assert.NotContains(t, content, "actions/bin/filter", tc.args)
+			assert.Contains(t, content, "    if: "+tc.expected+"\n", tc.args)
+		}
+		expected := append(tc.warnings, "workflow `w', action `a': `./a' must have an action.yml, such as one with `runs: {using: docker, image: Dockerfile}', to be used as an action")
+		assert.Equal(t, expected, warnings(result), tc.args)
+	}
+}
+
+func TestConvertWarnings(t *testing.T) {
+	src := `version = 7
+workflow "w" {
+  on = "push"
+  resolves = "a"
+}
+action "a" {
+  uses = "docker://alpine"
+  runs = ["echo", "two words", "say \"hi\""]
+  timeout = "90s"
+  retries = 3
+}
+`
+	result := Convert(parse(t, src), Options{})
+	require.Len(t, result.Files, 1)
+	content := string(result.Files[0].Content)
+	assert.Contains(t, content, `          args: '"two words" "say \"hi\""'`+"\n")
+	assert.Contains(t, content, "        timeout-minutes: 2\n")
+	assert.Equal(t, []string{
+		"workflow `w', action `a': GitHub Actions measures timeouts in minutes, so `timeout = \"1m30s\"' became 2",
+		"workflow `w', action `a': GitHub Actions cannot retry a step, so `retries = 3' was left out",
+	}, warnings(result))
+}
+
+func TestConvertNames(t *testing.T) {
+	src := `workflow "Build" {
+  on = "push"
+  resolves = "a"
+}
+workflow "build!" {
+  on = "push"
+  resolves = "a"
+}
+workflow "2019" {
+  on = "push"
+  resolves = "a"
+}
+action "a" {
+  uses = "docker://alpine"
+}
+`
+	result := Convert(parse(t, src), Options{})
+	var names []string
+	for _, file := range result.Files {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{
+		".github/workflows/build.yml",
+		".github/workflows/build-2.yml",
+		".github/workflows/workflow-2019.yml",
+	}, names)
+	assert.Contains(t, string(result.Files[2].Content), "jobs:\n  main-2019:\n")
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	config := parse(t, `version = 8
+
+workflow "CI" {
+  on = "push"
+  resolves = ["deploy", "lint"]
+  env = { STAGE = "prod" }
+}
+
+action "build" {
+  uses = "docker://golang:1.12"
+  runs = ["go", "build"]
+  args = "./..."
+  timeout = "10m"
+}
+
+action "lint" {
+  uses = "docker://golangci/golangci-lint"
+  args = "run --fast"
+}
+
+action "deploy" {
+  uses = "actions/aws/cli@v1"
+  needs = "build"
+  secrets = ["AWS_KEY"]
+  env = { REGION = "us-east-1" }
+  if = "event.ref == 'refs/heads/main'"
+}
+`)
+	for _, opts := range []Options{{}, {Jobs: true}} {
+		result := Convert(config, opts)
+		require.Len(t, result.Files, 1)
+		parsed, err := parser.ParseYAML(strings.NewReader(string(result.Files[0].Content)))
+		require.NoError(t, err)
+		assert.Empty(t, parsed.Errors)
+
+		workflow := parsed.Configuration.GetWorkflow("CI")
+		require.NotNil(t, workflow)
+		assert.Equal(t, config.Workflows[0].On, workflow.On)
+		assert.Equal(t, config.Workflows[0].Env, workflow.Env)
+		for _, action := range config.Actions {
+			got := parsed.Configuration.GetAction(action.Identifier)
+			if assert.NotNil(t, got, action.Identifier) {
+				assert.Equal(t, action.Uses, got.Uses, action.Identifier)
+				assert.Equal(t, action.Env, got.Env, action.Identifier)
+				assert.Equal(t, action.Secrets, got.Secrets, action.Identifier)
+				assert.Equal(t, action.Timeout, got.Timeout, action.Identifier)
+				assert.Equal(t, action.If, got.If, action.Identifier)
+			}
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	result, err := export.Export("github", parse(t, pipeline), export.Options{
+		Workflows: []string{"CI / Deploy"},
+		Settings:  map[string]string{"jobs": "true", "runs-on": "self-hosted"},
+	})
+	require.NoError(t, err)
+	expected := Convert(parse(t, pipeline), Options{Jobs: true, RunsOn: "self-hosted"})
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, expected.Files[0].Name, result.Files[0].Name)
+	assert.Equal(t, expected.Files[0].Content, result.Files[0].Content)
+	assert.Len(t, result.Warnings, len(expected.Warnings))
+}
@@ -0,0 +1,44 @@
+package ghactions
+
+import (
+	"github.com/actions/workflow-parser/export"
+	"github.com/actions/workflow-parser/model"
+)
+
+func init() {
+	export.Register(exporter{})
+}
+
+// exporter makes Convert available as the "github" format of the export
+// package.
+type exporter struct{}
+
+func (exporter) Name() string        { return "github" }
+func (exporter) Description() string { return "GitHub Actions workflow files, under .github/workflows" }
+
+func (exporter) Settings() []export.Setting {
+	return []export.Setting{
+		{Name: "jobs", Usage: "true to make each action a job of its own"},
+		{Name: "runs-on", Usage: "the runner that jobs run on, ubuntu-latest by default"},
+	}
+}
+
+func (exporter) Export(config *model.Configuration, opts export.Options) (*export.Result, error) {
+	workflows, err := opts.SelectWorkflows(config)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := opts.Bool("jobs")
+	if err != nil {
+		return nil, err
+	}
+	selected := *config
+	selected.Workflows = workflows
+
+	result := Convert(&selected, Options{Jobs: jobs, RunsOn: opts.Settings["runs-on"]})
+	ret := &export.Result{Warnings: result.Warnings}
+	for _, file := range result.Files {
+		ret.Files = append(ret.Files, &export.File{Name: file.Name, Workflow: file.Workflow, Content: file.Content})
+	}
+	return ret, nil
+}
@@ -0,0 +1,102 @@
+package ghactions
+
+import (
+	"strings"
+
+	"github.com/actions/workflow-parser/model"
+)
+
+// isFilter returns true if action uses actions/bin/filter, which stopped
+// a workflow, without failing it, unless the event matched its arguments.
+func isFilter(action *model.Action) bool {
+	uses, ok := action.Uses.(*model.UsesRepository)
+	return ok && uses.Repository == "actions/bin" && uses.Path == "filter"
+}
+
+// convertFilter returns the condition, in the syntax of GitHub Actions
+// expressions, under which a filter lets the workflow go on.  It returns
+// false if action is not a filter, or if its arguments cannot be
+// expressed as a condition; then the filter is kept as a step, and a
+// warning says that it fails the job rather than stopping it.
+//
+// The arguments are one of `branch PATTERN', `tag PATTERN', `ref
+// PATTERN', `action TYPE...', or `actor LOGIN...', optionally after
+// `not'.  A pattern can only have a `*' at the end.
+func (c *converter) convertFilter(action *model.Action) (string, bool) {
+	if !isFilter(action) {
+		return "", false
+	}
+	var all []string
+	if action.Args != nil {
+		all = action.Args.Split()
+	}
+	args := all
+	negate := len(args) > 0 && args[0] == "not"
+	if negate {
+		args = args[1:]
+	}
+
+	cond, ok := "", len(args) >= 2
+	if ok {
+		switch args[0] {
+		case "branch":
+			cond, ok = c.refCondition(action, "refs/heads/", args[1:])
+		case "tag":
+			cond, ok = c.refCondition(action, "refs/tags/", args[1:])
+		case "ref":
+			cond, ok = c.refCondition(action, "", args[1:])
+		case "action":
+			cond = anyEqual("github.event.action", args[1:])
+		case "actor":
+			cond = anyEqual("github.actor", args[1:])
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		c.warnf(action, "the filter `%s' could not be converted to a condition, so it was kept as a step, which fails the job, rather than stopping it, when the event does not match", strings.Join(all, " "))
+		return "", false
+	}
+	if negate {
+		cond = "!(" + cond + ")"
+	}
+	return cond, true
+}
+
+// refCondition returns a condition that github.ref matches the pattern,
+// after prefix.
+func (c *converter) refCondition(action *model.Action, prefix string, args []string) (string, bool) {
+	if len(args) != 1 {
+		return "", false
+	}
+	pattern := args[0]
+	if !strings.HasSuffix(pattern, "*") {
+		if strings.ContainsAny(pattern, "*?[") {
+			return "", false
+		}
+		return "github.ref == " + quote(prefix+pattern), true
+	}
+
+	stem := strings.TrimRight(pattern, "*")
+	if strings.ContainsAny(stem, "*?[") {
+		return "", false
+	}
+	if !strings.HasSuffix(pattern, "**") {
+		c.warnf(action, "the `*' at the end of `%s' now also matches `/'", pattern)
+	}
+	return "startsWith(github.ref, " + quote(prefix+stem) + ")", true
+}
+
+// anyEqual returns a condition that expr equals one of values.
+func anyEqual(expr string, values []string) string {
+	conds := make([]string, len(values))
+	for i, value := range values {
+		conds[i] = expr + " == " + quote(value)
+	}
+	return strings.Join(conds, " || ")
+}
+
+// quote returns s as a string in an expression.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
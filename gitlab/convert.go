@@ -0,0 +1,316 @@
+// Package gitlab converts workflows to GitLab CI pipelines, the
+// .gitlab-ci.yml file at the root of a repository.
+package gitlab
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actions/workflow-parser/export"
+	"github.com/actions/workflow-parser/internal/yaml"
+	"github.com/actions/workflow-parser/model"
+)
+
+// Filename is the name of the file that GitLab CI reads its pipeline from.
+const Filename = ".gitlab-ci.yml"
+
+// maxRetries is the most times that GitLab CI retries a job.
+const maxRetries = 2
+
+// Result is a workflow converted to a GitLab CI pipeline.
+type Result struct {
+	Content []byte
+
+	// Warnings describe what could not be converted exactly, in the
+	// order it was found.
+	Warnings []export.Warning
+}
+
+// BuildError is returned for a workflow with actions that use a
+// repository or a path.  Their images are built from a Dockerfile before
+// they run, which a GitLab CI job cannot do by itself.
+type BuildError struct {
+	Workflow string
+	Actions  []*model.Action
+}
+
+func (e *BuildError) Error() string {
+	uses := make([]string, len(e.Actions))
+	for i, action := range e.Actions {
+		uses[i] = fmt.Sprintf("`%s' uses `%s'", action.Identifier, action.Uses)
+	}
+	return fmt.Sprintf("workflow `%s' cannot be converted to GitLab CI, which can only run existing images, because %s, which must be built from a Dockerfile; use `docker://' with an image built from it instead",
+		e.Workflow, strings.Join(uses, ", "))
+}
+
+// reservedNames are the top-level keys of .gitlab-ci.yml that are not
+// jobs.
+var reservedNames = map[string]bool{
+	"after_script":  true,
+	"before_script": true,
+	"cache":         true,
+	"default":       true,
+	"image":         true,
+	"include":       true,
+	"pages":         true,
+	"services":      true,
+	"stages":        true,
+	"types":         true,
+	"variables":     true,
+	"workflow":      true,
+}
+
+// Convert converts workflow, and the actions it resolves, to a GitLab CI
+// pipeline.
+//
+// Each action becomes a job that runs in the image that the action uses.
+// Jobs are put in stages by how deep they are in the dependencies, so
+// that each job runs after all the jobs it needs: the actions that need
+// nothing are in the first stage, and the others are in the stage after
+// the last of the actions they need.  `runs' and `args' become the
+// job's script, which GitLab CI runs in a shell, so the image's
+// entrypoint is not used.  `env' becomes `variables'.  Secrets are the
+// CI/CD variables of the project, which GitLab CI passes to every job.
+//
+// Actions that use a repository or a path cannot be converted, and make
+// Convert return a *BuildError.  Actions without a valid `uses' make it
+// return an error too.
+func Convert(config *model.Configuration, workflow *model.Workflow) (*Result, error) {
+	c := &converter{config: config, workflow: workflow, result: &Result{}}
+	actions := c.config.OrderedActions(c.workflow)
+
+	var unbuilt []*model.Action
+	var invalid []string
+	for _, action := range actions {
+		switch action.Uses.(type) {
+		case *model.UsesDockerImage:
+		case *model.UsesRepository, *model.UsesPath:
+			unbuilt = append(unbuilt, action)
+		default:
+			invalid = append(invalid, action.Identifier)
+		}
+	}
+	if len(invalid) == 1 {
+		return nil, fmt.Errorf("workflow `%s' cannot be converted to GitLab CI, because the action %s has no valid `uses'", workflow.Identifier, quoteAll(invalid))
+	} else if len(invalid) > 1 {
+		return nil, fmt.Errorf("workflow `%s' cannot be converted to GitLab CI, because the actions %s have no valid `uses'", workflow.Identifier, quoteAll(invalid))
+	}
+	if len(unbuilt) > 0 {
+		return nil, &BuildError{Workflow: workflow.Identifier, Actions: unbuilt}
+	}
+
+	content := []byte(fmt.Sprintf("# Converted from the workflow %q.\n", workflow.Identifier))
+	c.result.Content = append(content, yaml.Marshal(c.convertWorkflow(actions))...)
+	return c.result, nil
+}
+
+type converter struct {
+	config   *model.Configuration
+	workflow *model.Workflow
+	result   *Result
+}
+
+func (c *converter) warnf(action *model.Action, format string, a ...interface{}) {
+	warning := export.Warning{Workflow: c.workflow.Identifier, Message: fmt.Sprintf(format, a...)}
+	if action != nil {
+		warning.Action = action.Identifier
+	}
+	c.result.Warnings = append(c.result.Warnings, warning)
+}
+
+func (c *converter) convertWorkflow(actions []*model.Action) *yaml.Node {
+	c.checkOn()
+
+	levels := c.levels(actions)
+	depth := 0
+	for _, level := range levels {
+		if level+1 > depth {
+			depth = level + 1
+		}
+	}
+	stages := make([]string, depth)
+	for i := range stages {
+		stages[i] = stageName(i)
+	}
+
+	root := yaml.Mapping()
+	root.Set("stages", yaml.Strings(stages))
+	if len(c.workflow.Env) > 0 {
+		root.Set("variables", yaml.StringMap(c.workflow.Env))
+	}
+	var secrets []string
+	seen := make(map[string]bool)
+	for _, action := range actions {
+		name := jobName(action.Identifier)
+		if root.Get(name) != nil {
+			c.warnf(action, "the job for the action was named `%s', which another job already has, so it was left out", name)
+			continue
+		}
+		root.Set(name, c.convertJob(action, stageName(levels[action.Identifier])))
+		for _, secret := range action.Secrets {
+			if !seen[secret] {
+				seen[secret] = true
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	if len(secrets) > 0 {
+		sort.Strings(secrets)
+		if len(secrets) == 1 {
+			c.warnf(nil, "the secret %s must be a CI/CD variable of the project", quoteAll(secrets))
+		} else {
+			c.warnf(nil, "the secrets %s must be CI/CD variables of the project", quoteAll(secrets))
+		}
+	}
+	return root
+}
+
+// checkOn warns about the events of the workflow that GitLab CI does not
+// run pipelines on the same way.  GitLab CI runs a pipeline on every
+// push, and on the schedules set up in the project, so only those can be
+// converted, and neither is written in .gitlab-ci.yml.
+func (c *converter) checkOn() {
+	for _, o := range c.workflow.On {
+		switch o := o.(type) {
+		case *model.OnEvent:
+			if strings.ToLower(o.Event) != "push" {
+				c.warnf(nil, "GitLab CI has no `%s' event, so the pipeline runs on each push instead", o.Event)
+			}
+		case *model.OnSchedule:
+			c.warnf(nil, "schedules are set up in the project rather than in %s, so `%s' must be added there", Filename, o.Expression)
+		default:
+			c.warnf(nil, "`%s' is not an event, so it was left out", o)
+		}
+	}
+	if c.workflow.Branches != nil {
+		c.warnf(nil, "the pipeline runs for every branch, not only %s", quoteAll(c.workflow.Branches))
+	}
+	if c.workflow.Types != nil {
+		c.warnf(nil, "GitLab CI has no activity types, so `types' was left out")
+	}
+}
+
+// levels returns the level of each action, which is 0 for actions that
+// need nothing, and otherwise one more than the highest level of the
+// actions it needs.  actions must be in the order that order returns.
+func (c *converter) levels(actions []*model.Action) map[string]int {
+	levels := make(map[string]int, len(actions))
+	for _, action := range actions {
+		level := 0
+		for _, need := range action.Needs {
+			if l, ok := levels[need]; ok && l+1 > level {
+				level = l + 1
+			}
+		}
+		levels[action.Identifier] = level
+	}
+	return levels
+}
+
+// convertJob converts an action to a job in stage.
+func (c *converter) convertJob(action *model.Action, stage string) *yaml.Node {
+	job := yaml.Mapping()
+	job.Set("stage", yaml.Scalar(stage))
+
+	// The entrypoint is cleared so that GitLab CI can run the script in a
+	// shell.
+	image := yaml.Mapping()
+	image.Set("name", yaml.Scalar(action.Uses.(*model.UsesDockerImage).Image))
+	image.Set("entrypoint", yaml.Strings([]string{""}))
+	job.Set("image", image)
+
+	var command []string
+	if action.Runs != nil {
+		command = action.Runs.Split()
+	} else if action.Args != nil {
+		c.warnf(action, "the job runs `args' as a command, rather than as the arguments of the image's entrypoint, since it has no `runs'")
+	}
+	if action.Args != nil {
+		command = append(command, action.Args.Split()...)
+	}
+	if len(command) == 0 {
+		c.warnf(action, "the job runs nothing, since GitLab CI does not run the image's entrypoint, and the action has neither `runs' nor `args'")
+		command = []string{"true"}
+	}
+	job.Set("script", yaml.Strings([]string{shellJoin(command)}))
+
+	if len(action.Env) > 0 {
+		job.Set("variables", yaml.StringMap(action.Env))
+	}
+	if action.If != nil {
+		c.warnf(action, "GitLab CI cannot refer to the event, so the job always runs, not only if `%s'", action.If)
+	}
+	if action.Timeout > 0 {
+		job.Set("timeout", yaml.Scalar(formatDuration(action.Timeout)))
+	}
+	if action.Retries > 0 {
+		retries := action.Retries
+		if retries > maxRetries {
+			c.warnf(action, "GitLab CI retries a job at most %d times, so `retries = %d' became %d", maxRetries, retries, maxRetries)
+			retries = maxRetries
+		}
+		job.Set("retry", yaml.Int(retries))
+	}
+	return job
+}
+
+// stageName returns the name of the stage for level.
+func stageName(level int) string {
+	return fmt.Sprintf("stage-%d", level+1)
+}
+
+// jobName returns the name of the job for an action.  Names that GitLab
+// CI would not read as jobs, because they are other top-level keys or
+// start with `.', which hides a job, get " job" added.
+func jobName(identifier string) string {
+	if reservedNames[identifier] || strings.HasPrefix(identifier, ".") {
+		return identifier + " job"
+	}
+	return identifier
+}
+
+// formatDuration returns d in the format of `timeout', such as "1h 30m".
+func formatDuration(d time.Duration) string {
+	var parts []string
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{{time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}} {
+		if n := d / unit.size; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, unit.name))
+			d -= n * unit.size
+		}
+	}
+	if len(parts) == 0 {
+		return "1s"
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellJoin joins arguments into a command for a POSIX shell, quoting
+// those that have characters the shell would treat specially.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.IndexFunc(arg, isShellSpecial) >= 0 {
+			arg = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+func isShellSpecial(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+}
+
+// quoteAll returns values quoted and separated by commas.
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "`" + value + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,212 @@
+package gitlab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/actions/workflow-parser/export"
+	"github.com/actions/workflow-parser/model"
+	"github.com/actions/workflow-parser/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, src string) *model.Configuration {
+	config, err := parser.Parse(strings.NewReader(src))
+	require.NoError(t, err)
+	return config
+}
+
+func warnings(result *Result) []string {
+	var ret []string
+	for _, warning := range result.Warnings {
+		ret = append(ret, warning.String())
+	}
+	return ret
+}
+
+const pipeline = `version = 8
+
+workflow "CI" {
+  on = ["push", "schedule(0 4 * * *)"]
+  resolves = ["deploy", "lint"]
+  env = { STAGE = "prod" }
+}
+
+action "build" {
+  uses = "docker://golang:1.12"
+  runs = ["sh", "-c", "go build ./..."]
+  env = { GO111MODULE = "on" }
+  timeout = "90m"
+}
+
+action "test" {
+  uses = "docker://golang:1.12"
+  needs = "build"
+  runs = "go test ./..."
+  retries = 1
+}
+
+action "lint" {
+  uses = "docker://golangci/golangci-lint"
+  args = "golangci-lint run"
+}
+
+action "deploy" {
+  uses = "docker://amazon/aws-cli"
+  needs = ["build", "test"]
+  args = "s3 sync dist s3://bucket"
+  secrets = ["AWS_KEY"]
+}
+`
+
+func TestConvert(t *testing.T) {
+	config := parse(t, pipeline)
+	result, err := Convert(config, config.Workflows[0])
+	require.NoError(t, err)
+	assert.Equal(t, `# Converted from the workflow "CI".
+stages: [stage-1, stage-2, stage-3]
+variables:
+  STAGE: prod
+build:
+  stage: stage-1
+  image:
+    name: golang:1.12
+    entrypoint: ['']
+  script: [sh -c 'go build ./...']
+  variables:
+    GO111MODULE: 'on'
+  timeout: '1h 30m'
+test:
+  stage: stage-2
+  image:
+    name: golang:1.12
+    entrypoint: ['']
+  script: [go test ./...]
+  retry: 1
+deploy:
+  stage: stage-3
+  image:
+    name: amazon/aws-cli
+    entrypoint: ['']
+  script: ['s3 sync dist s3://bucket']
+lint:
+  stage: stage-1
+  image:
+    name: golangci/golangci-lint
+    entrypoint: ['']
+  script: [golangci-lint run]
+`, string(result.Content))
+	assert.Equal(t, []string{
+		"workflow `CI': schedules are set up in the project rather than in .gitlab-ci.yml, so `schedule(0 4 * * *)' must be added there",
+		"workflow `CI', action `deploy': the job runs `args' as a command, rather than as the arguments of the image's entrypoint, since it has no `runs'",
+		"workflow `CI', action `lint': the job runs `args' as a command, rather than as the arguments of the image's entrypoint, since it has no `runs'",
+		"workflow `CI': the secret `AWS_KEY' must be a CI/CD variable of the project",
+	}, warnings(result))
+}
+
+func TestConvertWarnings(t *testing.T) {
+	config := parse(t, `version = 8
+
+workflow "PRs" {
+  on = "pull_request"
+  resolves = ["image", "nothing"]
+  types = ["opened"]
+}
+
+action "image" {
+  uses = "docker://alpine"
+  runs = ["echo", "it's done"]
+  retries = 5
+  if = "event.action == 'opened'"
+}
+
+action "nothing" {
+  uses = "docker://alpine"
+}
+`)
+	result, err := Convert(config, config.Workflows[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(result.Content), `image job:
+  stage: stage-1
+  image:
+    name: alpine
+    entrypoint: ['']
+  script: [echo 'it'\''s done']
+  retry: 2
+nothing:
+  stage: stage-1
+  image:
+    name: alpine
+    entrypoint: ['']
+  script: ['true']
+`)
+	assert.Equal(t, []string{
+		"workflow `PRs': GitLab CI has no `pull_request' event, so the pipeline runs on each push instead",
+		"workflow `PRs': GitLab CI has no activity types, so `types' was left out",
+		"workflow `PRs', action `image': GitLab CI cannot refer to the event, so the job always runs, not only if `event.action == 'opened''",
+		"workflow `PRs', action `image': GitLab CI retries a job at most 2 times, so `retries = 5' became 2",
+		"workflow `PRs', action `nothing': the job runs nothing, since GitLab CI does not run the image's entrypoint, and the action has neither `runs' nor `args'",
+	}, warnings(result))
+}
+
+func TestConvertBuildError(t *testing.T) {
+	config := parse(t, `workflow "CI" {
+  on = "push"
+  resolves = ["deploy"]
+}
+
+action "build" {
+  uses = "./build"
+}
+
+action "deploy" {
+  uses = "actions/aws/cli@v1"
+  needs = "build"
+}
+`)
+	result, err := Convert(config, config.Workflows[0])
+	assert.Nil(t, result)
+	require.IsType(t, &BuildError{}, err)
+	assert.Len(t, err.(*BuildError).Actions, 2)
+	assert.Equal(t, "workflow `CI' cannot be converted to GitLab CI, which can only run existing images, because `build' uses `./build', `deploy' uses `actions/aws/cli@v1', which must be built from a Dockerfile; use `docker://' with an image built from it instead", err.Error())
+}
+
+func TestConvertInvalidUses(t *testing.T) {
+	config := &model.Configuration{
+		Actions: []*model.Action{
+			{Identifier: "a", Uses: &model.UsesInvalid{Raw: "nope"}},
+			{Identifier: "b", Needs: []string{"a"}},
+		},
+		Workflows: []*model.Workflow{
+			{Identifier: "CI", On: []model.On{&model.OnEvent{Event: "push"}}, Resolves: []string{"b"}},
+		},
+	}
+	result, err := Convert(config, config.Workflows[0])
+	assert.Nil(t, result)
+	require.Error(t, err)
+	assert.Equal(t, "workflow `CI' cannot be converted to GitLab CI, because the actions `a', `b' have no valid `uses'", err.Error())
+
+	config.Workflows[0].Resolves = []string{"a"}
+	_, err = Convert(config, config.Workflows[0])
+	require.Error(t, err)
+	assert.Equal(t, "workflow `CI' cannot be converted to GitLab CI, because the action `a' has no valid `uses'", err.Error())
+}
+
+func TestExport(t *testing.T) {
+	config := parse(t, pipeline+`
+workflow "other" {
+  on = "push"
+  resolves = "lint"
+}
+`)
+	_, err := export.Export("gitlab", config, export.Options{})
+	assert.EqualError(t, err, "GitLab CI has one pipeline, so one workflow must be chosen, out of 2")
+
+	result, err := export.Export("gitlab", config, export.Options{Workflows: []string{"other"}})
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, ".gitlab-ci.yml", result.Files[0].Name)
+	assert.Equal(t, "other", result.Files[0].Workflow.Identifier)
+	assert.Equal(t, []export.Warning{{Workflow: "other", Action: "lint", Message: "the job runs `args' as a command, rather than as the arguments of the image's entrypoint, since it has no `runs'"}}, result.Warnings)
+}
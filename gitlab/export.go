@@ -0,0 +1,41 @@
+package gitlab
+
+import (
+	"fmt"
+
+	"github.com/actions/workflow-parser/export"
+	"github.com/actions/workflow-parser/model"
+)
+
+func init() {
+	export.Register(exporter{})
+}
+
+// exporter makes Convert available as the "gitlab" format of the export
+// package.  A repository has one GitLab CI pipeline, so it exports one
+// workflow: the one selected, or the only one.
+type exporter struct{}
+
+func (exporter) Name() string               { return "gitlab" }
+func (exporter) Description() string        { return "a GitLab CI pipeline, " + Filename }
+func (exporter) Settings() []export.Setting { return nil }
+
+func (exporter) Export(config *model.Configuration, opts export.Options) (*export.Result, error) {
+	workflows, err := opts.SelectWorkflows(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(workflows) != 1 {
+		return nil, fmt.Errorf("GitLab CI has one pipeline, so one workflow must be chosen, out of %d", len(workflows))
+	}
+
+	result, err := Convert(config, workflows[0])
+	if err != nil {
+		return nil, err
+	}
+	ret := &export.Result{
+		Files:    []*export.File{{Name: Filename, Workflow: workflows[0], Content: result.Content}},
+		Warnings: result.Warnings,
+	}
+	return ret, nil
+}
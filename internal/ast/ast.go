@@ -0,0 +1,116 @@
+// Package ast declares the syntax tree of a .workflow file: blocks and
+// attributes, as object items, with their keys and values, and the
+// comments of the file.  The parser package builds it with its own parser
+// or from the tree of the HCL parser, and works only on this one.
+package ast
+
+import "github.com/actions/workflow-parser/internal/token"
+
+// Node is a node of the syntax tree.
+type Node interface {
+	node()
+}
+
+func (*File) node()        {}
+func (*ObjectList) node()  {}
+func (*ObjectItem) node()  {}
+func (*ObjectKey) node()   {}
+func (*ObjectType) node()  {}
+func (*LiteralType) node() {}
+func (*ListType) node()    {}
+
+// File is a parsed file.  Node is an *ObjectList of the items at the top
+// level.
+type File struct {
+	Node     Node
+	Comments []*CommentGroup
+}
+
+// ObjectList is a list of object items: the top level of a file, or the
+// body of an object.
+type ObjectList struct {
+	Items []*ObjectItem
+}
+
+// Add appends an item to the list.
+func (o *ObjectList) Add(item *ObjectItem) {
+	o.Items = append(o.Items, item)
+}
+
+// ObjectItem is a block, as in `action "a" { ... }', or an attribute, as
+// in `uses = "./a"'.  Assign is the position of the `=', and is not valid
+// for a block.
+type ObjectItem struct {
+	Keys   []*ObjectKey
+	Assign token.Pos
+	Val    Node
+}
+
+// ObjectKey is one of the keys of an object item: an IDENT or a STRING.
+type ObjectKey struct {
+	Token token.Token
+}
+
+// LiteralType is a string, heredoc, number, or boolean.
+type LiteralType struct {
+	Token token.Token
+}
+
+// ListType is a list of values between `[' and `]'.
+type ListType struct {
+	Lbrack token.Pos
+	Rbrack token.Pos
+	List   []Node
+}
+
+// Add appends a value to the list.
+func (l *ListType) Add(node Node) {
+	l.List = append(l.List, node)
+}
+
+// ObjectType is an object between `{' and `}'.
+type ObjectType struct {
+	Lbrace token.Pos
+	Rbrace token.Pos
+	List   *ObjectList
+}
+
+// Comment is a single `#', `//', or `/* */' comment.
+type Comment struct {
+	Start token.Pos
+	Text  string
+}
+
+// CommentGroup is a run of comments on consecutive lines.
+type CommentGroup struct {
+	List []*Comment
+}
+
+// Walk calls fn for node and, if fn returns true, for each of its
+// children in turn, depth first.
+func Walk(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *File:
+		Walk(n.Node, fn)
+	case *ObjectList:
+		for _, item := range n.Items {
+			Walk(item, fn)
+		}
+	case *ObjectItem:
+		for _, key := range n.Keys {
+			Walk(key, fn)
+		}
+		Walk(n.Val, fn)
+	case *ObjectType:
+		if n.List != nil {
+			Walk(n.List, fn)
+		}
+	case *ListType:
+		for _, elem := range n.List {
+			Walk(elem, fn)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package token defines the tokens of the syntax tree that the parser
+// works on, and how the values of literal tokens are decoded.  The
+// tokens and their values are those of HCL, which .workflow files are
+// written in, so that the tree can come from either the parser package's
+// own parser or from HCL's.
+package token
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Type is the type of a token.
+type Type int
+
+const (
+	ILLEGAL Type = iota
+	EOF
+	COMMENT
+
+	IDENT   // action
+	NUMBER  // 12345
+	FLOAT   // 123.45
+	BOOL    // true, false
+	STRING  // "abc"
+	HEREDOC // <<EOF ... EOF
+
+	LBRACK // [
+	LBRACE // {
+	COMMA  // ,
+	ASSIGN // =
+	RBRACK // ]
+	RBRACE // }
+)
+
+var typeNames = [...]string{
+	ILLEGAL: "ILLEGAL",
+	EOF:     "EOF",
+	COMMENT: "COMMENT",
+	IDENT:   "IDENT",
+	NUMBER:  "NUMBER",
+	FLOAT:   "FLOAT",
+	BOOL:    "BOOL",
+	STRING:  "STRING",
+	HEREDOC: "HEREDOC",
+	LBRACK:  "LBRACK",
+	LBRACE:  "LBRACE",
+	COMMA:   "COMMA",
+	ASSIGN:  "ASSIGN",
+	RBRACK:  "RBRACK",
+	RBRACE:  "RBRACE",
+}
+
+func (t Type) String() string {
+	if t >= 0 && int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// IsOperator returns true for punctuation: brackets, braces, `,', and
+// `='.
+func (t Type) IsOperator() bool {
+	return LBRACK <= t && t <= RBRACE
+}
+
+// Pos is a position in a file.  Offset is in bytes, from 0; Line and
+// Column count from 1, with Column counting characters.
+type Pos struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid returns true if the position is set.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// Token is a token of a .workflow file, with its text as written.
+type Token struct {
+	Type Type
+	Pos  Pos
+	Text string
+
+	// JSON is true for a STRING from a file in the JSON flavor, whose
+	// text is quoted as in Go rather than as in HCL.
+	JSON bool
+}
+
+// Value returns the value of a literal token: a string for an IDENT,
+// STRING, or HEREDOC, an int64 for a NUMBER, a float64 for a FLOAT, and a
+// bool for a BOOL.  It panics for other tokens, and for text that is not
+// valid for the type, which the parsers do not make.
+func (t Token) Value() interface{} {
+	switch t.Type {
+	case IDENT:
+		return t.Text
+	case STRING:
+		unquote := Unquote
+		if t.JSON {
+			unquote = strconv.Unquote
+		}
+		v, err := unquote(t.Text)
+		if err != nil {
+			panic(fmt.Sprintf("token: unquote %s: %s", t.Text, err))
+		}
+		return v
+	case HEREDOC:
+		return heredocValue(t.Text)
+	case NUMBER:
+		v, err := strconv.ParseInt(t.Text, 0, 64)
+		if err != nil {
+			panic(fmt.Sprintf("token: %s", err))
+		}
+		return v
+	case FLOAT:
+		v, err := strconv.ParseFloat(t.Text, 64)
+		if err != nil {
+			panic(fmt.Sprintf("token: %s", err))
+		}
+		return v
+	case BOOL:
+		return t.Text == "true"
+	default:
+		panic(fmt.Sprintf("token: no value for %s", t.Type))
+	}
+}
+
+// ErrSyntax is returned by Unquote for text that is not a valid string.
+var ErrSyntax = errors.New("invalid syntax")
+
+// Unquote returns the value of a double-quoted HCL string.  Escape
+// sequences are those of Go, except that `${...}' sequences are kept as
+// they are, escapes and quotes included, up to the matching `}'.  A line
+// break is only allowed inside `${...}' or, as in HCL, in a string with a
+// `{' and no escapes, quotes, or `$'.
+func Unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", ErrSyntax
+	}
+	s = s[1 : len(s)-1]
+	if !strings.ContainsAny(s, "${") && strings.Contains(s, "\n") {
+		return "", ErrSyntax
+	}
+	if !strings.ContainsAny(s, `\"$`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "${") {
+			end := interpolationEnd(s)
+			if end < 0 {
+				return "", ErrSyntax
+			}
+			b.WriteString(s[:end])
+			s = s[end:]
+			continue
+		}
+		if s[0] == '\n' {
+			return "", ErrSyntax
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			return "", err
+		}
+		if r < utf8.RuneSelf || !multibyte {
+			b.WriteByte(byte(r))
+		} else {
+			b.WriteRune(r)
+		}
+		s = tail
+	}
+	return b.String(), nil
+}
+
+// interpolationEnd returns the offset just after the `}' that closes the
+// `${' at the start of s, or -1 if there is none.
+func interpolationEnd(s string) int {
+	depth := 0
+	for i := 1; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError {
+			return -1
+		}
+		i += size
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// heredocValue returns the lines of a heredoc between the line with the
+// opening anchor and the line with the closing one, each with its line
+// break.  For a `<<-' heredoc, the indentation of the closing anchor is
+// removed from every line, if they all have it.
+func heredocValue(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) < 2 {
+		return ""
+	}
+	closing := lines[len(lines)-1]
+	body := lines[1 : len(lines)-1]
+
+	if strings.HasPrefix(text, "<<-") {
+		indent := closing[:len(closing)-len(strings.TrimLeft(closing, " \t"))]
+		all := true
+		for _, line := range body {
+			all = all && strings.HasPrefix(line, indent)
+		}
+		if all {
+			for i, line := range body {
+				body[i] = strings.TrimPrefix(line, indent)
+			}
+		}
+	}
+	return strings.Join(body, "")
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnquote(t *testing.T) {
+	cases := []struct {
+		in  string
+		out string
+		ok  bool
+	}{
+		{`""`, "", true},
+		{`"abc"`, "abc", true},
+		{`"a\tb\"c\\"`, "a\tb\"c\\", true},
+		{`"é\x41\101"`, "éAA", true},
+		{`"${a.b["c"]} \n"`, `${a.b["c"]} ` + "\n", true},
+		{`"${ {} }"`, "${ {} }", true},
+		{"\"${a\nb}\"", "${a\nb}", true},
+		{"\"{\n}\"", "{\n}", true},
+		{`"${a"`, "", false},
+		{"\"a\nb\"", "", false},
+		{`"\q"`, "", false},
+		{`"a"b"`, "", false},
+		{`'a'`, "", false},
+		{`"`, "", false},
+	}
+
+	for _, tc := range cases {
+		out, err := Unquote(tc.in)
+		if tc.ok {
+			assert.NoError(t, err, tc.in)
+			assert.Equal(t, tc.out, out, tc.in)
+		} else {
+			assert.Error(t, err, tc.in)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	cases := []struct {
+		tok   Token
+		value interface{}
+	}{
+		{Token{Type: IDENT, Text: "uses"}, "uses"},
+		{Token{Type: STRING, Text: `"a\nb"`}, "a\nb"},
+		{Token{Type: STRING, Text: `"${a}é"`, JSON: true}, "${a}é"},
+		{Token{Type: NUMBER, Text: "0x1f"}, int64(31)},
+		{Token{Type: FLOAT, Text: "1.5e2"}, 150.0},
+		{Token{Type: BOOL, Text: "true"}, true},
+		{Token{Type: BOOL, Text: "false"}, false},
+		{Token{Type: HEREDOC, Text: "<<EOF\n  a\n b\nEOF\n"}, "  a\n b\n"},
+		{Token{Type: HEREDOC, Text: "<<-EOF\n  a\n   b\n  EOF\n"}, "a\n b\n"},
+		{Token{Type: HEREDOC, Text: "<<-EOF\n  a\n b\n  EOF\n"}, "  a\n b\n"},
+		{Token{Type: HEREDOC, Text: "<<EOF\nEOF\n"}, ""},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.value, tc.tok.Value(), tc.tok.Text)
+	}
+}
@@ -0,0 +1,775 @@
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError is a problem that stops Parse from reading a document.
+type SyntaxError struct {
+	Line, Column int
+	Message      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Parse reads a single YAML document.  It reads block and flow
+// collections, plain, quoted, literal, and folded scalars, and comments,
+// which is what CI configuration files are written with.  Anchors,
+// aliases, tags, complex keys, and multiple documents are reported as
+// errors, as are duplicate keys.
+//
+// Each node has the position where it starts.  Plain scalars are Raw, so
+// that numbers, booleans, and null can be told apart from quoted strings.
+// An empty document is a null scalar.
+func Parse(src []byte) (n *Node, err error) {
+	text := strings.TrimPrefix(string(src), "\ufeff")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			n, err = nil, se
+		}
+	}()
+
+	d := &decoder{lines: lines, row: -1}
+	if d.nextLine() && d.isMarker("---") {
+		d.col += 3
+	}
+	d.started = true
+	if d.eof() {
+		return &Node{Kind: ScalarNode, Raw: true, Line: 1, Column: 1}, nil
+	}
+	n = d.value(-1, true)
+	if !d.eof() {
+		d.fail("unexpected content after the document")
+	}
+	return n, nil
+}
+
+// IsNull returns true if n is a plain scalar that YAML reads as null.
+func (n *Node) IsNull() bool {
+	switch n.Value {
+	case "", "~", "null", "Null", "NULL":
+		return n.Kind == ScalarNode && n.Raw
+	}
+	return false
+}
+
+// decoder reads a document line by line.  Apart from inside a scalar or
+// a flow collection, it is always at the first character of a line, after
+// the indentation, or at the end of the document.
+type decoder struct {
+	lines    []string
+	row, col int
+
+	// started is true once the `---' that may start the document has
+	// been skipped.
+	started bool
+}
+
+// fail stops Parse with an error at the current position.
+func (d *decoder) fail(format string, a ...interface{}) {
+	line, column := d.pos()
+	panic(&SyntaxError{Line: line, Column: column, Message: fmt.Sprintf(format, a...)})
+}
+
+// pos returns the line and column of the current position, counting
+// from 1.
+func (d *decoder) pos() (int, int) {
+	if d.eof() {
+		return len(d.lines), 1
+	}
+	return d.row + 1, utf8.RuneCountInString(d.lines[d.row][:d.col]) + 1
+}
+
+// newNode returns a node of kind at the current position.
+func (d *decoder) newNode(kind Kind) *Node {
+	line, column := d.pos()
+	return &Node{Kind: kind, Line: line, Column: column}
+}
+
+func (d *decoder) eof() bool {
+	return d.row >= len(d.lines)
+}
+
+// rest returns the current line from the current position.
+func (d *decoder) rest() string {
+	return d.lines[d.row][d.col:]
+}
+
+// skipSpace skips spaces and tabs in the current line.
+func (d *decoder) skipSpace() {
+	line := d.lines[d.row]
+	for d.col < len(line) && (line[d.col] == ' ' || line[d.col] == '\t') {
+		d.col++
+	}
+}
+
+// atEOL returns true if the rest of the current line, after spaces, is
+// empty or a comment.
+func (d *decoder) atEOL() bool {
+	rest := strings.TrimLeft(d.rest(), " \t")
+	return rest == "" || rest[0] == '#'
+}
+
+// endLine checks that nothing but a comment follows on the current line,
+// and moves to the next line with content.
+func (d *decoder) endLine() {
+	d.skipSpace()
+	if !d.atEOL() {
+		d.fail("unexpected %q", d.rest())
+	}
+	d.nextLine()
+}
+
+// nextLine moves to the first character of the next line with content,
+// and returns false at the end of the document.
+func (d *decoder) nextLine() bool {
+	for d.row++; d.row < len(d.lines); d.row++ {
+		line := d.lines[d.row]
+		d.col = 0
+		if isBlank(line) {
+			continue
+		}
+		for line[d.col] == ' ' {
+			d.col++
+		}
+		if line[d.col] == '\t' {
+			d.fail("tabs are not allowed in indentation")
+		}
+		if d.started && d.isMarker("---") {
+			d.fail("multiple documents are not supported")
+		}
+		if d.isMarker("...") {
+			for d.row++; d.row < len(d.lines) && isBlank(d.lines[d.row]); d.row++ {
+			}
+			if d.row < len(d.lines) {
+				d.col = 0
+				d.fail("multiple documents are not supported")
+			}
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// isBlank returns true if line is empty or a comment.
+func isBlank(line string) bool {
+	line = strings.TrimLeft(line, " \t")
+	return line == "" || line[0] == '#'
+}
+
+// isMarker returns true if the current line is the document marker `---'
+// or `...'.
+func (d *decoder) isMarker(marker string) bool {
+	line := d.lines[d.row]
+	return d.col == 0 && strings.HasPrefix(line, marker) && (len(line) == 3 || line[3] == ' ' || line[3] == '\t')
+}
+
+// atDash returns true if the current position is at a block sequence
+// entry.
+func (d *decoder) atDash() bool {
+	rest := d.rest()
+	return strings.HasPrefix(rest, "-") && (len(rest) == 1 || rest[1] == ' ' || rest[1] == '\t')
+}
+
+// value reads the node after a key or a dash, or at the top of the
+// document.  parent is the indentation of the collection it is in, or -1
+// at the top.  compact is true if a block collection may start on the
+// current line, as after a dash.
+func (d *decoder) value(parent int, compact bool) *Node {
+	d.skipSpace()
+	if d.atEOL() {
+		n := d.newNode(ScalarNode)
+		n.Raw = true
+		// Only the value of a key may be a sequence at the same
+		// indentation as the key.
+		if !d.nextLine() || d.col < parent || d.col == parent && (compact || !d.atDash()) {
+			return n
+		}
+		return d.value(parent, true)
+	}
+
+	d.checkIndicator()
+	switch {
+	case d.atDash():
+		if !compact {
+			d.fail("block sequence entries are not allowed here")
+		}
+		return d.sequence(d.col)
+	case keyEnd(d.rest()) >= 0:
+		if !compact {
+			d.fail("mapping values are not allowed here")
+		}
+		return d.mapping(d.col)
+	}
+
+	var n *Node
+	switch d.rest()[0] {
+	case '|', '>':
+		return d.blockScalar(parent)
+	case '[', '{':
+		n = d.flow()
+	case '"':
+		n = d.newNode(ScalarNode)
+		n.Value = d.doubleQuoted()
+	case '\'':
+		n = d.newNode(ScalarNode)
+		n.Value = d.singleQuoted()
+	default:
+		return d.plain(parent)
+	}
+	d.endLine()
+	return n
+}
+
+// checkIndicator reports the constructs that Parse does not support, and
+// characters that cannot start a node.
+func (d *decoder) checkIndicator() {
+	rest := d.rest()
+	switch rest[0] {
+	case '&':
+		d.fail("anchors are not supported")
+	case '*':
+		d.fail("aliases are not supported")
+	case '!':
+		d.fail("tags are not supported")
+	case '%', '@', '`', ',', ']', '}':
+		d.fail("unexpected %q", rest[:1])
+	case '?':
+		if len(rest) == 1 || rest[1] == ' ' || rest[1] == '\t' {
+			d.fail("complex mapping keys are not supported")
+		}
+	}
+}
+
+// sequence reads a block sequence whose dashes are at indent.
+func (d *decoder) sequence(indent int) *Node {
+	n := d.newNode(SequenceNode)
+	for {
+		d.col++
+		n.Append(d.value(indent, true))
+		if d.eof() || d.col < indent {
+			return n
+		}
+		if d.col > indent {
+			d.fail("bad indentation of a sequence entry")
+		}
+		if !d.atDash() {
+			return n
+		}
+	}
+}
+
+// mapping reads a block mapping whose keys are at indent.
+func (d *decoder) mapping(indent int) *Node {
+	n := d.newNode(MappingNode)
+	keys := make(map[string]*Node)
+	for {
+		row, col := d.row, d.col
+		key := d.key()
+		if prev, ok := keys[key.Value]; ok {
+			d.row, d.col = row, col
+			d.fail("duplicate key %q, first defined on line %d", key.Value, prev.Line)
+		}
+		keys[key.Value] = key
+		n.Content = append(n.Content, key, d.value(indent, false))
+		if d.eof() || d.col < indent {
+			return n
+		}
+		if d.col > indent {
+			d.fail("bad indentation of a mapping entry")
+		}
+	}
+}
+
+// key reads a key of a block mapping and the colon after it.
+func (d *decoder) key() *Node {
+	rest := d.rest()
+	end := keyEnd(rest)
+	if end < 0 || d.atDash() {
+		d.fail("expected a mapping key, got %q", rest)
+	}
+	d.checkIndicator()
+	start := d.col
+	n := d.newNode(ScalarNode)
+	switch rest[0] {
+	case '"':
+		n.Value = d.doubleQuoted()
+	case '\'':
+		n.Value = d.singleQuoted()
+	default:
+		n.Value = strings.TrimRight(rest[:end], " \t")
+		n.Raw = true
+	}
+	d.col = start + end + 1
+	return n
+}
+
+// keyEnd returns the offset of the colon after a key at the start of s,
+// or -1 if s does not start with a key.  Only plain and quoted keys on a
+// single line are recognized.
+func keyEnd(s string) int {
+	i := 0
+	switch {
+	case s == "" || strings.ContainsAny(s[:1], "[{|>#"):
+		return -1
+	case s[0] == '"' || s[0] == '\'':
+		i = quoteEnd(s)
+		if i < 0 {
+			return -1
+		}
+		for i++; i < len(s) && (s[i] == ' ' || s[i] == '\t'); i++ {
+		}
+		if i < len(s) && s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t') {
+			return i
+		}
+		return -1
+	}
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t'):
+			return -1
+		case s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t'):
+			return i
+		}
+	}
+	return -1
+}
+
+// quoteEnd returns the offset of the quote that ends the quoted string at
+// the start of s, or -1 if it does not end on the same line.
+func quoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[0] == '"' && s[i] == '\\':
+			i++
+		case s[0] == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == s[0]:
+			return i
+		}
+	}
+	return -1
+}
+
+// plain reads a plain scalar, which may go on over the following lines
+// that are indented more than parent.
+func (d *decoder) plain(parent int) *Node {
+	n := d.newNode(ScalarNode)
+	n.Raw = true
+	value, comment := plainLine(d.rest())
+	for !comment {
+		row, blanks := d.row+1, 0
+		for ; row < len(d.lines) && strings.TrimLeft(d.lines[row], " \t") == ""; row++ {
+			blanks++
+		}
+		if row == len(d.lines) {
+			break
+		}
+		line := strings.TrimLeft(d.lines[row], " ")
+		if len(d.lines[row])-len(line) <= parent || line[0] == '#' || strings.HasPrefix(d.lines[row], "---") || strings.HasPrefix(d.lines[row], "...") {
+			break
+		}
+		d.row, d.col = row, len(d.lines[row])-len(line)
+		if keyEnd(line) >= 0 {
+			d.fail("mapping values are not allowed here")
+		}
+		var text string
+		text, comment = plainLine(line)
+		if blanks == 0 {
+			value += " " + text
+		} else {
+			value += strings.Repeat("\n", blanks) + text
+		}
+	}
+	n.Value = value
+	d.nextLine()
+	return n
+}
+
+// plainLine returns the part of a line of a plain scalar before any
+// comment, and whether there was a comment.
+func plainLine(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimRight(s[:i], " \t"), true
+		}
+	}
+	return strings.TrimRight(s, " \t"), false
+}
+
+// doubleQuoted reads a double-quoted scalar, which may go on over several
+// lines, and returns its value.
+func (d *decoder) doubleQuoted() string {
+	var b strings.Builder
+	startRow, startCol := d.row, d.col
+	d.col++
+	for {
+		line := d.lines[d.row]
+		// keep is the length of b that trailing spaces are not trimmed
+		// from, since they were escaped.
+		keep, escapedBreak := 0, false
+		for d.col < len(line) {
+			c := line[d.col]
+			switch {
+			case c == '"':
+				d.col++
+				return b.String()
+			case c == '\\' && d.col+1 == len(line):
+				escapedBreak = true
+				d.col++
+			case c == '\\':
+				d.escape(&b)
+				keep = b.Len()
+			default:
+				b.WriteByte(c)
+				d.col++
+			}
+		}
+		if !escapedBreak {
+			s := b.String()
+			trimmed := strings.TrimRight(s[keep:], " \t")
+			b.Reset()
+			b.WriteString(s[:keep])
+			b.WriteString(trimmed)
+		}
+		d.foldQuoted(&b, startRow, startCol, escapedBreak)
+	}
+}
+
+// singleQuoted reads a single-quoted scalar, which may go on over several
+// lines, and returns its value.
+func (d *decoder) singleQuoted() string {
+	var b strings.Builder
+	startRow, startCol := d.row, d.col
+	d.col++
+	for {
+		line := d.lines[d.row]
+		for d.col < len(line) {
+			c := line[d.col]
+			switch {
+			case c == '\'' && d.col+1 < len(line) && line[d.col+1] == '\'':
+				b.WriteByte('\'')
+				d.col += 2
+			case c == '\'':
+				d.col++
+				return b.String()
+			default:
+				b.WriteByte(c)
+				d.col++
+			}
+		}
+		s := strings.TrimRight(b.String(), " \t")
+		b.Reset()
+		b.WriteString(s)
+		d.foldQuoted(&b, startRow, startCol, false)
+	}
+}
+
+// foldQuoted moves to the next line of a quoted scalar that started at
+// startRow and startCol, and adds the line break to b: a space, or a
+// newline for each empty line.  An escaped line break adds nothing.
+func (d *decoder) foldQuoted(b *strings.Builder, startRow, startCol int, escapedBreak bool) {
+	blanks := 0
+	for d.row++; d.row < len(d.lines) && strings.TrimLeft(d.lines[d.row], " \t") == ""; d.row++ {
+		blanks++
+	}
+	if d.row == len(d.lines) {
+		d.row, d.col = startRow, startCol
+		d.fail("unterminated quoted string")
+	}
+	switch {
+	case blanks > 0:
+		b.WriteString(strings.Repeat("\n", blanks))
+	case !escapedBreak:
+		b.WriteByte(' ')
+	}
+	d.col = 0
+	d.skipSpace()
+}
+
+// escapes maps the single-character escapes of double-quoted scalars to
+// their values.
+var escapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// escape reads an escape sequence in a double-quoted scalar into b.
+func (d *decoder) escape(b *strings.Builder) {
+	line := d.lines[d.row]
+	c := line[d.col+1]
+	if s, ok := escapes[c]; ok {
+		b.WriteString(s)
+		d.col += 2
+		return
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if digits == 0 || d.col+2+digits > len(line) {
+		d.fail("invalid escape %q", line[d.col:d.col+2])
+	}
+	r, err := strconv.ParseUint(line[d.col+2:d.col+2+digits], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		d.fail("invalid escape %q", line[d.col:d.col+2+digits])
+	}
+	b.WriteRune(rune(r))
+	d.col += 2 + digits
+}
+
+// blockScalar reads a literal (`|') or folded (`>') scalar, whose lines
+// are indented more than parent.
+func (d *decoder) blockScalar(parent int) *Node {
+	n := d.newNode(ScalarNode)
+	header := d.rest()
+	folded := header[0] == '>'
+	chomp, explicit := byte(0), 0
+	i := 1
+	for ; i < len(header) && i <= 2; i++ {
+		c := header[i]
+		if (c == '-' || c == '+') && chomp == 0 {
+			chomp = c
+		} else if c >= '1' && c <= '9' && explicit == 0 {
+			explicit = int(c - '0')
+		} else {
+			break
+		}
+	}
+	d.col += i
+	d.skipSpace()
+	if !d.atEOL() {
+		d.fail("invalid block scalar header")
+	}
+
+	indent := -1
+	if explicit > 0 {
+		indent = explicit
+		if parent > 0 {
+			indent += parent
+		}
+	}
+	var lines []string
+	row := d.row + 1
+	for ; row < len(d.lines); row++ {
+		line := d.lines[row]
+		text := strings.TrimLeft(line, " ")
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if indent < 0 {
+			indent = len(line) - len(text)
+			if indent <= parent {
+				break
+			}
+		}
+		if len(line)-len(text) < indent {
+			break
+		}
+		lines = append(lines, line[indent:])
+	}
+
+	// Empty lines at the end are subject to chomping.
+	body := len(lines)
+	for body > 0 && strings.TrimRight(lines[body-1], " ") == "" {
+		body--
+	}
+	trailing := len(lines) - body
+	if folded {
+		n.Value = foldLines(lines[:body])
+	} else {
+		n.Value = strings.Join(lines[:body], "\n")
+	}
+	switch {
+	case chomp == '+':
+		n.Value += strings.Repeat("\n", trailing)
+		if body > 0 {
+			n.Value += "\n"
+		}
+	case chomp == 0 && body > 0:
+		n.Value += "\n"
+	}
+
+	d.row = row - 1
+	d.nextLine()
+	return n
+}
+
+// foldLines joins the lines of a folded scalar.  A line break between two
+// lines of text becomes a space, unless either is indented more than the
+// others; empty lines are kept as newlines.
+func foldLines(lines []string) string {
+	var b strings.Builder
+	blanks, prevMore := 0, false
+	for i, line := range lines {
+		if line == "" {
+			blanks++
+			continue
+		}
+		more := line[0] == ' ' || line[0] == '\t'
+		switch {
+		case i == blanks:
+			b.WriteString(strings.Repeat("\n", blanks))
+		case blanks == 0 && !more && !prevMore:
+			b.WriteByte(' ')
+		case !more && !prevMore:
+			b.WriteString(strings.Repeat("\n", blanks))
+		default:
+			b.WriteString(strings.Repeat("\n", blanks+1))
+		}
+		b.WriteString(line)
+		blanks, prevMore = 0, more
+	}
+	return b.String()
+}
+
+// flow reads a flow sequence or mapping, which may go on over several
+// lines.
+func (d *decoder) flow() *Node {
+	open := d.rest()[0]
+	closing := byte(']')
+	n := d.newNode(SequenceNode)
+	n.Flow = true
+	if open == '{' {
+		closing = '}'
+		n.Kind = MappingNode
+	}
+	keys := make(map[string]*Node)
+	d.col++
+	for {
+		d.flowSkip()
+		if d.rest()[0] == closing {
+			d.col++
+			return n
+		}
+		row, col := d.row, d.col
+		item := d.flowNode()
+		d.flowSkip()
+		if n.Kind == SequenceNode {
+			if d.rest()[0] == ':' {
+				d.fail("mappings in flow sequences are not supported")
+			}
+			n.Append(item)
+		} else {
+			if item.Kind != ScalarNode {
+				d.row, d.col = row, col
+				d.fail("only scalars are supported as mapping keys")
+			}
+			if prev, ok := keys[item.Value]; ok {
+				d.row, d.col = row, col
+				d.fail("duplicate key %q, first defined on line %d", item.Value, prev.Line)
+			}
+			keys[item.Value] = item
+			value := d.newNode(ScalarNode)
+			value.Raw = true
+			if d.rest()[0] == ':' {
+				d.col++
+				d.flowSkip()
+				if c := d.rest()[0]; c != ',' && c != '}' {
+					value = d.flowNode()
+					d.flowSkip()
+				}
+			}
+			n.Content = append(n.Content, item, value)
+		}
+		switch d.rest()[0] {
+		case ',':
+			d.col++
+		case closing:
+		default:
+			d.fail("expected ',' or '%c', got %q", closing, d.rest())
+		}
+	}
+}
+
+// flowSkip skips spaces, comments, and line breaks in a flow collection.
+func (d *decoder) flowSkip() {
+	for {
+		d.skipSpace()
+		if !d.atEOL() {
+			return
+		}
+		if d.row+1 == len(d.lines) {
+			d.col = len(d.lines[d.row])
+			d.fail("unterminated flow collection")
+		}
+		d.row++
+		d.col = 0
+	}
+}
+
+// flowNode reads a node in a flow collection.
+func (d *decoder) flowNode() *Node {
+	d.checkIndicator()
+	n := d.newNode(ScalarNode)
+	rest := d.rest()
+	switch rest[0] {
+	case '[', '{':
+		return d.flow()
+	case '"':
+		n.Value = d.doubleQuoted()
+		return n
+	case '\'':
+		n.Value = d.singleQuoted()
+		return n
+	}
+
+	// A plain scalar in a flow collection ends at a flow indicator, or at
+	// a colon followed by a space or a flow indicator.  It may go on over
+	// several lines.
+	n.Raw = true
+	for {
+		i := flowPlainEnd(rest)
+		n.Value += strings.TrimRight(rest[:i], " \t")
+		d.col += i
+		if i < len(rest) {
+			return n
+		}
+		row, blanks := d.row+1, 0
+		for ; row < len(d.lines) && strings.TrimLeft(d.lines[row], " \t") == ""; row++ {
+			blanks++
+		}
+		if row == len(d.lines) {
+			return n
+		}
+		rest = strings.TrimLeft(d.lines[row], " \t")
+		if rest[0] == '#' || flowPlainEnd(rest) == 0 {
+			return n
+		}
+		d.row, d.col = row, len(d.lines[row])-len(rest)
+		if blanks == 0 {
+			n.Value += " "
+		} else {
+			n.Value += strings.Repeat("\n", blanks)
+		}
+	}
+}
+
+// flowPlainEnd returns the length of the part of s that belongs to a plain
+// scalar in a flow collection.
+func flowPlainEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if strings.IndexByte(",[]{}", c) >= 0 ||
+			c == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t') ||
+			c == ':' && (i+1 == len(s) || strings.IndexByte(" \t,[]{}", s[i+1]) >= 0) {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,140 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	n, err := Parse([]byte(`---
+# A workflow.
+name: CI
+on: [push, "pull_request"]
+env: {A: "1", B: 2}
+jobs:
+  build:
+    steps:
+    - uses: actions/checkout@v4
+    -   name: Test
+        run: |
+          make
+          make test
+        with:
+          args: >-
+            --fast
+            --quiet
+
+    - - nested
+      - list
+    empty:
+    list:
+    - at the same indentation
+  plain: a plain
+    scalar # with a comment
+...
+`))
+	require.NoError(t, err)
+	assert.Equal(t, `name: CI
+on: [push, pull_request]
+env: {A: '1', B: 2}
+jobs:
+  build:
+    steps:
+      - uses: actions/checkout@v4
+      - name: Test
+        run: |
+          make
+          make test
+        with:
+          args: --fast --quiet
+      -
+        - nested
+        - list
+    empty:
+    list:
+      - at the same indentation
+  plain: a plain scalar
+`, string(Marshal(n)))
+}
+
+func TestParsePositions(t *testing.T) {
+	n, err := Parse([]byte("a:\n  - é: x\n    c: [d,\n      e]\n"))
+	require.NoError(t, err)
+	item := n.Get("a").Content[0]
+	assert.Equal(t, []int{2, 5}, []int{item.Line, item.Column})
+	assert.Equal(t, []int{2, 8}, []int{item.Get("é").Line, item.Get("é").Column})
+	e := item.Get("c").Content[1]
+	assert.Equal(t, []int{4, 7}, []int{e.Line, e.Column})
+}
+
+func TestParseScalars(t *testing.T) {
+	cases := []struct {
+		src      string
+		expected string
+		null     bool
+	}{
+		{"", "", true},
+		{"~", "~", true},
+		{"null", "null", true},
+		{"''", "", false},
+		{"'null'", "null", false},
+		{"docker://alpine:3.9", "docker://alpine:3.9", false},
+		{"a:b", "a:b", false},
+		{"a#b", "a#b", false},
+		{"${{ secrets.TOKEN }}", "${{ secrets.TOKEN }}", false},
+		{"'it''s'", "it's", false},
+		{`"tab\there \x41\u00e9\U0001F600 \" \\"`, "tab\there Aé😀 \" \\", false},
+		{"\"one\n  two\n\n  three\"", "one two\nthree", false},
+		{"\"joined \\\n  line\"", "joined line", false},
+		{"'one\n  two'", "one two", false},
+		{"|\n  one\n  two\n\n", "one\ntwo\n", false},
+		{"|-\n  one\n", "one", false},
+		{"|+\n  one\n\n", "one\n\n\n", false},
+		{"|2\n    indented\n  base\n", "  indented\nbase\n", false},
+		{">\n  one\n  two\n\n  three\n    more\n  four\n", "one two\nthree\n  more\nfour\n", false},
+	}
+	for _, c := range cases {
+		n, err := Parse([]byte("v: " + c.src + "\nnext: x\n"))
+		if assert.NoError(t, err, c.src) {
+			v := n.Get("v")
+			assert.Equal(t, c.expected, v.Value, c.src)
+			assert.Equal(t, c.null, v.IsNull(), c.src)
+			assert.Equal(t, "x", n.Get("next").Value, c.src)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		src      string
+		expected string
+	}{
+		{"a: b: c\n", "line 1, column 4: mapping values are not allowed here"},
+		{"a: - b\n", "line 1, column 4: block sequence entries are not allowed here"},
+		{"a:\n  b: 1\n c: 2\n", "line 3, column 2: bad indentation of a mapping entry"},
+		{"- a\nb: c\n", "line 2, column 1: unexpected content after the document"},
+		{"a: 1\n- b\n", "line 2, column 1: expected a mapping key, got \"- b\""},
+		{"a: 1\na: 2\n", "line 2, column 1: duplicate key \"a\", first defined on line 1"},
+		{"a: {b: 1, b: 2}\n", "line 1, column 11: duplicate key \"b\", first defined on line 1"},
+		{"a: \"b\n", "line 1, column 4: unterminated quoted string"},
+		{"a: [b, c\n", "line 2, column 1: unterminated flow collection"},
+		{"a: [b: c]\n", "line 1, column 6: mappings in flow sequences are not supported"},
+		{"a: \"\\q\"\n", "line 1, column 5: invalid escape \"\\\\q\""},
+		{"a: 'b' c\n", "line 1, column 8: unexpected \"c\""},
+		{"a: &x 1\n", "line 1, column 4: anchors are not supported"},
+		{"a: *x\n", "line 1, column 4: aliases are not supported"},
+		{"a: !!str 1\n", "line 1, column 4: tags are not supported"},
+		{"? a\n: b\n", "line 1, column 1: complex mapping keys are not supported"},
+		{"a:\n\t- b\n", "line 2, column 1: tabs are not allowed in indentation"},
+		{"a: 1\n---\nb: 2\n", "line 2, column 1: multiple documents are not supported"},
+		{"a: |x\n  b\n", "line 1, column 5: invalid block scalar header"},
+	}
+	for _, c := range cases {
+		_, err := Parse([]byte(c.src))
+		if assert.Error(t, err, c.src) {
+			assert.Equal(t, c.expected, err.Error(), c.src)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package yaml
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Marshal returns the YAML for n, in block style, with two spaces of
+// indentation for each level.  Strings are written plainly where YAML
+// reads them back as the same string, and quoted otherwise.
+func Marshal(n *Node) []byte {
+	var b strings.Builder
+	switch {
+	case n.Kind == ScalarNode || len(n.Content) == 0 || n.Flow:
+		b.WriteString(inline(n, false))
+		b.WriteByte('\n')
+	case n.Kind == MappingNode:
+		writeMapping(&b, n, 0)
+	default:
+		writeSequence(&b, n, 0)
+	}
+	return []byte(b.String())
+}
+
+// writeMapping writes the entries of a non-empty mapping, each starting at
+// indent.
+func writeMapping(b *strings.Builder, n *Node, indent int) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if i > 0 {
+			b.WriteString(strings.Repeat(" ", indent))
+		}
+		b.WriteString(scalar(n.Content[i].Value, true, false))
+		b.WriteByte(':')
+		writeValue(b, n.Content[i+1], indent)
+	}
+}
+
+// writeSequence writes the items of a non-empty block sequence, each
+// starting at indent.
+func writeSequence(b *strings.Builder, n *Node, indent int) {
+	for i, item := range n.Content {
+		if i > 0 {
+			b.WriteString(strings.Repeat(" ", indent))
+		}
+		b.WriteByte('-')
+		switch {
+		case item.Kind == MappingNode && len(item.Content) > 0:
+			// The first entry goes on the same line as the dash.
+			b.WriteByte(' ')
+			writeMapping(b, item, indent+2)
+		default:
+			writeValue(b, item, indent)
+		}
+	}
+}
+
+// writeValue writes a value after a key or dash at indent, including the
+// separating space or newline, and the final newline.
+func writeValue(b *strings.Builder, n *Node, indent int) {
+	if n.Kind != ScalarNode && len(n.Content) > 0 && !n.Flow {
+		b.WriteByte('\n')
+		b.WriteString(strings.Repeat(" ", indent+2))
+		if n.Kind == MappingNode {
+			writeMapping(b, n, indent+2)
+		} else {
+			writeSequence(b, n, indent+2)
+		}
+		return
+	}
+	if n.Kind == ScalarNode && !n.Raw && isLiteral(n.Value) {
+		writeLiteral(b, n.Value, indent+2)
+		return
+	}
+	// A null that Parse read is written as nothing at all.
+	if s := inline(n, false); s != "" {
+		b.WriteByte(' ')
+		b.WriteString(s)
+	}
+	b.WriteByte('\n')
+}
+
+// inline returns a scalar, an empty collection, or a flow sequence, all of
+// which fit on one line.  inFlow is true inside a flow sequence, where
+// more characters need quotes.
+func inline(n *Node, inFlow bool) string {
+	switch {
+	case n.Kind == ScalarNode && n.Raw:
+		return n.Value
+	case n.Kind == ScalarNode:
+		return scalar(n.Value, false, inFlow)
+	case n.Kind == MappingNode && len(n.Content) == 0:
+		return "{}"
+	case n.Kind == MappingNode:
+		parts := make([]string, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			parts = append(parts, scalar(n.Content[i].Value, true, true)+": "+inline(n.Content[i+1], true))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	parts := make([]string, len(n.Content))
+	for i, item := range n.Content {
+		parts[i] = inline(item, true)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// isLiteral returns true if s is best written as a literal block scalar,
+// `|' followed by its lines.  That is the case for multi-line strings
+// that have no characters that need escapes, and whose first line does
+// not start with a space, which would be taken for indentation.
+func isLiteral(s string) bool {
+	if !strings.Contains(strings.TrimRight(s, "\n"), "\n") || strings.HasPrefix(strings.TrimLeft(s, "\n"), " ") {
+		return false
+	}
+	for _, r := range s {
+		if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLiteral writes s as a literal block scalar, with its lines at
+// indent.
+func writeLiteral(b *strings.Builder, s string, indent int) {
+	body := strings.TrimRight(s, "\n")
+	trailing := len(s) - len(body)
+	switch trailing {
+	case 0:
+		b.WriteString(" |-\n")
+	case 1:
+		b.WriteString(" |\n")
+	default:
+		b.WriteString(" |+\n")
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" {
+			b.WriteString(strings.Repeat(" ", indent))
+			b.WriteString(line)
+		}
+		b.WriteByte('\n')
+	}
+	for i := 1; i < trailing; i++ {
+		b.WriteByte('\n')
+	}
+}
+
+var (
+	// numberRe matches strings that YAML might read as numbers.
+	numberRe = regexp.MustCompile(`\A[-+]?(\.?[0-9]|\.(inf|Inf|INF|nan|NaN|NAN)\z)`)
+
+	// reservedWords are read as booleans or null by YAML 1.1 or 1.2.
+	reservedWords = map[string]bool{
+		"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+		"y": true, "n": true, "null": true, "~": true,
+	}
+)
+
+// scalar returns s as a plain, single-quoted, or double-quoted string.
+// Keys are never read as booleans, so words like `on' are left plain.
+func scalar(s string, isKey, inFlow bool) string {
+	switch {
+	case isPlain(s, isKey, inFlow):
+		return s
+	case isPrintable(s):
+		return "'" + strings.Replace(s, "'", "''", -1) + "'"
+	}
+	return doubleQuoted(s)
+}
+
+// isPlain returns true if s can be written without quotes.
+func isPlain(s string, isKey, inFlow bool) bool {
+	if s == "" || s != strings.TrimSpace(s) || !isPrintable(s) {
+		return false
+	}
+	// `-', `?', and `:' only start something else when a space follows.
+	if strings.ContainsAny(s[:1], ",[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:") && (len(s) == 1 || s[1] == ' ' || inFlow && s[0] != '-') {
+		return false
+	}
+	if strings.HasPrefix(s, "---") || strings.HasPrefix(s, "...") {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	// YAML 1.1 readers also end a plain string in a flow sequence at `:'
+	// and `?'.
+	if inFlow && strings.ContainsAny(s, ",[]{}:?") {
+		return false
+	}
+	if numberRe.MatchString(s) {
+		return false
+	}
+	return isKey || !reservedWords[strings.ToLower(s)]
+}
+
+// isPrintable returns true if s has no characters that need escapes.
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// doubleQuoted returns s in double quotes, with escapes for characters
+// that are not printable.
+func doubleQuoted(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case !unicode.IsPrint(r) && r > 0xffff:
+			fmt.Fprintf(&b, `\U%08x`, r)
+		case !unicode.IsPrint(r) && r > 0xff:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
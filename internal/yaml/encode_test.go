@@ -0,0 +1,74 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshal(t *testing.T) {
+	step := Mapping()
+	step.Set("uses", Scalar("docker://alpine"))
+	step.Set("with", StringMap(map[string]string{"entrypoint": "sh", "args": "-c make"}))
+	root := Mapping()
+	root.Set("name", Scalar("CI"))
+	root.Set("on", Strings([]string{"push", "pull_request"}))
+	root.Set("empty", Mapping())
+	root.Set("steps", Sequence(step, Scalar("plain")))
+	root.Set("timeout-minutes", Int(10))
+	root.Set("name", Scalar("renamed"))
+
+	assert.Equal(t, `name: renamed
+on: [push, pull_request]
+empty: {}
+steps:
+  - uses: docker://alpine
+    with:
+      args: -c make
+      entrypoint: sh
+  - plain
+timeout-minutes: 10
+`, string(Marshal(root)))
+	assert.Equal(t, "[]\n", string(Marshal(Sequence())))
+}
+
+func TestMarshalScalars(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected string
+	}{
+		{"docker://alpine:3.9", "docker://alpine:3.9"},
+		{"${{ secrets.TOKEN }}", "${{ secrets.TOKEN }}"},
+		{"--fast", "--fast"},
+		{"", "''"},
+		{"1.10", "'1.10'"},
+		{"-1", "'-1'"},
+		{".inf", "'.inf'"},
+		{"on", "'on'"},
+		{"No", "'No'"},
+		{"*/15 * * * *", "'*/15 * * * *'"},
+		{"a: b", "'a: b'"},
+		{"a #b", "'a #b'"},
+		{"it's", "it's"},
+		{"'quoted'", "'''quoted'''"},
+		{"- item", "'- item'"},
+		{" padded", "' padded'"},
+		{"tab\there", `"tab\there"`},
+		{"bell\a", `"bell\x07"`},
+		{"echo one\necho two\n", "|\n  echo one\n  echo two"},
+		{"echo one\necho two", "|-\n  echo one\n  echo two"},
+		{"one\n\ntwo\n\n", "|+\n  one\n\n  two\n"},
+		{"  indented\nline", `"  indented\nline"`},
+	}
+	for _, tc := range cases {
+		root := Mapping()
+		root.Set("key", Scalar(tc.value))
+		assert.Equal(t, "key: "+tc.expected+"\n", string(Marshal(root)), "%q", tc.value)
+	}
+
+	// Keys are never booleans, but other rules apply to them.
+	root := Mapping()
+	root.Set("on", Strings([]string{"a,b", "yes"}))
+	root.Set("1", Mapping())
+	assert.Equal(t, "on: ['a,b', 'yes']\n'1': {}\n", string(Marshal(root)))
+}
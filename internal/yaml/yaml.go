@@ -0,0 +1,104 @@
+// Package yaml reads and writes the subset of YAML that CI configuration
+// files use: mappings and sequences of strings, with the keys in the order
+// they were written or added.
+package yaml
+
+import "strconv"
+
+// Kind is the kind of a Node.
+type Kind int
+
+const (
+	// ScalarNode is a string.
+	ScalarNode Kind = iota
+
+	// MappingNode is a mapping, whose Content alternates keys and values.
+	MappingNode
+
+	// SequenceNode is a sequence, whose Content holds its items.
+	SequenceNode
+)
+
+// Node is a YAML value.
+type Node struct {
+	Kind    Kind
+	Value   string
+	Content []*Node
+
+	// Flow writes a sequence of scalars on one line, like `[a, b]'.
+	Flow bool
+
+	// Raw writes a scalar as it is, without quotes, for numbers and
+	// booleans.
+	Raw bool
+
+	// Line and Column are where Parse found the node, counting from 1.
+	// They are 0 for nodes made by the functions below.
+	Line, Column int
+}
+
+// Scalar returns a node for the string s.
+func Scalar(s string) *Node {
+	return &Node{Kind: ScalarNode, Value: s}
+}
+
+// Int returns a node for the number i.
+func Int(i int) *Node {
+	return &Node{Kind: ScalarNode, Value: strconv.Itoa(i), Raw: true}
+}
+
+// Mapping returns an empty mapping.
+func Mapping() *Node {
+	return &Node{Kind: MappingNode}
+}
+
+// Sequence returns a sequence of items.
+func Sequence(items ...*Node) *Node {
+	return &Node{Kind: SequenceNode, Content: items}
+}
+
+// Strings returns a sequence of scalars, written on one line.
+func Strings(items []string) *Node {
+	n := &Node{Kind: SequenceNode, Flow: true}
+	for _, item := range items {
+		n.Content = append(n.Content, Scalar(item))
+	}
+	return n
+}
+
+// StringMap returns a mapping of each key in m to its value, with the
+// keys in sorted order.
+func StringMap(m map[string]string) *Node {
+	n := Mapping()
+	for _, k := range sortedKeys(m) {
+		n.Set(k, Scalar(m[k]))
+	}
+	return n
+}
+
+// Set sets key to value in the mapping n.  A new key is added at the end.
+func (n *Node) Set(key string, value *Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = value
+			return
+		}
+	}
+	n.Content = append(n.Content, Scalar(key), value)
+}
+
+// Get returns the value of key in the mapping n, or nil if there is
+// none.
+func (n *Node) Get(key string) *Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Append adds an item to the end of the sequence n.
+func (n *Node) Append(item *Node) {
+	n.Content = append(n.Content, item)
+}
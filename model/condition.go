@@ -0,0 +1,771 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Condition is a compiled `if' expression, which decides whether an
+// action runs for an event.  See CompileCondition for the language.
+type Condition struct {
+	source string
+	root   exprNode
+}
+
+// ConditionError is an error in the source of a condition.  Offset is the
+// byte offset in the source at which the error was found.
+type ConditionError struct {
+	Offset  int
+	Message string
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Message)
+}
+
+// CompileCondition compiles the source of a condition.  The language is
+// deliberately small:
+//
+//   - literals: strings in single quotes, in which two single quotes
+//     stand for one, numbers, true, false, and null
+//   - event, which is the event's payload, and its fields, such as
+//     event.ref, event.pull_request.base.ref, and event.commits[0]
+//   - comparisons: ==, !=, <, <=, >, and >=
+//   - logic: &&, ||, !, and parentheses
+//   - functions: contains(a, b), which is true if the string a contains
+//     the string b, or if the array a has an element equal to b; and
+//     startsWith(a, b) and endsWith(a, b), for strings
+//
+// Compiling checks the types of the expression as far as they are known
+// without an event, so "'a' == 1" and "!'a'" are errors, but
+// "event.ref == 1" is not.  A field that is not in the payload is null.
+func CompileCondition(source string) (*Condition, error) {
+	c := &exprCompiler{src: source}
+	if err := c.lex(); err != nil {
+		return nil, err
+	}
+	root, err := c.expr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := c.peek(); tok.kind != exprEOF {
+		return nil, c.errorf(tok.offset, "unexpected %s", tok.describe())
+	}
+	if t := root.typ(); t != typeAny && t != typeBool {
+		return nil, c.errorf(0, "condition must be a boolean, got %s", t)
+	}
+	return &Condition{source: source, root: root}, nil
+}
+
+// String returns the source of the condition.
+func (c *Condition) String() string {
+	return c.source
+}
+
+// MarshalText returns the source of the condition, so that conditions
+// are encoded as strings.
+func (c *Condition) MarshalText() ([]byte, error) {
+	return []byte(c.source), nil
+}
+
+// UnmarshalText compiles a condition from its source.
+func (c *Condition) UnmarshalText(text []byte) error {
+	compiled, err := CompileCondition(string(text))
+	if err != nil {
+		return err
+	}
+	*c = *compiled
+	return nil
+}
+
+// Format returns the condition with the payload written as root, such as
+// "github.event", instead of "event".  Whitespace is normalized, and
+// parentheses are only kept where they are needed.
+func (c *Condition) Format(root string) string {
+	return c.root.format(root)
+}
+
+// Evaluate returns true if the condition holds for an event's payload.
+// Values that are not booleans count as false if they are null, "", or
+// 0, and as true otherwise.
+func (c *Condition) Evaluate(payload map[string]interface{}) bool {
+	return truthy(c.root.eval(payload))
+}
+
+// RunsFor returns true if the action has no condition, or if its
+// condition holds for event.
+func (a *Action) RunsFor(event *Event) bool {
+	return a.If == nil || a.If.Evaluate(event.Payload)
+}
+
+// exprType is the type of an expression, as far as it is known before
+// evaluation.
+type exprType int
+
+const (
+	typeAny exprType = iota
+	typeBool
+	typeNumber
+	typeString
+	typeNull
+)
+
+func (t exprType) String() string {
+	switch t {
+	case typeBool:
+		return "boolean"
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeNull:
+		return "null"
+	}
+	return "any"
+}
+
+type exprNode interface {
+	eval(payload map[string]interface{}) interface{}
+	typ() exprType
+	format(root string) string
+}
+
+// The precedence of each kind of expression, for deciding where format
+// needs parentheses.
+const (
+	precOr = iota
+	precAnd
+	precCompare
+	precUnary
+)
+
+// formatOperand formats x as an operand of an operator with precedence
+// prec, in parentheses if x binds less tightly.
+func formatOperand(x exprNode, prec int, root string) string {
+	xprec := precUnary
+	switch x := x.(type) {
+	case *logicalNode:
+		xprec = precOr
+		if x.and {
+			xprec = precAnd
+		}
+	case *compareNode:
+		xprec = precCompare
+	}
+	if xprec < prec {
+		return "(" + x.format(root) + ")"
+	}
+	return x.format(root)
+}
+
+type literalNode struct {
+	value interface{}
+	t     exprType
+}
+
+func (n *literalNode) eval(map[string]interface{}) interface{} { return n.value }
+func (n *literalNode) typ() exprType                           { return n.t }
+
+func (n *literalNode) format(string) string {
+	switch value := n.value.(type) {
+	case string:
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return "null"
+}
+
+// fieldNode is `event' followed by a path of field names and indexes,
+// which are strings and ints respectively.
+type fieldNode struct {
+	path []interface{}
+}
+
+func (n *fieldNode) eval(payload map[string]interface{}) interface{} {
+	var val interface{} = payload
+	for _, step := range n.path {
+		switch step := step.(type) {
+		case string:
+			obj, ok := val.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			val = obj[step]
+		case int:
+			arr, ok := val.([]interface{})
+			if !ok || step >= len(arr) {
+				return nil
+			}
+			val = arr[step]
+		}
+	}
+	return val
+}
+
+func (n *fieldNode) typ() exprType { return typeAny }
+
+func (n *fieldNode) format(root string) string {
+	var b strings.Builder
+	b.WriteString(root)
+	for _, step := range n.path {
+		switch step := step.(type) {
+		case string:
+			if isExprName(step) {
+				fmt.Fprintf(&b, ".%s", step)
+			} else {
+				fmt.Fprintf(&b, "['%s']", strings.Replace(step, "'", "''", -1))
+			}
+		case int:
+			fmt.Fprintf(&b, "[%d]", step)
+		}
+	}
+	return b.String()
+}
+
+type notNode struct {
+	x exprNode
+}
+
+func (n *notNode) eval(payload map[string]interface{}) interface{} { return !truthy(n.x.eval(payload)) }
+func (n *notNode) typ() exprType                                   { return typeBool }
+
+func (n *notNode) format(root string) string {
+	return "!" + formatOperand(n.x, precUnary, root)
+}
+
+type logicalNode struct {
+	and  bool
+	x, y exprNode
+}
+
+func (n *logicalNode) eval(payload map[string]interface{}) interface{} {
+	x := truthy(n.x.eval(payload))
+	if x != n.and {
+		return x
+	}
+	return truthy(n.y.eval(payload))
+}
+
+func (n *logicalNode) typ() exprType { return typeBool }
+
+func (n *logicalNode) format(root string) string {
+	op, prec := " || ", precOr
+	if n.and {
+		op, prec = " && ", precAnd
+	}
+	return formatOperand(n.x, prec, root) + op + formatOperand(n.y, prec+1, root)
+}
+
+type compareNode struct {
+	op   string
+	x, y exprNode
+}
+
+func (n *compareNode) eval(payload map[string]interface{}) interface{} {
+	x, y := n.x.eval(payload), n.y.eval(payload)
+	switch n.op {
+	case "==":
+		return equal(x, y)
+	case "!=":
+		return !equal(x, y)
+	}
+	cmp, ok := order(x, y)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func (n *compareNode) typ() exprType { return typeBool }
+
+func (n *compareNode) format(root string) string {
+	return formatOperand(n.x, precCompare+1, root) + " " + n.op + " " + formatOperand(n.y, precCompare+1, root)
+}
+
+// callNode holds the name of the function, rather than the function
+// itself, so that compiled conditions can be compared with
+// reflect.DeepEqual.
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n *callNode) eval(payload map[string]interface{}) interface{} {
+	return functions[n.name].fn(n.args[0].eval(payload), n.args[1].eval(payload))
+}
+
+func (n *callNode) typ() exprType { return typeBool }
+
+func (n *callNode) format(root string) string {
+	return fmt.Sprintf("%s(%s, %s)", n.name, n.args[0].format(root), n.args[1].format(root))
+}
+
+// functions are the functions a condition can call.  All of them take two
+// arguments.  The types are those that each argument allows, besides
+// typeAny.
+var functions = map[string]struct {
+	fn    func(a, b interface{}) bool
+	types [2][]exprType
+}{
+	"contains": {
+		fn: func(a, b interface{}) bool {
+			switch a := a.(type) {
+			case string:
+				s, ok := b.(string)
+				return ok && strings.Contains(a, s)
+			case []interface{}:
+				for _, elem := range a {
+					if equal(elem, b) {
+						return true
+					}
+				}
+			}
+			return false
+		},
+		types: [2][]exprType{{typeString}, {typeBool, typeNumber, typeString, typeNull}},
+	},
+	"startsWith": {
+		fn: func(a, b interface{}) bool {
+			s, ok1 := a.(string)
+			prefix, ok2 := b.(string)
+			return ok1 && ok2 && strings.HasPrefix(s, prefix)
+		},
+		types: [2][]exprType{{typeString}, {typeString}},
+	},
+	"endsWith": {
+		fn: func(a, b interface{}) bool {
+			s, ok1 := a.(string)
+			suffix, ok2 := b.(string)
+			return ok1 && ok2 && strings.HasSuffix(s, suffix)
+		},
+		types: [2][]exprType{{typeString}, {typeString}},
+	},
+}
+
+func truthy(val interface{}) bool {
+	switch val := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	}
+	if n, ok := number(val); ok {
+		return n != 0
+	}
+	return true
+}
+
+// number converts the numeric types that a payload may hold to float64.
+func number(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	}
+	return 0, false
+}
+
+func equal(x, y interface{}) bool {
+	if a, ok := number(x); ok {
+		b, ok := number(y)
+		return ok && a == b
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// order compares two numbers or two strings, returning -1, 0, or 1.  The
+// second return value is false if the values are not comparable.
+func order(x, y interface{}) (int, bool) {
+	if a, ok := number(x); ok {
+		b, ok := number(y)
+		switch {
+		case !ok:
+			return 0, false
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	}
+	a, ok1 := x.(string)
+	b, ok2 := y.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(a, b), true
+}
+
+// isExprName returns true if s can be written as a name, such as a field
+// name after `.', rather than as a string.
+func isExprName(s string) bool {
+	for i, ch := range s {
+		if ch != '_' && !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') && !(i > 0 && ch >= '0' && ch <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprIdent
+	exprString
+	exprNumber
+	exprPunct
+)
+
+type exprToken struct {
+	kind exprTokenKind
+
+	// text is the name of an identifier, the value of a string, or the
+	// text of a number or punctuation.
+	text   string
+	offset int
+}
+
+func (t exprToken) describe() string {
+	switch t.kind {
+	case exprEOF:
+		return "end of condition"
+	case exprIdent:
+		return fmt.Sprintf("name `%s'", t.text)
+	case exprString:
+		return fmt.Sprintf("string '%s'", strings.Replace(t.text, "'", "''", -1))
+	case exprNumber:
+		return fmt.Sprintf("number %s", t.text)
+	}
+	return fmt.Sprintf("`%s'", t.text)
+}
+
+// exprCompiler is a recursive-descent parser for conditions, which checks
+// types as it goes.
+type exprCompiler struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+func (c *exprCompiler) errorf(offset int, format string, a ...interface{}) error {
+	return &ConditionError{Offset: offset, Message: fmt.Sprintf(format, a...)}
+}
+
+// punctuation lists the operators and other punctuation, longest first.
+var punctuation = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ".", ","}
+
+func (c *exprCompiler) lex() error {
+	src := c.src
+	for i := 0; i < len(src); {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '\'':
+			var value strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return c.errorf(i, "unterminated string")
+				}
+				if src[j] == '\'' {
+					if j+1 < len(src) && src[j+1] == '\'' {
+						value.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				value.WriteByte(src[j])
+				j++
+			}
+			c.tokens = append(c.tokens, exprToken{kind: exprString, text: value.String(), offset: i})
+			i = j + 1
+		case ch >= '0' && ch <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			c.tokens = append(c.tokens, exprToken{kind: exprNumber, text: src[i:j], offset: i})
+			i = j
+		case ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			c.tokens = append(c.tokens, exprToken{kind: exprIdent, text: src[i:j], offset: i})
+			i = j
+		default:
+			found := false
+			for _, punct := range punctuation {
+				if strings.HasPrefix(src[i:], punct) {
+					c.tokens = append(c.tokens, exprToken{kind: exprPunct, text: punct, offset: i})
+					i += len(punct)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return c.errorf(i, "unexpected character `%c'", ch)
+			}
+		}
+	}
+	c.tokens = append(c.tokens, exprToken{kind: exprEOF, offset: len(src)})
+	return nil
+}
+
+func (c *exprCompiler) peek() exprToken {
+	return c.tokens[c.pos]
+}
+
+func (c *exprCompiler) next() exprToken {
+	tok := c.tokens[c.pos]
+	if tok.kind != exprEOF {
+		c.pos++
+	}
+	return tok
+}
+
+func (c *exprCompiler) isPunct(text string) bool {
+	tok := c.peek()
+	return tok.kind == exprPunct && tok.text == text
+}
+
+func (c *exprCompiler) expect(text string) error {
+	if tok := c.next(); tok.kind != exprPunct || tok.text != text {
+		return c.errorf(tok.offset, "expected `%s', got %s", text, tok.describe())
+	}
+	return nil
+}
+
+// expr : and ('||' and)* ;
+// and : comparison ('&&' comparison)* ;
+func (c *exprCompiler) expr() (exprNode, error) {
+	return c.logical("||", func() (exprNode, error) {
+		return c.logical("&&", c.comparison)
+	})
+}
+
+func (c *exprCompiler) logical(op string, operand func() (exprNode, error)) (exprNode, error) {
+	start := c.peek().offset
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for c.isPunct(op) {
+		c.next()
+		if err := c.checkBool(x, start, op); err != nil {
+			return nil, err
+		}
+		start = c.peek().offset
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkBool(y, start, op); err != nil {
+			return nil, err
+		}
+		x = &logicalNode{and: op == "&&", x: x, y: y}
+	}
+	return x, nil
+}
+
+func (c *exprCompiler) checkBool(x exprNode, offset int, op string) error {
+	if t := x.typ(); t != typeAny && t != typeBool {
+		return c.errorf(offset, "`%s' needs booleans, got %s", op, t)
+	}
+	return nil
+}
+
+// comparison : unary (('==' | '!=' | '<' | '<=' | '>' | '>=') unary)? ;
+func (c *exprCompiler) comparison() (exprNode, error) {
+	x, err := c.unary()
+	if err != nil {
+		return nil, err
+	}
+	tok := c.peek()
+	if tok.kind != exprPunct {
+		return x, nil
+	}
+	switch tok.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return x, nil
+	}
+	c.next()
+	y, err := c.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	xt, yt := x.typ(), y.typ()
+	if tok.text == "==" || tok.text == "!=" {
+		if xt != typeAny && yt != typeAny && xt != typeNull && yt != typeNull && xt != yt {
+			return nil, c.errorf(tok.offset, "cannot compare %s with %s", xt, yt)
+		}
+	} else {
+		for _, t := range []exprType{xt, yt} {
+			if t != typeAny && t != typeNumber && t != typeString {
+				return nil, c.errorf(tok.offset, "`%s' needs numbers or strings, got %s", tok.text, t)
+			}
+		}
+		if xt != typeAny && yt != typeAny && xt != yt {
+			return nil, c.errorf(tok.offset, "cannot compare %s with %s", xt, yt)
+		}
+	}
+	return &compareNode{op: tok.text, x: x, y: y}, nil
+}
+
+// unary : '!' unary | primary ;
+func (c *exprCompiler) unary() (exprNode, error) {
+	if !c.isPunct("!") {
+		return c.primary()
+	}
+	c.next()
+	start := c.peek().offset
+	x, err := c.unary()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkBool(x, start, "!"); err != nil {
+		return nil, err
+	}
+	return &notNode{x: x}, nil
+}
+
+// primary : STRING | NUMBER | 'true' | 'false' | 'null' | '(' expr ')' | field | call ;
+// field : 'event' ('.' NAME | '[' (NUMBER | STRING) ']')* ;
+// call : NAME '(' expr ',' expr ')' ;
+func (c *exprCompiler) primary() (exprNode, error) {
+	tok := c.next()
+	switch tok.kind {
+	case exprString:
+		return &literalNode{value: tok.text, t: typeString}, nil
+	case exprNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, c.errorf(tok.offset, "invalid number %s", tok.text)
+		}
+		return &literalNode{value: n, t: typeNumber}, nil
+	case exprPunct:
+		if tok.text != "(" {
+			break
+		}
+		x, err := c.expr()
+		if err != nil {
+			return nil, err
+		}
+		return x, c.expect(")")
+	case exprIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{value: tok.text == "true", t: typeBool}, nil
+		case "null":
+			return &literalNode{t: typeNull}, nil
+		case "event":
+			return c.field()
+		}
+		if c.isPunct("(") {
+			return c.call(tok)
+		}
+		return nil, c.errorf(tok.offset, "unknown name `%s'; conditions can only refer to `event'", tok.text)
+	}
+	return nil, c.errorf(tok.offset, "unexpected %s", tok.describe())
+}
+
+// field parses the path after `event'.
+func (c *exprCompiler) field() (exprNode, error) {
+	n := &fieldNode{}
+	for {
+		switch {
+		case c.isPunct("."):
+			c.next()
+			tok := c.next()
+			if tok.kind != exprIdent {
+				return nil, c.errorf(tok.offset, "expected field name, got %s", tok.describe())
+			}
+			n.path = append(n.path, tok.text)
+		case c.isPunct("["):
+			c.next()
+			tok := c.next()
+			switch tok.kind {
+			case exprString:
+				n.path = append(n.path, tok.text)
+			case exprNumber:
+				i, err := strconv.Atoi(tok.text)
+				if err != nil {
+					return nil, c.errorf(tok.offset, "index must be a whole number, got %s", tok.text)
+				}
+				n.path = append(n.path, i)
+			default:
+				return nil, c.errorf(tok.offset, "expected index, got %s", tok.describe())
+			}
+			if err := c.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// call parses the arguments of a function call and checks their types.
+func (c *exprCompiler) call(name exprToken) (exprNode, error) {
+	f, ok := functions[name.text]
+	if !ok {
+		return nil, c.errorf(name.offset, "unknown function `%s'", name.text)
+	}
+	c.next()
+	n := &callNode{name: name.text}
+	for i := range f.types {
+		if i > 0 {
+			if err := c.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		start := c.peek().offset
+		arg, err := c.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !allowsType(f.types[i], arg.typ()) {
+			return nil, c.errorf(start, "argument %d of `%s' must be a %s, got %s", i+1, name.text, f.types[i][0], arg.typ())
+		}
+		n.args = append(n.args, arg)
+	}
+	if err := c.expect(")"); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func allowsType(types []exprType, t exprType) bool {
+	if t == typeAny {
+		return true
+	}
+	for _, allowed := range types {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	payload := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"ref": "refs/heads/main",
+		"labels": ["deploy", "urgent"],
+		"number": 12,
+		"pull_request": {"draft": false, "title": "Fix 'quotes'"},
+		"commits": [{"message": "first"}, {"message": "second"}]
+	}`), &payload))
+
+	cases := []struct {
+		source   string
+		expected bool
+	}{
+		{"event.ref == 'refs/heads/main'", true},
+		{"event.ref != 'refs/heads/main'", false},
+		{"contains(event.labels, 'deploy')", true},
+		{"contains(event.labels, 'ship')", false},
+		{"contains(event.ref, 'heads')", true},
+		{"startsWith(event.ref, 'refs/heads/') && endsWith(event.ref, '/main')", true},
+		{"event.number > 10 && event.number <= 12", true},
+		{"event.number < 10 || event.number >= 13", false},
+		{"!event.pull_request.draft", true},
+		{"event.pull_request.title == 'Fix ''quotes'''", true},
+		{"event.commits[1].message == 'second'", true},
+		{"event['ref'] == event.ref", true},
+		{"event.commits[5].message == null", true},
+		{"event.missing.field", false},
+		{"event.missing == null", true},
+		{"!(event.ref == 'x' || event.number == 12)", false},
+		{"event.number == '12'", false},
+		{"event.ref < 5", false},
+		{"true", true},
+	}
+	for _, tc := range cases {
+		cond, err := CompileCondition(tc.source)
+		require.NoError(t, err, tc.source)
+		assert.Equal(t, tc.expected, cond.Evaluate(payload), tc.source)
+		assert.Equal(t, tc.source, cond.String())
+	}
+}
+
+func TestConditionFormat(t *testing.T) {
+	cases := []struct {
+		source   string
+		expected string
+	}{
+		{"event.ref=='refs/heads/main'", "github.event.ref == 'refs/heads/main'"},
+		{"(event.a || event.b) && !(event.c == 1.50)", "(github.event.a || github.event.b) && !(github.event.c == 1.5)"},
+		{"((event.a && event.b)) || event.c", "github.event.a && github.event.b || github.event.c"},
+		{"event.a || (event.b || event.c)", "github.event.a || (github.event.b || github.event.c)"},
+		{"contains( event.labels , 'it''s' )", "contains(github.event.labels, 'it''s')"},
+		{"event['ref'] != null && event['a b'][0] == true", "github.event.ref != null && github.event['a b'][0] == true"},
+	}
+	for _, tc := range cases {
+		cond, err := CompileCondition(tc.source)
+		require.NoError(t, err, tc.source)
+		assert.Equal(t, tc.expected, cond.Format("github.event"), tc.source)
+
+		// Formatting keeps the meaning of the condition.
+		again, err := CompileCondition(cond.Format("event"))
+		require.NoError(t, err, tc.source)
+		assert.Equal(t, cond.root, again.root, tc.source)
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	cases := []struct {
+		source  string
+		offset  int
+		message string
+	}{
+		{"event.ref == 'main", 13, "unterminated string"},
+		{"event.ref = 'main'", 10, "unexpected character `='"},
+		{"ref == 'main'", 0, "unknown name `ref'; conditions can only refer to `event'"},
+		{"event.ref == 'a' &&", 19, "unexpected end of condition"},
+		{"'a' == 1", 4, "cannot compare string with number"},
+		{"true < 1", 5, "`<' needs numbers or strings, got boolean"},
+		{"!'a'", 1, "`!' needs booleans, got string"},
+		{"event.a && 'b'", 11, "`&&' needs booleans, got string"},
+		{"'main'", 0, "condition must be a boolean, got string"},
+		{"includes(event.labels, 'x')", 0, "unknown function `includes'"},
+		{"startsWith(event.ref, 1)", 22, "argument 2 of `startsWith' must be a string, got number"},
+		{"contains(event.labels)", 21, "expected `,', got `)'"},
+		{"event.", 6, "expected field name, got end of condition"},
+		{"event[x]", 6, "expected index, got name `x'"},
+		{"(event.a", 8, "expected `)', got end of condition"},
+		{"event.a event.b", 8, "unexpected name `event'"},
+	}
+	for _, tc := range cases {
+		_, err := CompileCondition(tc.source)
+		require.Error(t, err, tc.source)
+		ce, ok := err.(*ConditionError)
+		require.True(t, ok, tc.source)
+		assert.Equal(t, tc.offset, ce.Offset, tc.source)
+		assert.Equal(t, tc.message, ce.Message, tc.source)
+	}
+}
+
+func TestConditionJSON(t *testing.T) {
+	cond, err := CompileCondition("event.ref == 'refs/heads/main'")
+	require.NoError(t, err)
+	action := &Action{Identifier: "a", If: cond}
+	data, err := json.Marshal(action)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"If":"event.ref == 'refs/heads/main'"`)
+
+	var decoded Action
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	event := &Event{Name: "push", Payload: map[string]interface{}{"ref": "refs/heads/main"}}
+	assert.True(t, decoded.RunsFor(event))
+	assert.True(t, (&Action{}).RunsFor(event))
+	assert.Error(t, json.Unmarshal([]byte(`{"If": "event.ref =="}`), &decoded))
+}
@@ -2,14 +2,33 @@ package model
 
 import (
 	"strings"
+	"time"
 )
 
 // Configuration is a parsed main.workflow file
 type Configuration struct {
+	Version   int
+	Heredocs  HeredocMode
 	Actions   []*Action
 	Workflows []*Workflow
 }
 
+// HeredocMode records how heredoc strings (`<<EOF ... EOF') in a file
+// were converted to values.
+type HeredocMode int
+
+const (
+	// HeredocCompressed means every run of whitespace in a heredoc,
+	// including newlines, was compressed to a single space, and leading
+	// and trailing whitespace was removed.  This is the default.
+	HeredocCompressed HeredocMode = iota
+
+	// HeredocVerbatim means heredoc content was kept as written, except
+	// that indentation common to all lines was removed, and so was the
+	// final newline of a value that is a name, such as `uses' or `on'.
+	HeredocVerbatim
+)
+
 // Action represents a single "action" stanza in a .workflow file.
 type Action struct {
 	Identifier string
@@ -18,13 +37,48 @@ type Action struct {
 	Needs      []string
 	Env        map[string]string
 	Secrets    []string
+
+	// Extends names the templates the action inherits attributes from,
+	// in order.  It is only allowed from version 4.  The other fields
+	// already include the inherited values.
+	Extends []string
+
+	// Matrix maps variables to the values to run the action with.  It is
+	// only allowed from version 6.  In a parsed Configuration, an action
+	// with a matrix is replaced by one action for each combination of
+	// values, which has the combination in Cell, and also in Env.
+	Matrix map[string][]string
+	Cell   map[string]string
+
+	// Timeout is how long the action may run before it is stopped and
+	// fails, or 0 for no limit of its own.  Retries is how many more times
+	// a failed action is run before it is counted as failing.  Both are
+	// only allowed from version 7.
+	Timeout time.Duration
+	Retries int
+
+	// If, if not nil, is the condition under which the action runs.  It
+	// is only allowed from version 8.  See RunsFor.
+	If *Condition
 }
 
 // Workflow represents a single "workflow" stanza in a .workflow file.
 type Workflow struct {
 	Identifier string
-	On         On
-	Resolves   []string
+
+	// On holds the events that trigger the workflow.  Before version 2,
+	// there is only ever one.
+	On       []On
+	Resolves []string
+
+	// Env holds environment variables for every action the workflow
+	// resolves.  It is only allowed from version 1.  See ActionEnv.
+	Env map[string]string
+
+	// Types and Branches, if not nil, limit which events trigger the
+	// workflow.  They are only allowed from version 3.  See Triggers.
+	Types    []string
+	Branches []string
 }
 
 // GetAction looks up action by identifier.
@@ -53,12 +107,87 @@ func (c *Configuration) GetWorkflow(id string) *Workflow {
 
 // GetWorkflows gets all Workflow structures that match a given type of event.
 // e.g., GetWorkflows("push")
+//
+// A workflow matches if any of its On values does.
 func (c *Configuration) GetWorkflows(eventType string) []*Workflow {
 	var ret []*Workflow
 	for _, workflow := range c.Workflows {
-		if strings.EqualFold(workflow.On.String(), eventType) {
-			ret = append(ret, workflow)
+		for _, on := range workflow.On {
+			if strings.EqualFold(on.String(), eventType) {
+				ret = append(ret, workflow)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// ResolvedActions returns the actions that run when workflow is
+// triggered: the actions it resolves and everything they need, directly
+// or indirectly, in the order they are first reached.  Unknown actions
+// are skipped.
+func (c *Configuration) ResolvedActions(workflow *Workflow) []*Action {
+	var ret []*Action
+	seen := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		if seen[id] {
+			return
 		}
+		seen[id] = true
+		action := c.GetAction(id)
+		if action == nil {
+			return
+		}
+		ret = append(ret, action)
+		for _, need := range action.Needs {
+			visit(need)
+		}
+	}
+	for _, id := range workflow.Resolves {
+		visit(id)
+	}
+	return ret
+}
+
+// OrderedActions returns the actions that ResolvedActions returns, each
+// after the actions it needs, as they would run one at a time.
+func (c *Configuration) OrderedActions(workflow *Workflow) []*Action {
+	var ret []*Action
+	seen := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		action := c.GetAction(id)
+		if action == nil {
+			return
+		}
+		for _, need := range action.Needs {
+			visit(need)
+		}
+		ret = append(ret, action)
+	}
+	for _, id := range workflow.Resolves {
+		visit(id)
+	}
+	return ret
+}
+
+// ActionEnv returns the environment of an action when it runs as part of
+// workflow: the workflow's Env, overridden by the action's own Env.
+func (w *Workflow) ActionEnv(action *Action) map[string]string {
+	if len(w.Env) == 0 {
+		return action.Env
+	}
+	ret := make(map[string]string, len(w.Env)+len(action.Env))
+	for k, v := range w.Env {
+		ret[k] = v
+	}
+	for k, v := range action.Env {
+		ret[k] = v
 	}
 	return ret
 }
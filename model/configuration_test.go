@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvedActions(t *testing.T) {
+	a := &Action{Identifier: "a", Needs: []string{"b", "c"}}
+	b := &Action{Identifier: "b", Needs: []string{"c", "missing"}}
+	c := &Action{Identifier: "c", Needs: []string{"a"}}
+	d := &Action{Identifier: "d"}
+	config := &Configuration{Actions: []*Action{a, b, c, d}}
+
+	assert.Equal(t, []*Action{a, b, c}, config.ResolvedActions(&Workflow{Resolves: []string{"a"}}))
+	assert.Equal(t, []*Action{d, c, a, b}, config.ResolvedActions(&Workflow{Resolves: []string{"d", "c"}}))
+	assert.Empty(t, config.ResolvedActions(&Workflow{}))
+}
+
+func TestOrderedActions(t *testing.T) {
+	a := &Action{Identifier: "a", Needs: []string{"b", "c"}}
+	b := &Action{Identifier: "b", Needs: []string{"c", "missing"}}
+	c := &Action{Identifier: "c"}
+	d := &Action{Identifier: "d", Needs: []string{"c"}}
+	config := &Configuration{Actions: []*Action{a, b, c, d}}
+
+	assert.Equal(t, []*Action{c, b, a}, config.OrderedActions(&Workflow{Resolves: []string{"a"}}))
+	assert.Equal(t, []*Action{c, d, b, a}, config.OrderedActions(&Workflow{Resolves: []string{"d", "a"}}))
+	assert.Empty(t, config.OrderedActions(&Workflow{}))
+}
+
+func TestActionEnv(t *testing.T) {
+	action := &Action{Env: map[string]string{"A": "action", "B": "action"}}
+	workflow := &Workflow{Env: map[string]string{"B": "workflow", "C": "workflow"}}
+	assert.Equal(t, map[string]string{"A": "action", "B": "action", "C": "workflow"}, workflow.ActionEnv(action))
+	assert.Equal(t, map[string]string{"A": "action", "B": "action"}, action.Env)
+	assert.Equal(t, action.Env, (&Workflow{}).ActionEnv(action))
+}
+
+func TestGetWorkflows(t *testing.T) {
+	push := &Workflow{Identifier: "push", On: []On{&OnEvent{Event: "push"}}}
+	both := &Workflow{Identifier: "both", On: []On{&OnEvent{Event: "Push"}, &OnEvent{Event: "pull_request"}}}
+	none := &Workflow{Identifier: "none"}
+	config := &Configuration{Workflows: []*Workflow{push, both, none}}
+
+	assert.Equal(t, []*Workflow{push, both}, config.GetWorkflows("push"))
+	assert.Equal(t, []*Workflow{both}, config.GetWorkflows("pull_request"))
+	assert.Empty(t, config.GetWorkflows("release"))
+}
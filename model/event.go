@@ -0,0 +1,174 @@
+package model
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Event is a repository event, such as a push, that might trigger
+// workflows.
+type Event struct {
+	// Name is the type of the event, such as "push" or "pull_request".
+	Name string
+
+	// Payload is the event's webhook payload, decoded from JSON.
+	Payload map[string]interface{}
+}
+
+// NewEvent returns an Event of the given type with a JSON-encoded webhook
+// payload.
+func NewEvent(name string, payload []byte) (*Event, error) {
+	event := &Event{Name: name}
+	if err := json.Unmarshal(payload, &event.Payload); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// ActivityType returns the `action' field of the payload, such as
+// "opened" for a pull_request event, or "" if there is none.
+func (e *Event) ActivityType() string {
+	return e.field("action")
+}
+
+// Branch returns the branch the event is for: the pushed branch for a
+// push, the base branch for pull request events, and the created or
+// deleted branch for create and delete.  For other events, it is the
+// `ref' of the payload, if that is a branch.  The second return value is
+// false if the event is not for a branch, such as a push of a tag.
+func (e *Event) Branch() (string, bool) {
+	var branch string
+	switch strings.ToLower(e.Name) {
+	case "create", "delete":
+		if e.field("ref_type") != "branch" {
+			return "", false
+		}
+		branch = e.field("ref")
+	case "pull_request", "pull_request_review", "pull_request_review_comment":
+		branch = e.field("pull_request", "base", "ref")
+	default:
+		ref := e.field("ref")
+		if !strings.HasPrefix(ref, "refs/heads/") {
+			return "", false
+		}
+		branch = strings.TrimPrefix(ref, "refs/heads/")
+	}
+	return branch, branch != ""
+}
+
+// field returns the string at path in the payload, or "" if there is
+// none.
+func (e *Event) field(path ...string) string {
+	var val interface{} = e.Payload
+	for _, key := range path {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		val = obj[key]
+	}
+	str, _ := val.(string)
+	return str
+}
+
+// Triggers returns true if event runs workflow: one of its On values
+// names the event, and the event passes the workflow's filters.  Types
+// only apply to events with an activity type, and Branches only to events
+// whose OnEvent is for a branch; other events in On are not filtered.
+func (w *Workflow) Triggers(event *Event) bool {
+	var match On
+	for _, on := range w.On {
+		if strings.EqualFold(on.String(), event.Name) {
+			match = on
+			break
+		}
+	}
+	if match == nil {
+		return false
+	}
+
+	found := false
+
+	if activityType := event.ActivityType(); w.Types != nil && activityType != "" {
+		found = false
+		for _, t := range w.Types {
+			if t == activityType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if onEvent, ok := match.(*OnEvent); ok && onEvent.Branches && w.Branches != nil {
+		branch, ok := event.Branch()
+		if !ok {
+			return false
+		}
+		found = false
+		for _, pattern := range w.Branches {
+			if MatchBranch(pattern, branch) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TriggeredWorkflows returns the workflows that event runs.  See
+// Workflow.Triggers.
+func (c *Configuration) TriggeredWorkflows(event *Event) []*Workflow {
+	var ret []*Workflow
+	for _, workflow := range c.Workflows {
+		if workflow.Triggers(event) {
+			ret = append(ret, workflow)
+		}
+	}
+	return ret
+}
+
+// MatchBranch returns true if branch matches pattern, a branch name in
+// which `*' matches any run of characters other than `/', and `**' matches
+// any run of characters at all.  For example, "release/*" matches
+// "release/v1" but not "release/v1/fix", and "**" matches every branch.
+func MatchBranch(pattern, branch string) bool {
+	if re, ok := branchPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp).MatchString(branch)
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			re.WriteString("[^/]*")
+			i++
+		default:
+			next := strings.IndexByte(pattern[i:], '*')
+			if next < 0 {
+				next = len(pattern) - i
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+next]))
+			i += next
+		}
+	}
+	re.WriteString("$")
+	compiled := regexp.MustCompile(re.String())
+	branchPatterns.Store(pattern, compiled)
+	return compiled.MatchString(branch)
+}
+
+// branchPatterns caches the regular expression of each pattern that
+// MatchBranch has been given, since a workflow's Branches are matched
+// against every event.
+var branchPatterns sync.Map
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBranch(t *testing.T) {
+	cases := []struct {
+		name, payload string
+		branch        string
+		ok            bool
+	}{
+		{"push", `{"ref": "refs/heads/release/v1"}`, "release/v1", true},
+		{"push", `{"ref": "refs/tags/v1"}`, "", false},
+		{"create", `{"ref": "main", "ref_type": "branch"}`, "main", true},
+		{"delete", `{"ref": "v1", "ref_type": "tag"}`, "", false},
+		{"pull_request", `{"pull_request": {"base": {"ref": "main"}}}`, "main", true},
+		{"pull_request_review", `{"pull_request": "main"}`, "", false},
+		{"deploy", `{"ref": "refs/heads/main"}`, "main", true},
+		{"issues", `{"action": "opened"}`, "", false},
+	}
+	for _, tc := range cases {
+		event, err := NewEvent(tc.name, []byte(tc.payload))
+		require.NoError(t, err)
+		branch, ok := event.Branch()
+		assert.Equal(t, tc.branch, branch, tc.payload)
+		assert.Equal(t, tc.ok, ok, tc.payload)
+	}
+
+	_, err := NewEvent("push", []byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestMatchBranch(t *testing.T) {
+	cases := []struct {
+		pattern, branch string
+		expected        bool
+	}{
+		{"main", "main", true},
+		{"main", "main2", false},
+		{"release/*", "release/v1", true},
+		{"release/*", "release/v1/fix", false},
+		{"release/**", "release/v1/fix", true},
+		{"**", "a/b/c", true},
+		{"*-stable", "1.0-stable", true},
+		{"v1.*", "v1x", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, MatchBranch(tc.pattern, tc.branch), "%s %s", tc.pattern, tc.branch)
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	workflow := &Workflow{
+		On: []On{
+			&OnEvent{Event: "pull_request", Branches: true},
+			&OnEvent{Event: "push", Branches: true},
+			&OnEvent{Event: "watch"},
+			&OnEvent{Event: "deploy", Branches: true},
+		},
+		Types:    []string{"opened"},
+		Branches: []string{"main"},
+	}
+	cases := []struct {
+		name, payload string
+		expected      bool
+	}{
+		{"pull_request", `{"action": "opened", "pull_request": {"base": {"ref": "main"}}}`, true},
+		{"PULL_REQUEST", `{"action": "opened", "pull_request": {"base": {"ref": "main"}}}`, true},
+		{"pull_request", `{"action": "closed", "pull_request": {"base": {"ref": "main"}}}`, false},
+		{"pull_request", `{"action": "opened", "pull_request": {"base": {"ref": "dev"}}}`, false},
+		{"push", `{"ref": "refs/heads/main"}`, true},
+		{"push", `{"ref": "refs/tags/main"}`, false},
+		{"watch", `{"action": "started"}`, false},
+		{"watch", `{}`, true},
+		{"issues", `{"action": "opened"}`, false},
+		{"deploy", `{"ref": "refs/heads/main"}`, true},
+		{"deploy", `{"ref": "refs/heads/dev"}`, false},
+	}
+	for _, tc := range cases {
+		event, err := NewEvent(tc.name, []byte(tc.payload))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, workflow.Triggers(event), "%s %s", tc.name, tc.payload)
+	}
+
+	unfiltered := &Workflow{On: []On{&OnEvent{Event: "push", Branches: true}}}
+	config := &Configuration{Workflows: []*Workflow{workflow, unfiltered}}
+	event := &Event{Name: "push", Payload: map[string]interface{}{"ref": "refs/heads/dev"}}
+	assert.Equal(t, []*Workflow{unfiltered}, config.TriggeredWorkflows(event))
+}
@@ -11,6 +11,11 @@ type On interface {
 
 type OnEvent struct {
 	Event string
+
+	// Branches is true if the event is for a branch, so that the
+	// workflow's Branches apply to it.  The parser sets it from the
+	// event types it knows.
+	Branches bool
 }
 
 type OnSchedule struct {
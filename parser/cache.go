@@ -0,0 +1,360 @@
+package parser
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/actions/workflow-parser/model"
+)
+
+// cacheFormat is part of every cache key.  Bump it whenever a change to
+// the parser changes the results for some file, so that results cached
+// by an older version, such as those in a DiskCache, are not used.
+const cacheFormat = 10
+
+// Cache memoizes the results of ParseFiles and ParseDir.  Keys are hashes
+// of a file's contents and the options it was parsed with.  A Cache must
+// be safe for concurrent use, and may drop entries whenever it likes.
+type Cache interface {
+	Get(key string) (*ParseResult, bool)
+	Add(key string, result *ParseResult)
+}
+
+// LRUCache is an in-memory Cache that holds a fixed number of results,
+// discarding the least recently used one to make room for a new one.
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	result *ParseResult
+}
+
+// NewLRUCache returns an LRUCache that holds up to size results.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the result cached under key, if any.
+func (c *LRUCache) Get(key string) (*ParseResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).result, true
+}
+
+// Add caches result under key.
+func (c *LRUCache) Add(key string, result *ParseResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).result = result
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, result: result})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of results in the cache.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// DiskCache is a Cache that keeps results in files in a directory, so
+// they survive from one run to the next.  Only the configuration and
+// diagnostics are kept; results from a DiskCache have no SyntaxTree.
+// Failures to read or write the directory are treated as cache misses.
+type DiskCache struct {
+	dir string
+}
+
+// diskEntry is what a DiskCache stores for each result, encoded as JSON.
+// JSON, unlike gob, keeps the difference between nil and empty slices
+// and maps, so a cached configuration is identical to a fresh one.
+type diskEntry struct {
+	Version   int
+	Heredocs  model.HeredocMode
+	Actions   []*diskAction
+	Workflows []*diskWorkflow
+	Errors    []diskError
+	Message   string
+}
+
+// diskAction and diskWorkflow are model.Action and model.Workflow with
+// their interface fields tagged with the concrete type.
+type diskAction struct {
+	Identifier string
+	Uses       *diskValue
+	Runs, Args *diskValue
+	Needs      []string
+	Env        map[string]string
+	Secrets    []string
+	Extends    []string
+	Matrix     map[string][]string
+	Cell       map[string]string
+	Timeout    time.Duration
+	Retries    int
+	If         *model.Condition
+}
+
+type diskWorkflow struct {
+	Identifier string
+	On         []*diskValue
+	Resolves   []string
+	Env        map[string]string
+	Types      []string
+	Branches   []string
+}
+
+type diskValue struct {
+	Type  string
+	Value json.RawMessage
+}
+
+type diskError struct {
+	Message  string
+	Pos      ErrorPos
+	Severity Severity
+	Code     Code
+}
+
+// diskTypes makes an empty value of each type that can be in a diskValue.
+var diskTypes = map[string]func() interface{}{
+	"UsesDockerImage": func() interface{} { return &model.UsesDockerImage{} },
+	"UsesRepository":  func() interface{} { return &model.UsesRepository{} },
+	"UsesPath":        func() interface{} { return &model.UsesPath{} },
+	"UsesInvalid":     func() interface{} { return &model.UsesInvalid{} },
+	"StringCommand":   func() interface{} { return &model.StringCommand{} },
+	"ListCommand":     func() interface{} { return &model.ListCommand{} },
+	"OnEvent":         func() interface{} { return &model.OnEvent{} },
+	"OnSchedule":      func() interface{} { return &model.OnSchedule{} },
+	"OnInvalid":       func() interface{} { return &model.OnInvalid{} },
+}
+
+func newDiskValue(val interface{}) *diskValue {
+	if val == nil {
+		return nil
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return nil
+	}
+	return &diskValue{Type: reflect.TypeOf(val).Elem().Name(), Value: b}
+}
+
+func (v *diskValue) decode() (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	newValue, ok := diskTypes[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", v.Type)
+	}
+	ret := newValue()
+	return ret, json.Unmarshal(v.Value, ret)
+}
+
+func newDiskEntry(result *ParseResult) *diskEntry {
+	config := result.Configuration
+	entry := &diskEntry{Version: config.Version, Heredocs: config.Heredocs, Message: result.message}
+	if config.Actions != nil {
+		entry.Actions = make([]*diskAction, 0, len(config.Actions))
+	}
+	for _, action := range config.Actions {
+		entry.Actions = append(entry.Actions, &diskAction{
+			Identifier: action.Identifier,
+			Uses:       newDiskValue(action.Uses),
+			Runs:       newDiskValue(action.Runs),
+			Args:       newDiskValue(action.Args),
+			Needs:      action.Needs,
+			Env:        action.Env,
+			Secrets:    action.Secrets,
+			Extends:    action.Extends,
+			Matrix:     action.Matrix,
+			Cell:       action.Cell,
+			Timeout:    action.Timeout,
+			Retries:    action.Retries,
+			If:         action.If,
+		})
+	}
+	if config.Workflows != nil {
+		entry.Workflows = make([]*diskWorkflow, 0, len(config.Workflows))
+	}
+	for _, workflow := range config.Workflows {
+		dw := &diskWorkflow{
+			Identifier: workflow.Identifier,
+			Resolves:   workflow.Resolves,
+			Env:        workflow.Env,
+			Types:      workflow.Types,
+			Branches:   workflow.Branches,
+		}
+		if workflow.On != nil {
+			dw.On = make([]*diskValue, 0, len(workflow.On))
+		}
+		for _, on := range workflow.On {
+			dw.On = append(dw.On, newDiskValue(on))
+		}
+		entry.Workflows = append(entry.Workflows, dw)
+	}
+	for _, pe := range result.Errors {
+		entry.Errors = append(entry.Errors, diskError{
+			Message:  pe.message,
+			Pos:      pe.Pos,
+			Severity: pe.Severity,
+			Code:     pe.Code,
+		})
+	}
+	return entry
+}
+
+func (entry *diskEntry) result() (*ParseResult, error) {
+	config := &model.Configuration{Version: entry.Version, Heredocs: entry.Heredocs}
+	if entry.Actions != nil {
+		config.Actions = make([]*model.Action, 0, len(entry.Actions))
+	}
+	for _, da := range entry.Actions {
+		action := &model.Action{
+			Identifier: da.Identifier,
+			Needs:      da.Needs,
+			Env:        da.Env,
+			Secrets:    da.Secrets,
+			Extends:    da.Extends,
+			Matrix:     da.Matrix,
+			Cell:       da.Cell,
+			Timeout:    da.Timeout,
+			Retries:    da.Retries,
+			If:         da.If,
+		}
+		for _, field := range []struct {
+			val *diskValue
+			set func(interface{}) bool
+		}{
+			{da.Uses, func(v interface{}) (ok bool) { action.Uses, ok = v.(model.Uses); return }},
+			{da.Runs, func(v interface{}) (ok bool) { action.Runs, ok = v.(model.Command); return }},
+			{da.Args, func(v interface{}) (ok bool) { action.Args, ok = v.(model.Command); return }},
+		} {
+			v, err := field.val.decode()
+			if err != nil {
+				return nil, err
+			}
+			if v != nil && !field.set(v) {
+				return nil, fmt.Errorf("unexpected type %s in action %s", field.val.Type, da.Identifier)
+			}
+		}
+		config.Actions = append(config.Actions, action)
+	}
+	if entry.Workflows != nil {
+		config.Workflows = make([]*model.Workflow, 0, len(entry.Workflows))
+	}
+	for _, dw := range entry.Workflows {
+		workflow := &model.Workflow{
+			Identifier: dw.Identifier,
+			Resolves:   dw.Resolves,
+			Env:        dw.Env,
+			Types:      dw.Types,
+			Branches:   dw.Branches,
+		}
+		if dw.On != nil {
+			workflow.On = make([]model.On, 0, len(dw.On))
+		}
+		for _, dv := range dw.On {
+			v, err := dv.decode()
+			if err != nil {
+				return nil, err
+			}
+			on, ok := v.(model.On)
+			if !ok {
+				return nil, fmt.Errorf("unexpected type %s in workflow %s", dv.Type, dw.Identifier)
+			}
+			workflow.On = append(workflow.On, on)
+		}
+		config.Workflows = append(config.Workflows, workflow)
+	}
+
+	result := &ParseResult{Configuration: config, message: entry.Message}
+	for _, de := range entry.Errors {
+		result.Errors = append(result.Errors, &ParseError{
+			message:  de.Message,
+			Pos:      de.Pos,
+			Severity: de.Severity,
+			Code:     de.Code,
+		})
+	}
+	return result, nil
+}
+
+// NewDiskCache returns a DiskCache that keeps its files in dir, creating
+// it if necessary.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get returns the result cached under key, if any.
+func (c *DiskCache) Get(key string) (*ParseResult, bool) {
+	b, err := ioutil.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return nil, false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, false
+	}
+	result, err := entry.result()
+	if err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// Add caches result under key.  The file is written under a temporary
+// name and then renamed, so that concurrent readers never see part of
+// it.
+func (c *DiskCache) Add(key string, result *ParseResult) {
+	b, err := json.Marshal(newDiskEntry(result))
+	if err != nil {
+		return
+	}
+	file, err := ioutil.TempFile(c.dir, key+".tmp")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(b)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		os.Remove(file.Name()) // nolint: errcheck
+	}
+}
@@ -0,0 +1,88 @@
+package parser
+
+// Code identifies the rule that produced a diagnostic.  Codes are stable
+// across releases, so they can be used with WithCodeSeverity and
+// WithIgnoredCodes, and in `# workflow:ignore' comments.
+type Code string
+
+// Diagnostics about suppression comments.
+const (
+	CodeUnusedSuppression  Code = "WF001"
+	CodeInvalidSuppression Code = "WF002"
+)
+
+// Diagnostics about syntax and the overall structure of the file.
+const (
+	CodeSyntax              Code = "WF100"
+	CodeInternal            Code = "WF101"
+	CodeInvalidDeclaration  Code = "WF102"
+	CodeIdentifierRedefined Code = "WF103"
+	CodeInvalidIdentifier   Code = "WF104"
+	CodeInvalidVersion      Code = "WF105"
+	CodeTypeMismatch        Code = "WF106"
+	CodeCircularDependency  Code = "WF107"
+	CodeGrammar             Code = "WF108"
+)
+
+// Diagnostics about actions.
+const (
+	CodeMissingUses            Code = "WF200"
+	CodeInvalidUses            Code = "WF201"
+	CodeUnknownActionAttribute Code = "WF202"
+	CodeAttributeRedefined     Code = "WF203"
+	CodeInvalidCommand         Code = "WF204"
+	CodeUnknownDependency      Code = "WF205"
+	CodeTooManySecrets         Code = "WF206"
+	CodeReservedVariable       Code = "WF207"
+	CodeInvalidVariableName    Code = "WF208"
+	CodeSecretConflict         Code = "WF209"
+	CodeSecretRedefined        Code = "WF210"
+	CodeEnvRedefined           Code = "WF211"
+	CodeUndefinedVariable      Code = "WF212"
+	CodeSecretInCommand        Code = "WF213"
+	CodeInvalidMatrix          Code = "WF214"
+	CodeMatrixConflict         Code = "WF215"
+	CodeMatrixTooLarge         Code = "WF216"
+	CodeInvalidTimeout         Code = "WF217"
+	CodeInvalidRetries         Code = "WF218"
+	CodeInvalidCondition       Code = "WF219"
+)
+
+// Diagnostics about workflows.
+const (
+	CodeMissingOn                Code = "WF300"
+	CodeInvalidOn                Code = "WF301"
+	CodeUnknownResolves          Code = "WF302"
+	CodeInvalidResolves          Code = "WF303"
+	CodeUnknownWorkflowAttribute Code = "WF304"
+	CodeInvalidFilter            Code = "WF305"
+)
+
+// Diagnostics about templates and the actions that extend them.
+const (
+	CodeInvalidExtends   Code = "WF400"
+	CodeUnknownTemplate  Code = "WF401"
+	CodeCircularTemplate Code = "WF402"
+	CodeTemplateConflict Code = "WF403"
+)
+
+// Diagnostics about included files.
+const (
+	CodeInvalidInclude  Code = "WF500"
+	CodeIncludeNotFound Code = "WF501"
+	CodeIncludeCycle    Code = "WF502"
+)
+
+// Diagnostics about locals and the strings that use them.
+const (
+	CodeUndefinedLocal Code = "WF600"
+	CodeUnusedLocal    Code = "WF601"
+	CodeLocalRedefined Code = "WF602"
+)
+
+// Diagnostics about GitHub Actions workflow files read by ParseYAML.
+const (
+	CodeYAMLSyntax      Code = "WF700"
+	CodeUnsupportedYAML Code = "WF701"
+	CodeInvalidYAML     Code = "WF702"
+)
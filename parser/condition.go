@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+	"github.com/actions/workflow-parser/model"
+)
+
+// parseIf parses the `if' attribute of an action or template, compiling
+// its condition.  Errors in the condition are reported at the column
+// where they were found, or at the `${local.NAME}' whose value they are
+// in.
+func (p *Parser) parseIf(action *model.Action, node ast.Node) {
+	if action.If != nil {
+		p.addWarning(node, CodeAttributeRedefined, "`if' redefined in %s `%s'", p.blockType, action.Identifier)
+		// continue, allowing the redefinition
+	}
+	src, ok := p.literalToString(node)
+	if !ok {
+		return
+	}
+	cond, err := model.CompileCondition(src)
+	if err != nil {
+		ce, ok := err.(*model.ConditionError)
+		if !ok {
+			p.addError(node, CodeInvalidCondition, "Invalid `if' in %s `%s': %s", p.blockType, action.Identifier, err)
+			return
+		}
+		offset := ce.Offset
+		if literal, ok := node.(*ast.LiteralType); ok && literal.Token.Type == token.STRING {
+			offset = p.sourceOffset(literal.Token.Value().(string), offset)
+		}
+		p.addDiagnostic(newError(p.conditionPos(node, offset), CodeInvalidCondition, "Invalid `if' in %s `%s': %s", p.blockType, action.Identifier, ce.Message))
+		return
+	}
+	action.If = cond
+	p.posMap[&action.If] = node
+}
+
+// conditionPos returns the position of a byte offset in the value of a
+// string literal, allowing for escapes in the literal.  For a heredoc, it
+// returns the position of the heredoc.
+func (p *Parser) conditionPos(node ast.Node, offset int) ErrorPos {
+	pos := posFromNode(node)
+	literal, ok := node.(*ast.LiteralType)
+	if !ok || literal.Token.Type != token.STRING || len(literal.Token.Text) < 2 {
+		return pos
+	}
+
+	// The text of a string from the JSON flavor is quoted again, so the
+	// escapes are those of the source.
+	raw, unquoteChar := literal.Token.Text[1:len(literal.Token.Text)-1], goUnquoteChar
+	if start := literal.Token.Pos.Offset; literal.Token.JSON && start < len(p.jsonSrc) && p.jsonSrc[start] == '"' {
+		s := &jsonScanner{src: p.jsonSrc, offset: start}
+		raw, unquoteChar = string(p.jsonSrc[start+1:start+s.stringLength()-1]), jsonUnquoteChar
+	}
+
+	pos.Column++
+	for n := 0; n < offset && raw != ""; {
+		size, tail, ok := unquoteChar(raw)
+		if !ok {
+			break
+		}
+		n += size
+		pos.Column += utf8.RuneCountInString(raw[:len(raw)-len(tail)])
+		raw = tail
+	}
+	return pos
+}
+
+// goUnquoteChar decodes the first character or escape of the inside of a
+// Go string literal, returning its length in bytes in the value, and the
+// rest of the literal.
+func goUnquoteChar(raw string) (size int, tail string, ok bool) {
+	r, multibyte, tail, err := strconv.UnquoteChar(raw, '"')
+	if err != nil {
+		return 0, "", false
+	}
+	if multibyte || r < utf8.RuneSelf || raw[0] != '\\' {
+		return utf8.RuneLen(r), tail, true
+	}
+	// \x and octal escapes are single bytes.
+	return 1, tail, true
+}
+
+// jsonUnquoteChar is goUnquoteChar for a JSON string, decoding it as
+// encoding/json does.
+func jsonUnquoteChar(raw string) (size int, tail string, ok bool) {
+	if raw[0] != '\\' {
+		r, n := utf8.DecodeRuneInString(raw)
+		return utf8.RuneLen(r), raw[n:], true
+	}
+	if len(raw) < 2 {
+		return 0, "", false
+	}
+	if raw[1] != 'u' {
+		return 1, raw[2:], true
+	}
+	r, ok := jsonHexRune(raw[2:])
+	if !ok {
+		return 0, "", false
+	}
+	if utf16.IsSurrogate(r) {
+		if len(raw) >= 12 && raw[6:8] == `\u` {
+			if r2, ok := jsonHexRune(raw[8:]); ok {
+				if combined := utf16.DecodeRune(r, r2); combined != utf8.RuneError {
+					return utf8.RuneLen(combined), raw[12:], true
+				}
+			}
+		}
+		r = utf8.RuneError
+	}
+	return utf8.RuneLen(r), raw[6:], true
+}
+
+// jsonHexRune decodes the four hexadecimal digits at the start of s.
+func jsonHexRune(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[:4], 16, 32)
+	return rune(n), err == nil
+}
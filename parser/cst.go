@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+	"github.com/actions/workflow-parser/model"
+)
+
+// SyntaxTree is a lossless concrete syntax tree for a .workflow file.
+// Every byte of the file, including comments and whitespace, is in
+// exactly one of its tokens, so String returns the original file.  Tools
+// can change the text of individual tokens, or replace whole values with
+// Replace, and print the tree back without disturbing the rest of the
+// file.
+//
+// A SyntaxTree is only available from the native parser; see
+// WithNativeParser and ParseResult.SyntaxTree.
+type SyntaxTree struct {
+	Root *SyntaxNode
+}
+
+// SyntaxKind is the kind of a node in a SyntaxTree.
+type SyntaxKind int
+
+const (
+	FileNode      SyntaxKind = iota // the whole file
+	BlockNode                       // keys followed by an object, such as `action "a" { ... }'
+	AttributeNode                   // a key, `=', and a value, such as `uses = "./a"'
+	ObjectNode                      // `{', attributes and blocks, and `}'
+	ListNode                        // `[', values separated by commas, and `]'
+	LiteralNode                     // a single string, heredoc, number, or boolean
+)
+
+var syntaxKindNames = [...]string{
+	FileNode:      "File",
+	BlockNode:     "Block",
+	AttributeNode: "Attribute",
+	ObjectNode:    "Object",
+	ListNode:      "List",
+	LiteralNode:   "Literal",
+}
+
+func (k SyntaxKind) String() string {
+	if k >= 0 && int(k) < len(syntaxKindNames) {
+		return syntaxKindNames[k]
+	}
+	return fmt.Sprintf("SyntaxKind(%d)", int(k))
+}
+
+// SyntaxElement is either a *SyntaxNode or a *SyntaxToken.
+type SyntaxElement interface {
+	String() string
+	writeTo(b *strings.Builder)
+}
+
+// SyntaxToken is a token in a SyntaxTree.  The position is where the token
+// was when the file was parsed; it is not updated after edits.
+type SyntaxToken struct {
+	Kind   TokenKind
+	Text   string
+	Offset int
+	Line   int
+	Column int
+}
+
+func (t *SyntaxToken) String() string {
+	return t.Text
+}
+
+func (t *SyntaxToken) writeTo(b *strings.Builder) {
+	b.WriteString(t.Text)
+}
+
+// IsTrivia returns true for whitespace and comments.
+func (t *SyntaxToken) IsTrivia() bool {
+	return t.Kind == TokenWhitespace || t.Kind == TokenComment
+}
+
+// SyntaxNode is a node in a SyntaxTree.  Its children are the tokens and
+// nodes that make it up, in source order.  Whitespace and comments belong
+// to the innermost node that encloses them, so a BlockNode starts with
+// its first key and ends with its closing `}'.
+type SyntaxNode struct {
+	Kind     SyntaxKind
+	Parent   *SyntaxNode
+	Children []SyntaxElement
+
+	// Action and Workflow are set on top-level BlockNodes to the action
+	// or workflow they define, if the block was valid enough to define
+	// one.
+	Action   *model.Action
+	Workflow *model.Workflow
+
+	// ast is the AST node built from this node, which the rest of the
+	// parser uses to refer back to the source.
+	ast ast.Node
+}
+
+// String returns the source text of the node.
+func (n *SyntaxNode) String() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	return b.String()
+}
+
+func (n *SyntaxNode) writeTo(b *strings.Builder) {
+	for _, child := range n.Children {
+		child.writeTo(b)
+	}
+}
+
+// Tokens returns all the tokens in the node, trivia included, in order.
+func (n *SyntaxNode) Tokens() []*SyntaxToken {
+	var ret []*SyntaxToken
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *SyntaxToken:
+			ret = append(ret, child)
+		case *SyntaxNode:
+			ret = append(ret, child.Tokens()...)
+		}
+	}
+	return ret
+}
+
+// Nodes returns the child nodes of a node.  For a FileNode or ObjectNode,
+// these are its blocks and attributes; for a ListNode, its elements.
+func (n *SyntaxNode) Nodes() []*SyntaxNode {
+	var ret []*SyntaxNode
+	for _, child := range n.Children {
+		if node, ok := child.(*SyntaxNode); ok {
+			ret = append(ret, node)
+		}
+	}
+	return ret
+}
+
+// Keys returns the keys of a BlockNode or AttributeNode, with quotes
+// removed: `action "a"' has the keys "action" and "a".
+func (n *SyntaxNode) Keys() []string {
+	var ret []string
+	for _, child := range n.Children {
+		tok, ok := child.(*SyntaxToken)
+		if !ok {
+			break
+		}
+		switch tok.Kind {
+		case TokenIdent:
+			ret = append(ret, tok.Text)
+		case TokenString:
+			key, err := token.Unquote(tok.Text)
+			if err != nil {
+				key = strings.Trim(tok.Text, `"`)
+			}
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+// Value returns the value of an AttributeNode, or the ObjectNode of a
+// BlockNode.  It returns nil for other kinds of node.
+func (n *SyntaxNode) Value() *SyntaxNode {
+	if n.Kind != BlockNode && n.Kind != AttributeNode {
+		return nil
+	}
+	nodes := n.Nodes()
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[len(nodes)-1]
+}
+
+// Attribute returns the attribute with the given name in a BlockNode or
+// ObjectNode, or nil if there is none.  If the attribute is defined more
+// than once, it returns the last definition, which is the one the parser
+// uses.
+func (n *SyntaxNode) Attribute(name string) *SyntaxNode {
+	obj := n
+	if n.Kind == BlockNode {
+		obj = n.Value()
+	}
+	if obj == nil || obj.Kind != ObjectNode {
+		return nil
+	}
+
+	var ret *SyntaxNode
+	for _, item := range obj.Nodes() {
+		if keys := item.Keys(); item.Kind == AttributeNode && len(keys) == 1 && keys[0] == name {
+			ret = item
+		}
+	}
+	return ret
+}
+
+// Replace replaces the contents of a value node (an ObjectNode, ListNode,
+// or LiteralNode) with the value in src, for example `"./b"' or
+// `["a", "b"]'.  The node keeps its place in the tree; everything around
+// it is untouched.
+func (n *SyntaxNode) Replace(src string) error {
+	switch n.Kind {
+	case ObjectNode, ListNode, LiteralNode:
+	default:
+		return fmt.Errorf("cannot replace a %s node", n.Kind)
+	}
+
+	s := newSyntaxParser(lex([]byte(src)))
+	s.node = &SyntaxNode{}
+	val, pe := s.value("value")
+	if pe == nil && s.peek().kind != TokenEOF {
+		pe = s.unexpected(s.peek(), "end of value")
+	}
+	if pe != nil {
+		return pe
+	}
+	s.next()
+
+	nodes := s.node.Nodes()
+	if len(nodes) != 1 || len(s.node.Children) != 1 {
+		return fmt.Errorf("value must not have leading or trailing whitespace or comments")
+	}
+	repl := nodes[0]
+	n.Kind = repl.Kind
+	n.Children = repl.Children
+	for _, child := range n.Children {
+		if node, ok := child.(*SyntaxNode); ok {
+			node.Parent = n
+		}
+	}
+	n.ast = val
+	return nil
+}
+
+// String returns the source text of the whole file.
+func (t *SyntaxTree) String() string {
+	return t.Root.String()
+}
+
+// Action returns the top-level block that defines the action with the
+// given identifier, or nil if there is none.
+func (t *SyntaxTree) Action(id string) *SyntaxNode {
+	for _, node := range t.Root.Nodes() {
+		if node.Action != nil && node.Action.Identifier == id {
+			return node
+		}
+	}
+	return nil
+}
+
+// Workflow returns the top-level block that defines the workflow with the
+// given identifier, or nil if there is none.
+func (t *SyntaxTree) Workflow(id string) *SyntaxNode {
+	for _, node := range t.Root.Nodes() {
+		if node.Workflow != nil && node.Workflow.Identifier == id {
+			return node
+		}
+	}
+	return nil
+}
+
+// link points each top-level block at the action or workflow that the
+// parser built from it.  A block with a matrix is linked to the action as
+// written, rather than to its expansions.
+func (t *SyntaxTree) link(posMap map[interface{}]ast.Node) {
+	actions := make(map[ast.Node]*model.Action)
+	workflows := make(map[ast.Node]*model.Workflow)
+	for key, node := range posMap {
+		switch key := key.(type) {
+		case *model.Action:
+			if key.Cell == nil {
+				actions[node] = key
+			}
+		case *model.Workflow:
+			workflows[node] = key
+		}
+	}
+
+	for _, node := range t.Root.Nodes() {
+		node.Action = actions[node.ast]
+		node.Workflow = workflows[node.ast]
+	}
+}
+
+func newSyntaxToken(tok lexToken) *SyntaxToken {
+	return &SyntaxToken{Kind: tok.kind, Text: tok.text, Offset: tok.pos.Offset, Line: tok.pos.Line, Column: tok.pos.Column}
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/actions/workflow-parser/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSyntaxTree(t *testing.T, src string) *ParseResult {
+	result, err := ParseWithResult(strings.NewReader(src), WithNativeParser())
+	require.NoError(t, err)
+	require.NotNil(t, result.SyntaxTree)
+	return result
+}
+
+func TestSyntaxTreeRoundTrip(t *testing.T) {
+	for _, dir := range []string{"valid", "invalid"} {
+		for _, filename := range fixtureFiles(t, dir) {
+			bytes, err := ioutil.ReadFile("../tests/" + dir + "/" + filename)
+			require.NoError(t, err)
+			result, err := ParseWithResult(strings.NewReader(string(bytes)), WithNativeParser())
+			require.NoError(t, err)
+			if result.HasSeverity(FATAL) && result.Errors[0].Code == CodeSyntax {
+				assert.Nil(t, result.SyntaxTree, filename)
+				continue
+			}
+			assert.Equal(t, string(bytes), result.SyntaxTree.String(), filename)
+		}
+	}
+}
+
+func TestSyntaxTreeStructure(t *testing.T) {
+	src := `# Deploy on push.
+workflow "deploy" {
+  on = "push"
+  resolves = ["a"]  // the only action
+}
+
+action "a" {
+  uses = "./a"
+  env = { A = "1", B = "2" }
+}
+`
+	result := parseSyntaxTree(t, src)
+	root := result.SyntaxTree.Root
+	assert.Equal(t, FileNode, root.Kind)
+
+	tokens := root.Tokens()
+	assert.Equal(t, TokenComment, tokens[0].Kind)
+	assert.Equal(t, "# Deploy on push.", tokens[0].Text)
+
+	items := root.Nodes()
+	require.Len(t, items, 2)
+	assert.Equal(t, BlockNode, items[0].Kind)
+	assert.Equal(t, []string{"workflow", "deploy"}, items[0].Keys())
+	assert.Equal(t, "workflow \"deploy\" {\n  on = \"push\"\n  resolves = [\"a\"]  // the only action\n}", items[0].String())
+	assert.Equal(t, root, items[0].Parent)
+
+	resolves := items[0].Attribute("resolves")
+	require.NotNil(t, resolves)
+	assert.Equal(t, AttributeNode, resolves.Kind)
+	assert.Equal(t, ListNode, resolves.Value().Kind)
+	assert.Equal(t, `["a"]`, resolves.Value().String())
+
+	env := items[1].Attribute("env").Value()
+	assert.Equal(t, ObjectNode, env.Kind)
+	assert.Equal(t, `"2"`, env.Attribute("B").Value().String())
+	assert.Nil(t, items[1].Attribute("runs"))
+}
+
+func TestSyntaxTreeLinks(t *testing.T) {
+	src := "action \"a\" { uses = \"./a\" }\n" +
+		"action \"a\" { uses = \"./b\" }\n" +
+		"workflow \"w\" { on = \"push\" }\n" +
+		"version = 0\n"
+	result := parseSyntaxTree(t, src)
+	config := result.Configuration
+	tree := result.SyntaxTree
+
+	items := tree.Root.Nodes()
+	require.Len(t, items, 4)
+	assert.True(t, items[0].Action == config.Actions[0])
+	assert.True(t, items[1].Action == config.Actions[1])
+	assert.True(t, items[2].Workflow == config.Workflows[0])
+	assert.Nil(t, items[3].Action)
+	assert.Nil(t, items[3].Workflow)
+
+	assert.True(t, tree.Action("a") == items[0])
+	assert.True(t, tree.Workflow("w") == items[2])
+	assert.Nil(t, tree.Action("w"))
+}
+
+func TestSyntaxTreeReplace(t *testing.T) {
+	src := `action "a" {
+  # Build it.
+  uses = "./a"   # local
+  args = "build"
+}
+`
+	result := parseSyntaxTree(t, src)
+	tree := result.SyntaxTree
+	require.NoError(t, tree.Action("a").Attribute("uses").Value().Replace(`"docker://alpine"`))
+	require.NoError(t, tree.Action("a").Attribute("args").Value().Replace(`["build", "--release"]`))
+	expected := `action "a" {
+  # Build it.
+  uses = "docker://alpine"   # local
+  args = ["build", "--release"]
+}
+`
+	assert.Equal(t, expected, tree.String())
+
+	args := tree.Action("a").Attribute("args").Value()
+	assert.Equal(t, ListNode, args.Kind)
+	assert.Len(t, args.Nodes(), 2)
+	assert.True(t, args.Nodes()[0].Parent == args)
+
+	config, err := parseString(tree.String())
+	require.NoError(t, err)
+	assert.Equal(t, &model.UsesDockerImage{Image: "alpine"}, config.Actions[0].Uses)
+
+	value := tree.Action("a").Attribute("uses").Value()
+	assert.Error(t, value.Replace(`"a" "b"`))
+	assert.Error(t, value.Replace(` "a"`))
+	assert.Error(t, value.Replace(`"unterminated`))
+	assert.Error(t, tree.Action("a").Replace(`"a"`))
+	assert.Equal(t, expected, tree.String())
+}
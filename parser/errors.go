@@ -44,13 +44,15 @@ func (e *Error) FirstError(severity Severity) error {
 
 // ParseError represents an error identified by the parser, either syntactic
 // (HCL) or semantic (.workflow) in nature.  There are fields for location
-// (File, Line, Column), severity, and base error string.  The `Error()`
-// function on this type concatenates whatever bits of the location are
-// available with the message.  The severity is only used for filtering.
+// (File, Line, Column), severity, the code of the rule that produced it,
+// and base error string.  The `Error()` function on this type
+// concatenates whatever bits of the location are available with the
+// message.  The severity is only used for filtering.
 type ParseError struct {
 	message  string
 	Pos      ErrorPos
 	Severity Severity
+	Code     Code
 }
 
 // ErrorPos represents the location of an error in a user's workflow
@@ -63,36 +65,43 @@ type ErrorPos struct {
 
 // newFatal creates a new error at the FATAL level, indicating that the
 // file is so broken it should not be displayed.
-func newFatal(pos ErrorPos, format string, a ...interface{}) *ParseError {
+func newFatal(pos ErrorPos, code Code, format string, a ...interface{}) *ParseError {
 	return &ParseError{
 		message:  fmt.Sprintf(format, a...),
 		Pos:      pos,
 		Severity: FATAL,
+		Code:     code,
 	}
 }
 
 // newError creates a new error at the ERROR level, indicating that the
 // file can be displayed but cannot be run.
-func newError(pos ErrorPos, format string, a ...interface{}) *ParseError {
+func newError(pos ErrorPos, code Code, format string, a ...interface{}) *ParseError {
 	return &ParseError{
 		message:  fmt.Sprintf(format, a...),
 		Pos:      pos,
 		Severity: ERROR,
+		Code:     code,
 	}
 }
 
 // newWarning creates a new error at the WARNING level, indicating that
 // the file might be runnable but might not execute as intended.
-func newWarning(pos ErrorPos, format string, a ...interface{}) *ParseError {
+func newWarning(pos ErrorPos, code Code, format string, a ...interface{}) *ParseError {
 	return &ParseError{
 		message:  fmt.Sprintf(format, a...),
 		Pos:      pos,
 		Severity: WARNING,
+		Code:     code,
 	}
 }
 
 func (e *ParseError) Error() string {
 	var sb strings.Builder
+	if e.Pos.File != "" {
+		sb.WriteString(e.Pos.File) // nolint: errcheck
+		sb.WriteString(": ")       // nolint: errcheck
+	}
 	if e.Pos.Line != 0 {
 		sb.WriteString("Line ")                  // nolint: errcheck
 		sb.WriteString(strconv.Itoa(e.Pos.Line)) // nolint: errcheck
@@ -124,13 +133,42 @@ type Severity int
 
 type errorList []*ParseError
 
-func (a errorList) Len() int           { return len(a) }
-func (a errorList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a errorList) Less(i, j int) bool { return a[i].Pos.Line < a[j].Pos.Line }
+func (a errorList) Len() int      { return len(a) }
+func (a errorList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a errorList) Less(i, j int) bool {
+	if a[i].Pos.File != a[j].Pos.File {
+		return a[i].Pos.File < a[j].Pos.File
+	}
+	return a[i].Pos.Line < a[j].Pos.Line
+}
 
 // sortErrors sorts the errors reported by the parser.  Do this after
-// parsing is complete.  The sort is stable, so order is preserved within
-// a single line: left to right, syntax errors before validation errors.
+// parsing is complete.  Diagnostics in the file itself come first, then
+// those in included files, by name.  The sort is stable, so order is
+// preserved within a single line: left to right, syntax errors before
+// validation errors.
 func (errors errorList) sort() {
 	sort.Stable(errors)
 }
+
+// dedupe removes each diagnostic that is identical to an earlier one, as
+// happens when the same line of a template is checked for every action
+// that extends it.
+func (errors errorList) dedupe() errorList {
+	type key struct {
+		message  string
+		pos      ErrorPos
+		severity Severity
+		code     Code
+	}
+	seen := make(map[key]bool, len(errors))
+	ret := errors[:0]
+	for _, pe := range errors {
+		k := key{pe.message, pe.Pos, pe.Severity, pe.Code}
+		if !seen[k] {
+			seen[k] = true
+			ret = append(ret, pe)
+		}
+	}
+	return ret
+}
@@ -1,43 +1,172 @@
 package parser
 
 import (
+	"sort"
 	"strings"
+
+	"github.com/actions/workflow-parser/model"
 )
 
+// EventType describes a repository event that can trigger a workflow.
+// The registry of known event types is used to validate the `on'
+// attribute of workflows, and is exported so that editors and other
+// completion tooling can offer the same list the parser accepts.
+type EventType struct {
+	// Name is the value used in `on = "<name>"'.  Names are matched
+	// case-insensitively but are always registered in lower case.
+	Name string
+
+	// Description is a short, human-readable summary of the event.
+	Description string
+
+	// DocsURL links to the documentation of the event's payload.
+	DocsURL string
+
+	// ActivityTypes lists the values of the `action' field of the
+	// event's payload, which workflows can filter on with `types'.  It
+	// is empty for events without one.
+	ActivityTypes []string
+
+	// Branches is true if the event is for a branch, which workflows can
+	// filter on with `branches'.  See model.Event.Branch.
+	Branches bool
+}
+
+// hasActivityType returns true if name is one of the event's activity
+// types.
+func (et EventType) hasActivityType(name string) bool {
+	for _, t := range et.ActivityTypes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EventTypes returns the event types supported by default, sorted by
+// name.
+func EventTypes() []EventType {
+	return sortedEventTypes(eventTypeWhitelist)
+}
+
+// LookupEventType returns the default registry entry for an event type.
+// The lookup is case-insensitive.
+func LookupEventType(name string) (EventType, bool) {
+	et, ok := eventTypeWhitelist[strings.ToLower(name)]
+	return et, ok
+}
+
 // isAllowedEventType returns true if the event type is supported.
 func isAllowedEventType(eventType string) bool {
 	_, ok := eventTypeWhitelist[strings.ToLower(eventType)]
 	return ok
 }
 
-// https://developer.github.com/actions/creating-workflows/workflow-configuration-options/#events-supported-in-workflow-files
-var eventTypeWhitelist = map[string]struct{}{
-	"check_run":                      {},
-	"check_suite":                    {},
-	"commit_comment":                 {},
-	"create":                         {},
-	"delete":                         {},
-	"deployment":                     {},
-	"deployment_status":              {},
-	"fork":                           {},
-	"gollum":                         {},
-	"issue_comment":                  {},
-	"issues":                         {},
-	"label":                          {},
-	"member":                         {},
-	"milestone":                      {},
-	"page_build":                     {},
-	"project_card":                   {},
-	"project_column":                 {},
-	"project":                        {},
-	"public":                         {},
-	"pull_request_review_comment":    {},
-	"pull_request_review":            {},
-	"pull_request":                   {},
-	"push":                           {},
-	"release":                        {},
-	"repository_dispatch":            {},
-	"repository_vulnerability_alert": {},
-	"status":                         {},
-	"watch":                          {},
+// isAllowedEventType returns true if the event type is supported by the
+// registry this parser was configured with.
+func (p *Parser) isAllowedEventType(eventType string) bool {
+	_, ok := p.eventTypes[strings.ToLower(eventType)]
+	return ok
 }
+
+// onEvent returns the model of an event that the registry this parser was
+// configured with supports, which knows whether the event is for a
+// branch.
+func (p *Parser) onEvent(eventType string) *model.OnEvent {
+	return &model.OnEvent{Event: eventType, Branches: p.eventTypes[strings.ToLower(eventType)].Branches}
+}
+
+func sortedEventTypes(registry map[string]EventType) []EventType {
+	ret := make([]EventType, 0, len(registry))
+	for _, et := range registry {
+		ret = append(ret, et)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func makeEventTypeMap(types []EventType) map[string]EventType {
+	ret := make(map[string]EventType, len(types))
+	for _, et := range types {
+		et.Name = strings.ToLower(et.Name)
+		ret[et.Name] = et
+	}
+	return ret
+}
+
+const eventDocsBaseURL = "https://developer.github.com/v3/activity/events/types/"
+
+func eventType(name, description string) EventType {
+	return EventType{
+		Name:        name,
+		Description: description,
+		DocsURL:     eventDocsBaseURL + "#" + strings.Replace(name, "_", "", -1) + "event",
+	}
+}
+
+func (et EventType) withTypes(types ...string) EventType {
+	et.ActivityTypes = types
+	return et
+}
+
+func (et EventType) withBranches() EventType {
+	et.Branches = true
+	return et
+}
+
+// https://developer.github.com/actions/creating-workflows/workflow-configuration-options/#events-supported-in-workflow-files
+var eventTypeWhitelist = makeEventTypeMap([]EventType{
+	eventType("check_run", "A check run is created, rerequested, completed, or has a requested action.").
+		withTypes("created", "rerequested", "completed", "requested_action"),
+	eventType("check_suite", "A check suite is completed, requested, or rerequested.").
+		withTypes("completed", "requested", "rerequested"),
+	eventType("commit_comment", "A commit comment is created.").
+		withTypes("created"),
+	eventType("create", "A branch or tag is created.").
+		withBranches(),
+	eventType("delete", "A branch or tag is deleted.").
+		withBranches(),
+	eventType("deployment", "A deployment is created."),
+	eventType("deployment_status", "A deployment's status changes."),
+	eventType("fork", "A user forks the repository."),
+	eventType("gollum", "A wiki page is created or updated."),
+	eventType("issue_comment", "A comment on an issue or pull request is created, edited, or deleted.").
+		withTypes("created", "edited", "deleted"),
+	eventType("issues", "An issue is opened, edited, closed, labeled, or otherwise changed.").
+		withTypes("opened", "edited", "deleted", "transferred", "pinned", "unpinned", "closed", "reopened",
+			"assigned", "unassigned", "labeled", "unlabeled", "locked", "unlocked", "milestoned", "demilestoned"),
+	eventType("label", "A label is created, edited, or deleted.").
+		withTypes("created", "edited", "deleted"),
+	eventType("member", "A collaborator is added, removed, or has their permissions changed.").
+		withTypes("added", "removed", "edited"),
+	eventType("milestone", "A milestone is created, closed, opened, edited, or deleted.").
+		withTypes("created", "closed", "opened", "edited", "deleted"),
+	eventType("page_build", "A GitHub Pages site is built."),
+	eventType("project_card", "A project card is created, edited, moved, converted, or deleted.").
+		withTypes("created", "edited", "moved", "converted", "deleted"),
+	eventType("project_column", "A project column is created, updated, moved, or deleted.").
+		withTypes("created", "edited", "moved", "deleted"),
+	eventType("project", "A project is created, updated, closed, reopened, or deleted.").
+		withTypes("created", "edited", "closed", "reopened", "deleted"),
+	eventType("public", "The repository is made public."),
+	eventType("pull_request_review_comment", "A comment on a pull request's diff is created, edited, or deleted.").
+		withTypes("created", "edited", "deleted").
+		withBranches(),
+	eventType("pull_request_review", "A pull request review is submitted, edited, or dismissed.").
+		withTypes("submitted", "edited", "dismissed").
+		withBranches(),
+	eventType("pull_request", "A pull request is opened, synchronized, closed, labeled, or otherwise changed.").
+		withTypes("assigned", "unassigned", "review_requested", "review_request_removed", "labeled", "unlabeled",
+			"opened", "edited", "closed", "ready_for_review", "locked", "unlocked", "reopened", "synchronize").
+		withBranches(),
+	eventType("push", "Commits are pushed to a branch or a tag is pushed.").
+		withBranches(),
+	eventType("release", "A release is published.").
+		withTypes("published", "unpublished", "created", "edited", "deleted", "prereleased"),
+	eventType("repository_dispatch", "A custom event is triggered through the API."),
+	eventType("repository_vulnerability_alert", "A security alert is created, dismissed, or resolved.").
+		withTypes("create", "dismiss", "resolve"),
+	eventType("status", "The status of a commit changes."),
+	eventType("watch", "A user stars the repository.").
+		withTypes("started"),
+})
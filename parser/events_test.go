@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/actions/workflow-parser/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsAllowedEventType(t *testing.T) {
@@ -28,3 +31,59 @@ func TestIsAllowedEventType(t *testing.T) {
 		assert.False(t, isAllowedEventType(s), "should not allow %q", s)
 	}
 }
+
+func TestEventTypeRegistry(t *testing.T) {
+	types := EventTypes()
+	assert.Len(t, types, len(eventTypeWhitelist))
+	for i := 1; i < len(types); i++ {
+		assert.True(t, types[i-1].Name < types[i].Name, "registry should be sorted")
+	}
+
+	push, ok := LookupEventType("PUSH")
+	if assert.True(t, ok) {
+		assert.Equal(t, "push", push.Name)
+		assert.NotEmpty(t, push.Description)
+		assert.Equal(t, "https://developer.github.com/v3/activity/events/types/#pushevent", push.DocsURL)
+	}
+
+	_, ok = LookupEventType("installation")
+	assert.False(t, ok)
+}
+
+// TestEventTypeBranches checks that the branches of a workflow apply to
+// the events that the registry says are for a branch, including added
+// ones.
+func TestEventTypeBranches(t *testing.T) {
+	src := `version = 3
+workflow "w" {
+  on = ["deploy", "push", "watch"]
+  resolves = "a"
+  branches = "main"
+}
+action "a" {
+  uses = "./a"
+}
+`
+	config, err := Parse(strings.NewReader(src), WithAdditionalEventTypes(EventType{Name: "deploy", Branches: true}))
+	require.NoError(t, err)
+	workflow := config.Workflows[0]
+	assert.Equal(t, []model.On{
+		&model.OnEvent{Event: "deploy", Branches: true},
+		&model.OnEvent{Event: "push", Branches: true},
+		&model.OnEvent{Event: "watch"},
+	}, workflow.On)
+
+	for _, tc := range []struct {
+		name, payload string
+		expected      bool
+	}{
+		{"deploy", `{"ref": "refs/heads/main"}`, true},
+		{"deploy", `{"ref": "refs/heads/dev"}`, false},
+		{"push", `{"ref": "refs/heads/dev"}`, false},
+		{"watch", `{"action": "started"}`, true},
+	} {
+		event, err := model.NewEvent(tc.name, []byte(tc.payload))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, workflow.Triggers(event), "%s %s", tc.name, tc.payload)
+	}
+}
@@ -0,0 +1,189 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// FileResult is the outcome of parsing one of the files given to
+// ParseFiles or found by ParseDir.
+type FileResult struct {
+	Filename string
+
+	// Result is the result of parsing the file.  The File of each
+	// diagnostic's position is set to Filename.  Result is nil if Err is
+	// set.
+	Result *ParseResult
+
+	// Err is a system error, such as a failure to read the file.
+	// Problems in the file itself are reported in Result.Errors.
+	Err error
+}
+
+// ParseFiles parses many .workflow files at once, using a bounded pool of
+// workers (see WithWorkers).  Files whose names end in `.yml' or `.yaml'
+// are parsed with ParseYAML, and those whose names end in `.json' with
+// ParseJSON.  The results are in the same order as filenames, regardless
+// of the order in which the files were parsed.
+//
+// With WithCache, files whose contents have already been parsed with the
+// same options are not parsed or validated again.  Files that include
+// other files are never cached, since their results also depend on the
+// included files.  A result from the cache shares its Configuration and
+// SyntaxTree with every other file of the same contents, so they must not
+// be modified.
+func ParseFiles(filenames []string, options ...OptionFunc) []*FileResult {
+	settings := newParser(options...)
+	workers := settings.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(filenames) {
+		workers = len(filenames)
+	}
+
+	results := make([]*FileResult, len(filenames))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = settings.parseFile(filenames[idx], options)
+			}
+		}()
+	}
+	for idx := range filenames {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// ParseDir parses every workflow file under dir, in any of the formats
+// of ParseFiles, as ParseFiles does: those whose names end in
+// `.workflow', `.workflow.json', `.yml', or `.yaml'.  Other JSON files,
+// such as package.json, are left alone.  The results are sorted by
+// filename.  The returned error is only non-nil if dir cannot be walked.
+func ParseDir(dir string, options ...OptionFunc) ([]*FileResult, error) {
+	var filenames []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isWorkflowFile(path) {
+			filenames = append(filenames, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ParseFiles(filenames, options...), nil
+}
+
+// isWorkflowFile returns true if ParseDir should parse filename.
+func isWorkflowFile(filename string) bool {
+	return strings.HasSuffix(filename, ".workflow") || strings.HasSuffix(filename, ".workflow.json") || isYAMLFile(filename)
+}
+
+// parseFile reads and parses a single file for ParseFiles.  p holds the
+// options, and is not otherwise used.
+func (p *Parser) parseFile(filename string, options []OptionFunc) *FileResult {
+	ret := &FileResult{Filename: filename}
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		ret.Err = err
+		return ret
+	}
+
+	parse, prefix := ParseWithResult, ""
+	switch {
+	case isYAMLFile(filename):
+		parse, prefix = ParseYAML, "yaml-"
+	case isJSONFile(filename):
+		parse, prefix = ParseJSON, "json-"
+	}
+	if p.cache == nil {
+		ret.Result, ret.Err = parse(bytes.NewReader(src), options...)
+		if ret.Result != nil {
+			ret.Result.setFile(filename)
+		}
+		return ret
+	}
+
+	key := prefix + p.cacheKey(src)
+	result, ok := p.cache.Get(key)
+	if !ok {
+		result, err = parse(bytes.NewReader(src), options...)
+		if err != nil {
+			ret.Err = err
+			return ret
+		}
+		if len(result.Includes) > 0 {
+			ret.Result = result
+			ret.Result.setFile(filename)
+			return ret
+		}
+		p.cache.Add(key, result)
+	}
+	ret.Result = result.shared(src, options)
+	ret.Result.yaml = isYAMLFile(filename)
+	ret.Result.json = isJSONFile(filename)
+	ret.Result.setFile(filename)
+	return ret
+}
+
+// cacheKey returns the key under which the result of parsing src with
+// p's options is cached.  It covers every option that can change the
+// result.
+func (p *Parser) cacheKey(src []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d %d %d %d %d %v %v %d %v %v %v %v %v\x00",
+		cacheFormat, p.minVersion, p.maxVersion, p.maxSecrets, p.maxExpansions, p.eventTypes,
+		p.codeSeverities, p.suppressSeverity, p.ignoredCodes, p.heredocs,
+		p.analyzeInterpolation, p.strictGrammar, p.nativeSyntax)
+	h.Write(src) // nolint: errcheck
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// shared returns a copy of a cached result that can be handed out for one
+// file.  The diagnostics are copied, so that setFile does not affect
+// other files, but the configuration and syntax tree are shared.  The
+// copy cannot be reparsed incrementally, since that would modify the
+// shared syntax tree.
+func (r *ParseResult) shared(src []byte, options []OptionFunc) *ParseResult {
+	ret := &ParseResult{
+		Configuration: r.Configuration,
+		Errors:        make([]*ParseError, 0, len(r.Errors)),
+		SyntaxTree:    r.SyntaxTree,
+		message:       r.message,
+		src:           src,
+		options:       options,
+	}
+	for _, pe := range r.Errors {
+		copied := *pe
+		ret.Errors = append(ret.Errors, &copied)
+	}
+	return ret
+}
+
+// setFile records filename as the file of every diagnostic in r, except
+// those in included files.
+func (r *ParseResult) setFile(filename string) {
+	for _, pe := range r.Errors {
+		if pe.Pos.File == "" {
+			pe.Pos.File = filename
+		}
+	}
+}
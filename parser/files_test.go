@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCache wraps a Cache and counts hits and misses.
+type countingCache struct {
+	Cache
+	mu           sync.Mutex
+	hits, misses int
+}
+
+func (c *countingCache) Get(key string) (*ParseResult, bool) {
+	result, ok := c.Cache.Get(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return result, ok
+}
+
+// fixtureDir copies every fixture into a temporary directory and returns
+// its name and the names of the copies, in sorted order.
+func fixtureDir(t *testing.T) (string, []string) {
+	dir, err := ioutil.TempDir("", "workflow-parser")
+	require.NoError(t, err)
+	var filenames []string
+	for _, sub := range []string{"invalid", "valid"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+		for _, filename := range fixtureFiles(t, sub) {
+			bytes, err := ioutil.ReadFile("../tests/" + sub + "/" + filename)
+			require.NoError(t, err)
+			name := filepath.Join(dir, sub, filename)
+			require.NoError(t, ioutil.WriteFile(name, bytes, 0644))
+			filenames = append(filenames, name)
+		}
+	}
+	return dir, filenames
+}
+
+// assertFileResults checks that each result matches parsing its file
+// directly.
+func assertFileResults(t *testing.T, filenames []string, results []*FileResult, options ...OptionFunc) {
+	require.Len(t, results, len(filenames))
+	for i, fr := range results {
+		assert.Equal(t, filenames[i], fr.Filename)
+		require.NoError(t, fr.Err, fr.Filename)
+
+		bytes, err := ioutil.ReadFile(fr.Filename)
+		require.NoError(t, err)
+		expected, err := ParseWithResult(strings.NewReader(string(bytes)), options...)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Configuration, fr.Result.Configuration, fr.Filename)
+		for _, pe := range fr.Result.Errors {
+			assert.Equal(t, fr.Filename, pe.Pos.File)
+			pe.Pos.File = ""
+		}
+		assert.Equal(t, describeErrors(expected.Errors), describeErrors(fr.Result.Errors), fr.Filename)
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	dir, filenames := fixtureDir(t)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not a workflow"), 0644))
+
+	results, err := ParseDir(dir, WithWorkers(3))
+	require.NoError(t, err)
+	assertFileResults(t, filenames, results)
+
+	_, err = ParseDir(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+
+	results = ParseFiles([]string{filepath.Join(dir, "missing.workflow")})
+	require.Len(t, results, 1)
+	assert.Error(t, results[0].Err)
+	assert.Nil(t, results[0].Result)
+}
+
+func TestParseDirFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workflow-parser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, src := range map[string]string{
+		"a.workflow":      `workflow "w" { on = "push" resolves = "a" } action "a" { uses = "./a" }`,
+		"b.workflow.json": `{"workflow": {"w": {"on": "push", "resolves": "a"}}, "action": {"a": {"uses": "./a"}}}`,
+		"c.yml":           "on: push\njobs:\n  a:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: ./a\n",
+		"package.json":    `{"name": "not a workflow"}`,
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	results, err := ParseDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, fr := range results {
+		names = append(names, filepath.Base(fr.Filename))
+		require.NoError(t, fr.Err, fr.Filename)
+		assert.Empty(t, describeErrors(fr.Result.Errors), fr.Filename)
+		assert.Len(t, fr.Result.Configuration.Actions, 1, fr.Filename)
+	}
+	assert.Equal(t, []string{"a.workflow", "b.workflow.json", "c.yml"}, names)
+}
+
+func TestParseFilesCache(t *testing.T) {
+	dir, filenames := fixtureDir(t)
+	defer os.RemoveAll(dir)
+
+	diskCache, err := NewDiskCache(filepath.Join(dir, "cache"))
+	require.NoError(t, err)
+	for _, cache := range []Cache{NewLRUCache(1000), diskCache} {
+		counter := &countingCache{Cache: cache}
+		results := ParseFiles(filenames, WithCache(counter))
+		assertFileResults(t, filenames, results)
+		assert.Equal(t, 0, counter.hits)
+
+		results = ParseFiles(filenames, WithCache(counter))
+		assertFileResults(t, filenames, results)
+		assert.Equal(t, len(filenames), counter.hits)
+
+		// Different options must not share results.
+		results = ParseFiles(filenames, WithCache(counter), WithSuppressWarnings())
+		assertFileResults(t, filenames, results, WithSuppressWarnings())
+		assert.Equal(t, len(filenames), counter.hits)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	a, b, c := &ParseResult{}, &ParseResult{}, &ParseResult{}
+	cache.Add("a", a)
+	cache.Add("b", b)
+	result, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.True(t, result == a)
+
+	cache.Add("c", c)
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("b")
+	assert.False(t, ok, "b was the least recently used")
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+	"github.com/hashicorp/hcl"
+	hclast "github.com/hashicorp/hcl/hcl/ast"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	hcltoken "github.com/hashicorp/hcl/hcl/token"
+)
+
+// parseHCL parses src with the HCL parser, and converts its AST to the
+// one the rest of the package works on.  This is the only place that
+// depends on HCL, so that WithNativeParser can become the only parser
+// without touching anything else.  A syntax error is returned as a FATAL
+// *ParseError; any other error is a system error.  Like the native
+// parser, it rejects literals whose value cannot be worked out, such as
+// `"${x"', which HCL accepts.
+func parseHCL(src []byte) (*ast.File, error) {
+	root, err := hcl.ParseBytes(src)
+	if pe, ok := err.(*hclparser.PosError); ok {
+		pos := ErrorPos{File: pe.Pos.Filename, Line: pe.Pos.Line, Column: pe.Pos.Column}
+		return nil, newFatal(pos, CodeSyntax, "%s", pe.Err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+
+	file := &ast.File{Node: fromHCLNode(root.Node)}
+	for _, group := range root.Comments {
+		g := &ast.CommentGroup{}
+		for _, comment := range group.List {
+			g.List = append(g.List, &ast.Comment{Start: fromHCLPos(comment.Start), Text: comment.Text})
+		}
+		file.Comments = append(file.Comments, g)
+	}
+	if pe := checkLiterals(file); pe != nil {
+		return nil, pe
+	}
+	return file, nil
+}
+
+// checkLiterals returns a FATAL *ParseError for the first key or literal
+// in file with a value that cannot be worked out, or nil if there is none.
+func checkLiterals(file *ast.File) *ParseError {
+	var pe *ParseError
+	ast.Walk(file.Node, func(node ast.Node) bool {
+		var tok token.Token
+		switch node := node.(type) {
+		case *ast.ObjectKey:
+			tok = node.Token
+		case *ast.LiteralType:
+			tok = node.Token
+		default:
+			return pe == nil
+		}
+		if problem := literalProblem(tok.Type, tok.Text); problem != "" && pe == nil {
+			pos := ErrorPos{File: tok.Pos.Filename, Line: tok.Pos.Line, Column: tok.Pos.Column}
+			pe = newFatal(pos, CodeSyntax, "%s", problem)
+		}
+		return false
+	})
+	return pe
+}
+
+func fromHCLNode(node hclast.Node) ast.Node {
+	switch node := node.(type) {
+	case *hclast.ObjectList:
+		return fromHCLList(node)
+	case *hclast.ObjectType:
+		return &ast.ObjectType{
+			Lbrace: fromHCLPos(node.Lbrace),
+			Rbrace: fromHCLPos(node.Rbrace),
+			List:   fromHCLList(node.List),
+		}
+	case *hclast.ListType:
+		list := &ast.ListType{Lbrack: fromHCLPos(node.Lbrack), Rbrack: fromHCLPos(node.Rbrack)}
+		for _, elem := range node.List {
+			list.Add(fromHCLNode(elem))
+		}
+		return list
+	case *hclast.LiteralType:
+		return &ast.LiteralType{Token: fromHCLToken(node.Token)}
+	default:
+		panic(fmt.Sprintf("unexpected HCL node %T", node))
+	}
+}
+
+func fromHCLList(list *hclast.ObjectList) *ast.ObjectList {
+	ret := &ast.ObjectList{}
+	for _, item := range list.Items {
+		keys := make([]*ast.ObjectKey, 0, len(item.Keys))
+		for _, key := range item.Keys {
+			keys = append(keys, &ast.ObjectKey{Token: fromHCLToken(key.Token)})
+		}
+		ret.Add(&ast.ObjectItem{Keys: keys, Assign: fromHCLPos(item.Assign), Val: fromHCLNode(item.Val)})
+	}
+	return ret
+}
+
+var fromHCLType = map[hcltoken.Type]token.Type{
+	hcltoken.IDENT:   token.IDENT,
+	hcltoken.NUMBER:  token.NUMBER,
+	hcltoken.FLOAT:   token.FLOAT,
+	hcltoken.BOOL:    token.BOOL,
+	hcltoken.STRING:  token.STRING,
+	hcltoken.HEREDOC: token.HEREDOC,
+}
+
+func fromHCLToken(tok hcltoken.Token) token.Token {
+	t, ok := fromHCLType[tok.Type]
+	if !ok {
+		t = token.ILLEGAL
+	}
+	return token.Token{Type: t, Pos: fromHCLPos(tok.Pos), Text: tok.Text, JSON: tok.JSON}
+}
+
+func fromHCLPos(pos hcltoken.Pos) token.Pos {
+	return token.Pos{Filename: pos.Filename, Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
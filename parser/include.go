@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+)
+
+// FileSystem is where the parser reads the files named by `include'.
+// Names are slash-separated paths relative to the root of the repository,
+// without the leading "./", such as "ci/common.workflow".
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// DirFileSystem is a FileSystem rooted at a directory on disk, usually the
+// root of a checked-out repository.
+type DirFileSystem string
+
+// ReadFile reads the named file from under the directory.
+func (dir DirFileSystem) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
+
+// MapFileSystem is a FileSystem held in memory, mapping names to file
+// contents.  Editors can use it to include files with unsaved changes.
+type MapFileSystem map[string]string
+
+// ReadFile returns the contents of the named file.
+func (fs MapFileSystem) ReadFile(name string) ([]byte, error) {
+	src, ok := fs[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return []byte(src), nil
+}
+
+// includeState is shared by a file and all the files it includes,
+// directly or indirectly.
+type includeState struct {
+	// stack holds the files being included, innermost last, to detect
+	// cycles.
+	stack []string
+
+	// done holds the files already included, which are not included
+	// again.
+	done map[string]bool
+}
+
+// isInclude returns true if item is a top-level `include = ...'.
+func (p *Parser) isInclude(item *ast.ObjectItem) bool {
+	return len(item.Keys) == 1 && p.identString(item.Keys[0].Token) == "include"
+}
+
+// parseInclude parses a top-level `include = "./path"' statement, and
+// adds the actions and templates of the included file, along with its
+// diagnostics, to p.  identifiers maps each identifier defined so far to
+// the file it is in.  A file is only included once, however many times it
+// is named.
+func (p *Parser) parseInclude(item *ast.ObjectItem, identifiers map[string]string, afterBlocks bool) {
+	if p.version < versionInclude {
+		p.addError(item.Val, CodeInvalidDeclaration, "`include' requires `version = %d' or later", versionInclude)
+		return
+	}
+	if afterBlocks {
+		p.addError(item.Val, CodeInvalidInclude, "`include' must come before any blocks")
+		return
+	}
+	raw, ok := p.literalToString(item.Val)
+	if !ok {
+		p.addError(item.Val, CodeInvalidInclude, "Invalid format for `include', expected string")
+		return
+	}
+	if !strings.HasPrefix(raw, "./") {
+		p.addError(item.Val, CodeInvalidInclude, "`include' must be a path that begins with `./', got `%s'", raw)
+		return
+	}
+	name := path.Clean(strings.TrimPrefix(raw, "./"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		p.addError(item.Val, CodeInvalidInclude, "`include' path `%s' is outside the repository", raw)
+		return
+	}
+
+	for i, including := range p.includes.stack {
+		if including == name {
+			chain := append(append([]string(nil), p.includes.stack[i:]...), name)
+			p.addError(item.Val, CodeIncludeCycle, "Include cycle: %s", describeCycle(chain))
+			return
+		}
+	}
+	if p.includes.done[name] {
+		return
+	}
+	if p.fileSystem == nil {
+		p.addError(item.Val, CodeIncludeNotFound, "Cannot include `%s': there is no file system to read it from", name)
+		return
+	}
+	p.included = append(p.included, name)
+	src, err := p.fileSystem.ReadFile(name)
+	if err != nil {
+		p.addError(item.Val, CodeIncludeNotFound, "Cannot include `%s': %s", name, err)
+		return
+	}
+
+	p.includes.stack = append(p.includes.stack, name)
+	child := p.includeParser()
+	child.processInclude(name, src)
+	p.includes.stack = p.includes.stack[:len(p.includes.stack)-1]
+	p.includes.done[name] = true
+
+	p.errors = append(p.errors, child.errors...)
+	p.suppressions = append(p.suppressions, child.suppressions...)
+	for key, node := range child.posMap {
+		p.posMap[key] = node
+	}
+	p.included = append(p.included, child.included...)
+	for _, action := range child.actions {
+		p.addIncluded(action.Identifier, child.posMap[action], identifiers)
+		p.actions = append(p.actions, action)
+	}
+	for _, template := range child.templates {
+		p.addIncluded(template.Identifier, child.posMap[template], identifiers)
+		p.templates = append(p.templates, template)
+	}
+}
+
+// addIncluded checks that an identifier from an included file is unique.
+func (p *Parser) addIncluded(id string, node ast.Node, identifiers map[string]string) {
+	if file, ok := identifiers[id]; ok {
+		p.addError(node, CodeIdentifierRedefined, "Identifier `%s' redefined; it is already defined in %s", id, describeFile(file))
+		return
+	}
+	identifiers[id] = posFromNode(node).File
+}
+
+// describeFile names a file in a diagnostic.
+func describeFile(file string) string {
+	if file == "" {
+		return "the including file"
+	}
+	return fmt.Sprintf("`%s'", file)
+}
+
+// describeCycle describes a chain of includes that ends where it starts,
+// such as "`a' includes `b', which includes `a'".
+func describeCycle(chain []string) string {
+	var sb strings.Builder
+	for i, name := range chain {
+		switch i {
+		case 0:
+		case 1:
+			sb.WriteString(" includes ") // nolint: errcheck
+		default:
+			sb.WriteString(", which includes ") // nolint: errcheck
+		}
+		sb.WriteString("`" + name + "'") // nolint: errcheck
+	}
+	return sb.String()
+}
+
+// includeParser returns a Parser with the same options as p, but none of
+// its state, for parsing an included file.  The state of includes is
+// shared, to detect cycles.
+func (p *Parser) includeParser() *Parser {
+	sub := p.blockParser()
+	sub.version = 0
+	sub.included = nil
+	sub.locals = nil
+	sub.localNodes = nil
+	return sub
+}
+
+// processInclude parses an included file, named name.  Unlike
+// processFile, it does not validate the file or apply suppressions,
+// since its actions may need actions in the including file; the including
+// file does both for everything it includes.  Every position in the file
+// has name as its File.
+func (p *Parser) processInclude(name string, src []byte) {
+	defer func() {
+		for _, pe := range p.errors {
+			if pe.Pos.File == "" {
+				pe.Pos.File = name
+			}
+		}
+	}()
+
+	root, err := p.parseSyntax(src)
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			p.addDiagnostic(pe)
+		} else {
+			p.addDiagnostic(newFatal(ErrorPos{}, CodeIncludeNotFound, "Cannot include `%s': %s", name, err))
+		}
+		return
+	}
+	setFilename(root, name)
+
+	p.parseSuppressions(root.Comments)
+	if p.strictGrammar {
+		p.checkStrictGrammar(src)
+	}
+	p.parseRoot(root.Node, nil)
+	for _, workflow := range p.workflows {
+		p.addError(p.posMap[workflow], CodeInvalidInclude, "Workflow `%s' is in an included file; only actions and templates can be included", workflow.Identifier)
+	}
+}
+
+// setFilename sets the Filename of every position in an AST.
+func setFilename(file *ast.File, name string) {
+	ast.Walk(file.Node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ObjectItem:
+			node.Assign.Filename = name
+		case *ast.ObjectKey:
+			node.Token.Pos.Filename = name
+		case *ast.LiteralType:
+			node.Token.Pos.Filename = name
+		case *ast.ListType:
+			node.Lbrack.Filename = name
+			node.Rbrack.Filename = name
+		case *ast.ObjectType:
+			node.Lbrace.Filename = name
+			node.Rbrace.Filename = name
+		}
+		return true
+	})
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			comment.Start.Filename = name
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/actions/workflow-parser/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// describeErrorsWithFiles is describeErrors with the file of each
+// diagnostic.
+func describeErrorsWithFiles(errors []*ParseError) []string {
+	ret := make([]string, 0, len(errors))
+	for _, pe := range errors {
+		ret = append(ret, fmt.Sprintf("%s:%d %s %s", pe.Pos.File, pe.Pos.Line, pe.Code, pe.message))
+	}
+	return ret
+}
+
+func TestInclude(t *testing.T) {
+	fs := MapFileSystem{
+		"ci/common.workflow": "version = 5\ninclude = \"./ci/docker.workflow\"\n\naction \"build\" {\n  extends = \"alpine\"\n  needs = \"setup\"\n}\n",
+		"ci/docker.workflow": "version = 4\n\ntemplate \"alpine\" {\n  uses = \"docker://alpine\"\n}\n",
+	}
+	src := `version = 5
+include = "./ci/common.workflow"
+include = "./ci/../ci/docker.workflow"
+
+workflow "w" {
+  on = "push"
+  resolves = "test"
+}
+
+action "setup" {
+  uses = "./setup"
+}
+
+action "test" {
+  needs = "build"
+  extends = "alpine"
+}
+`
+	result, err := ParseWithResult(strings.NewReader(src), WithFileSystem(fs))
+	require.NoError(t, err)
+	assert.Empty(t, describeErrorsWithFiles(result.Errors))
+	assert.Equal(t, []string{"ci/common.workflow", "ci/docker.workflow"}, result.Includes)
+
+	config := result.Configuration
+	require.Len(t, config.Actions, 3)
+	build := config.Actions[0]
+	assert.Equal(t, "build", build.Identifier)
+	assert.Equal(t, &model.UsesDockerImage{Image: "alpine"}, build.Uses)
+	var resolved []string
+	for _, action := range config.ResolvedActions(config.Workflows[0]) {
+		resolved = append(resolved, action.Identifier)
+	}
+	assert.Equal(t, []string{"test", "build", "setup"}, resolved)
+}
+
+func TestIncludeErrors(t *testing.T) {
+	fs := MapFileSystem{
+		"a.workflow": `version = 5
+include = "./b.workflow"
+
+action "dup" {
+  uses = "./a"
+}
+
+workflow "w" {
+  on = "push"
+}
+
+action "bad" {
+  uses = "./bad"
+  secrets = ["GITHUB_X"]
+}
+`,
+		"b.workflow": `version = 5
+include = "./a.workflow"
+
+action "dup" {
+  uses = "./b"
+  needs = "nowhere"
+}
+`,
+	}
+	src := `version = 5
+include = "./a.workflow"
+include = "./missing.workflow"
+include = "a.workflow"
+include = "./../a.workflow"
+
+# workflow:ignore WF207
+action "dup" {
+  uses = "./dup"
+}
+
+include = "./b.workflow"
+`
+	result, err := ParseWithResult(strings.NewReader(src), WithFileSystem(fs))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		":3 WF501 Cannot include `missing.workflow': open missing.workflow: file does not exist",
+		":4 WF500 `include' must be a path that begins with `./', got `a.workflow'",
+		":5 WF500 `include' path `./../a.workflow' is outside the repository",
+		":7 WF001 `workflow:ignore' for WF207 does not match any diagnostic",
+		":8 WF103 Identifier `dup' redefined; it is already defined in `b.workflow'",
+		":12 WF500 `include' must come before any blocks",
+		"a.workflow:4 WF103 Identifier `dup' redefined; it is already defined in `b.workflow'",
+		"a.workflow:8 WF500 Workflow `w' is in an included file; only actions and templates can be included",
+		"a.workflow:14 WF207 Environment variables and secrets beginning with `GITHUB_' are reserved",
+		"b.workflow:2 WF502 Include cycle: `a.workflow' includes `b.workflow', which includes `a.workflow'",
+		"b.workflow:6 WF205 Action `dup' needs nonexistent action `nowhere'",
+	}, describeErrorsWithFiles(result.Errors))
+	assert.Equal(t, []string{"a.workflow", "b.workflow", "missing.workflow"}, result.Includes)
+
+	_, err = ParseWithResult(strings.NewReader(src))
+	require.NoError(t, err)
+	result, err = ParseWithResult(strings.NewReader("version = 5\ninclude = \"./a.workflow\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{":2 WF501 Cannot include `a.workflow': there is no file system to read it from"}, describeErrorsWithFiles(result.Errors))
+	assert.Empty(t, result.Includes)
+}
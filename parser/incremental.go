@@ -0,0 +1,284 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+	"github.com/actions/workflow-parser/model"
+)
+
+// TextEdit replaces the bytes of a file from Start up to, but not
+// including, End with Text.  Offsets are in bytes, so an editor working
+// in characters or UTF-16 code units must convert them first.
+type TextEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// blockState is the result of parsing a single top-level block, before
+// any validation that looks across blocks.
+type blockState struct {
+	item     *ast.ObjectItem
+	action   *model.Action
+	workflow *model.Workflow
+	template *model.Action
+
+	// id is the identifier the block declares.  declares is false if the
+	// block is too malformed to declare one.
+	id       string
+	declares bool
+
+	// errors and posMap are the diagnostics and positions from parsing
+	// the block.  The errors have not been through suppression yet.
+	errors errorList
+	posMap map[interface{}]ast.Node
+
+	// usedLocals holds the locals that the block used.
+	usedLocals map[string]bool
+
+	// checkErrors are the diagnostics of the checks of validate that
+	// look at the block alone, once checked is true; see checkBlocks.
+	checked     bool
+	checkErrors errorList
+}
+
+// blockParser returns a Parser with the same options as p, but none of
+// its state, for parsing a single block.
+func (p *Parser) blockParser() *Parser {
+	sub := *p
+	sub.actions = nil
+	sub.workflows = nil
+	sub.templates = nil
+	sub.own = nil
+	sub.errors = nil
+	sub.posMap = make(map[interface{}]ast.Node)
+	sub.usedLocals = make(map[string]bool)
+	sub.suppressions = nil
+	sub.syntaxTree = nil
+	sub.root = nil
+	sub.blocks = nil
+	return &sub
+}
+
+// Reparse applies an edit to the file that prev came from, and parses the
+// result with the same options.  It is meant for editors, which need
+// fresh diagnostics after every keystroke.
+//
+// If prev has a SyntaxTree (see WithNativeParser), and the edit falls
+// within a single top-level block and leaves it a single block, only that
+// block is parsed again; the other blocks, and their diagnostics, are
+// reused, as are the diagnostics of the checks that look at a block
+// alone.  Validation across blocks, such as dependencies between actions
+// and the limit on secrets, is always run again.  Any other edit causes
+// the whole file to be parsed again, as does any edit to a file parsed
+// with ParseYAML or ParseJSON.
+//
+// The new result shares state with prev, and updates some of it, so prev
+// must not be used once Reparse returns.  For the same reason, prev's
+// SyntaxTree must not have been modified.
+func Reparse(prev *ParseResult, edit TextEdit) (*ParseResult, error) {
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > len(prev.src) {
+		return nil, fmt.Errorf("edit from %d to %d is outside the file, which is %d bytes long", edit.Start, edit.End, len(prev.src))
+	}
+
+	src := make([]byte, 0, len(prev.src)-(edit.End-edit.Start)+len(edit.Text))
+	src = append(src, prev.src[:edit.Start]...)
+	src = append(src, edit.Text...)
+	src = append(src, prev.src[edit.End:]...)
+
+	if prev.yaml {
+		return ParseYAML(bytes.NewReader(src), prev.options...)
+	}
+	if prev.json {
+		return ParseJSON(bytes.NewReader(src), prev.options...)
+	}
+	if result := reparseBlock(prev, edit, src); result != nil {
+		return result, nil
+	}
+	return ParseWithResult(bytes.NewReader(src), prev.options...)
+}
+
+// reparseBlock handles the common case for Reparse, where the edit is
+// within one top-level block.  It returns nil if the edit is not, or if
+// the block does not parse as a single block afterwards.
+func reparseBlock(prev *ParseResult, edit TextEdit, src []byte) *ParseResult {
+	if prev.SyntaxTree == nil || prev.parser == nil {
+		return nil
+	}
+	oldRoot := prev.SyntaxTree.Root
+
+	// Find the block, both among the children of the root node and among
+	// the top-level items.
+	childIdx, itemIdx := -1, -1
+	var old *SyntaxNode
+	for i, child := range oldRoot.Children {
+		node, ok := child.(*SyntaxNode)
+		if !ok {
+			continue
+		}
+		itemIdx++
+		start, end := node.span()
+		if start <= edit.Start && edit.End <= end {
+			childIdx, old = i, node
+			break
+		}
+	}
+	if old == nil || old.Kind != BlockNode || prev.parser.blocks[itemIdx] == nil {
+		return nil
+	}
+
+	start, end := old.span()
+	newEnd := end + len(edit.Text) - (edit.End - edit.Start)
+	oldTokens := old.Tokens()
+	first := oldTokens[0]
+	s := newSyntaxParser(lexAt(src[start:newEnd], token.Pos{Offset: start, Line: first.Line, Column: first.Column}))
+	list, pe := s.file()
+	if pe != nil || len(s.node.Children) != 1 || s.node.Nodes()[0].Kind != BlockNode {
+		return nil
+	}
+	node := s.node.Nodes()[0]
+
+	// Everything after the block moves by the same number of bytes and
+	// lines.  Anything on the same line as the end of the block also
+	// moves sideways.
+	oldLast := oldTokens[len(oldTokens)-1]
+	newTokens := node.Tokens()
+	newLast := newTokens[len(newTokens)-1]
+	sh := shift{
+		offset:  newEnd - end,
+		lines:   newLast.Line - oldLast.Line,
+		line:    oldLast.Line,
+		columns: newLast.Column - oldLast.Column,
+	}
+
+	root := &SyntaxNode{Kind: FileNode, Children: make([]SyntaxElement, len(oldRoot.Children))}
+	copy(root.Children, oldRoot.Children)
+	root.Children[childIdx] = node
+	for i, child := range root.Children {
+		switch child := child.(type) {
+		case *SyntaxNode:
+			child.Parent = root
+			if i > childIdx {
+				for _, tok := range child.Tokens() {
+					sh.token(tok)
+				}
+			}
+		case *SyntaxToken:
+			if i > childIdx {
+				sh.token(child)
+			}
+		}
+	}
+
+	oldItems := prev.parser.root.Node.(*ast.ObjectList).Items
+	items := make([]*ast.ObjectItem, len(oldItems))
+	copy(items, oldItems)
+	items[itemIdx] = list.Items[0]
+	cached := make([]*blockState, len(prev.parser.blocks))
+	copy(cached, prev.parser.blocks)
+	cached[itemIdx] = nil
+	for i := itemIdx + 1; i < len(items); i++ {
+		sh.node(items[i])
+		if cached[i] != nil {
+			for _, pe := range cached[i].errors {
+				sh.errorPos(&pe.Pos)
+			}
+			for _, pe := range cached[i].checkErrors {
+				sh.errorPos(&pe.Pos)
+			}
+		}
+	}
+	objectList := &ast.ObjectList{Items: items}
+	root.ast = objectList
+
+	var lexTokens []lexToken
+	for _, tok := range root.Tokens() {
+		lexTokens = append(lexTokens, tok.lexToken())
+	}
+
+	p := newParser(prev.options...)
+	p.syntaxTree = &SyntaxTree{Root: root}
+	p.processFile(src, &ast.File{Node: objectList, Comments: commentGroups(lexTokens)}, cached)
+	return p.result(src, prev.options)
+}
+
+// span returns the offsets of the start and end of a node.
+func (n *SyntaxNode) span() (int, int) {
+	tokens := n.Tokens()
+	if len(tokens) == 0 {
+		return -1, -1
+	}
+	last := tokens[len(tokens)-1]
+	return tokens[0].Offset, last.Offset + len(last.Text)
+}
+
+func (t *SyntaxToken) lexToken() lexToken {
+	return lexToken{kind: t.Kind, text: t.Text, pos: token.Pos{Offset: t.Offset, Line: t.Line, Column: t.Column}}
+}
+
+// shift moves positions that come after an edit.  Positions on line
+// move sideways by columns, as well as down by lines.
+type shift struct {
+	offset  int
+	lines   int
+	line    int
+	columns int
+}
+
+func (sh shift) pos(pos *token.Pos) {
+	if pos.Line == sh.line {
+		pos.Column += sh.columns
+	}
+	pos.Line += sh.lines
+	pos.Offset += sh.offset
+}
+
+func (sh shift) errorPos(pos *ErrorPos) {
+	if pos.Line == 0 {
+		return
+	}
+	if pos.Line == sh.line {
+		pos.Column += sh.columns
+	}
+	pos.Line += sh.lines
+}
+
+func (sh shift) token(tok *SyntaxToken) {
+	if tok.Line == sh.line {
+		tok.Column += sh.columns
+	}
+	tok.Line += sh.lines
+	tok.Offset += sh.offset
+}
+
+func (sh shift) node(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.ObjectList:
+		for _, item := range node.Items {
+			sh.node(item)
+		}
+	case *ast.ObjectItem:
+		for _, key := range node.Keys {
+			sh.pos(&key.Token.Pos)
+		}
+		if node.Assign.IsValid() {
+			sh.pos(&node.Assign)
+		}
+		sh.node(node.Val)
+	case *ast.ObjectType:
+		sh.pos(&node.Lbrace)
+		sh.pos(&node.Rbrace)
+		sh.node(node.List)
+	case *ast.ListType:
+		sh.pos(&node.Lbrack)
+		sh.pos(&node.Rbrack)
+		for _, elem := range node.List {
+			sh.node(elem)
+		}
+	case *ast.LiteralType:
+		sh.pos(&node.Token.Pos)
+	}
+}
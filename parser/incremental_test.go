@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reparseSource = `workflow "w" {
+  on = "push"
+  resolves = ["b"]
+}
+
+action "a" {
+  uses = "./a"
+  secrets = ["TOKEN"]
+}
+
+action "b" {
+  uses = "./b"
+  needs = ["a"]
+  # workflow:ignore WF202
+  color = "blue"
+} action "c" { uses = "./c" }
+`
+
+// assertSameAsFullParse checks that an incremental result matches what
+// parsing the same text from scratch gives, positions included.
+func assertSameAsFullParse(t *testing.T, result *ParseResult, msg string) {
+	full, err := ParseWithResult(strings.NewReader(string(result.src)), result.options...)
+	require.NoError(t, err)
+
+	assert.Equal(t, full.Configuration, result.Configuration, msg)
+	assert.Equal(t, describeErrors(full.Errors), describeErrors(result.Errors), msg)
+	if full.SyntaxTree == nil {
+		assert.Nil(t, result.SyntaxTree, msg)
+		return
+	}
+	require.NotNil(t, result.SyntaxTree, msg)
+	assert.Equal(t, string(result.src), result.SyntaxTree.String(), msg)
+	assert.Equal(t, full.SyntaxTree.Root.Tokens(), result.SyntaxTree.Root.Tokens(), msg)
+}
+
+func replaceEdit(src, old, new string) TextEdit {
+	start := strings.Index(src, old)
+	return TextEdit{Start: start, End: start + len(old), Text: new}
+}
+
+func TestReparse(t *testing.T) {
+	edits := []struct {
+		old, new string
+	}{
+		{`"./b"`, `"./bb"`},
+		{"  secrets = [\"TOKEN\"]\n", "  secrets = [\"TOKEN\"]\n  env = {\n    A = \"1\"\n  }\n"},
+		{`needs = ["a"]`, `needs = ["a", "z"]`},
+		{`uses = "./c" }`, `uses = "./c" needs = "c" }`},
+		{`color = "blue"`, ``},
+		{`uses = "./a"`, `uses = "./a`},
+		{`uses = "./a`, `uses = "./a"`},
+		{"}\n\naction \"a\"", "}\naction \"a\""},
+		{`action "b" {`, `action "a" {`},
+		{`"./bb"`, `"./b"`},
+	}
+
+	result, err := ParseWithResult(strings.NewReader(reparseSource), WithNativeParser())
+	require.NoError(t, err)
+	src := reparseSource
+	for _, e := range edits {
+		edit := replaceEdit(src, e.old, e.new)
+		require.True(t, edit.Start >= 0, e.old)
+		result, err = Reparse(result, edit)
+		require.NoError(t, err)
+		src = strings.Replace(src, e.old, e.new, 1)
+		assert.Equal(t, src, string(result.src))
+		assertSameAsFullParse(t, result, e.old+" => "+e.new)
+	}
+}
+
+func TestReparseReusesBlocks(t *testing.T) {
+	result, err := ParseWithResult(strings.NewReader(reparseSource), WithNativeParser())
+	require.NoError(t, err)
+	a := result.Configuration.Actions[0]
+	b := result.Configuration.Actions[1]
+
+	result, err = Reparse(result, replaceEdit(reparseSource, `"./b"`, `"./b2"`))
+	require.NoError(t, err)
+	assert.True(t, a == result.Configuration.Actions[0], "action a should be reused")
+	assert.False(t, b == result.Configuration.Actions[1], "action b should be parsed again")
+	assert.True(t, result.SyntaxTree.Action("a").Action == a)
+}
+
+func TestReparseReusesChecks(t *testing.T) {
+	// The checks of a reused block are not run again, but those of the
+	// edited block, and those across blocks, are.
+	src := `workflow "w" {
+  on = "push"
+  resolves = ["a", "b"]
+}
+
+action "a" {
+  uses = "./a"
+  secrets = ["GITHUB_A"]
+}
+
+action "b" {
+  uses = "./b"
+}
+`
+	result, err := ParseWithResult(strings.NewReader(src), WithNativeParser(), WithMaxSecrets(1))
+	require.NoError(t, err)
+	require.Equal(t, []string{"8:13 1 WF207 Environment variables and secrets beginning with `GITHUB_' are reserved"}, describeErrors(result.Errors))
+	result.parser.blocks[1].checkErrors[0].message = "cached"
+
+	src = strings.Replace(src, `uses = "./b"`, `secrets = ["B"]`, 1)
+	result, err = Reparse(result, replaceEdit(string(result.src), `uses = "./b"`, `secrets = ["B"]`))
+	require.NoError(t, err)
+	assert.Equal(t, src, string(result.src))
+	assert.Equal(t, []string{
+		"8:13 1 WF207 cached",
+		"11:12 2 WF200 Action `b' must have a `uses' attribute",
+		"12:13 2 WF206 All actions combined must not have more than 1 unique secrets",
+	}, describeErrors(result.Errors))
+}
+
+func TestReparseWholeFile(t *testing.T) {
+	// Edits that span blocks, or results from the HCL parser, are parsed
+	// from scratch.
+	result, err := ParseWithResult(strings.NewReader(reparseSource), WithNativeParser())
+	require.NoError(t, err)
+	a := result.Configuration.Actions[0]
+	result, err = Reparse(result, replaceEdit(reparseSource, "]\n}\n\naction \"a\" {", "]\n}\naction \"a2\" {"))
+	require.NoError(t, err)
+	assert.False(t, a == result.Configuration.Actions[0])
+	assertSameAsFullParse(t, result, "across blocks")
+
+	result, err = ParseWithResult(strings.NewReader(reparseSource))
+	require.NoError(t, err)
+	result, err = Reparse(result, replaceEdit(reparseSource, `"./b"`, `"./b2"`))
+	require.NoError(t, err)
+	assert.Nil(t, result.SyntaxTree)
+	assertSameAsFullParse(t, result, "HCL parser")
+
+	_, err = Reparse(result, TextEdit{Start: 10, End: 5})
+	assert.Error(t, err)
+	_, err = Reparse(result, TextEdit{Start: 0, End: len(result.src) + 1})
+	assert.Error(t, err)
+}
+
+func TestReparseTemplates(t *testing.T) {
+	// Actions are expanded from templates after the blocks are parsed, so
+	// an edit to a template reaches the actions that extend it, and the
+	// reused blocks keep the actions as written.
+	src := "version = 4\n\ntemplate \"t\" {\n  uses = \"./t\"\n}\n\naction \"a\" {\n  extends = \"t\"\n}\n"
+	result, err := ParseWithResult(strings.NewReader(src), WithNativeParser())
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+
+	for _, edit := range []struct{ old, new string }{
+		{`"./t"`, `"./t2"`},
+		{`"./t2"`, `""`},
+		{`extends = "t"`, `extends = "t" runs = "x"`},
+	} {
+		result, err = Reparse(result, replaceEdit(string(result.src), edit.old, edit.new))
+		require.NoError(t, err)
+		assertSameAsFullParse(t, result, edit.new)
+		assert.True(t, result.SyntaxTree.Action("a").Action == result.Configuration.Actions[0], edit.new)
+	}
+	assert.Equal(t, []string{"t"}, result.parser.blocks[2].action.Extends)
+	assert.Nil(t, result.parser.blocks[2].action.Uses, "the cached block holds the action as written")
+}
+
+func TestReparseMatrix(t *testing.T) {
+	// Actions that need an action with a matrix are copied when the
+	// matrix is expanded, so the reused blocks keep `needs' as written.
+	src := "version = 6\n\naction \"a\" {\n  uses = \"./a\"\n  matrix = { X = [\"1\", \"2\"] }\n}\n\naction \"b\" {\n  uses = \"./b\"\n  needs = \"a\"\n}\n"
+	result, err := ParseWithResult(strings.NewReader(src), WithNativeParser())
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+
+	for _, edit := range []struct{ old, new string }{
+		{`"2"]`, `"2", "3"]`},
+		{`X = [`, `Y = [`},
+	} {
+		result, err = Reparse(result, replaceEdit(string(result.src), edit.old, edit.new))
+		require.NoError(t, err)
+		assertSameAsFullParse(t, result, edit.new)
+	}
+	assert.Equal(t, []string{"a (Y=1)", "a (Y=2)", "a (Y=3)"}, result.Configuration.GetAction("b").Needs)
+	assert.Equal(t, []string{"a"}, result.parser.blocks[2].action.Needs)
+	assert.Equal(t, map[string][]string{"Y": {"1", "2", "3"}}, result.SyntaxTree.Action("a").Action.Matrix)
+}
+
+func TestReparseLocals(t *testing.T) {
+	// Blocks use the values of locals, so editing a `locals' block parses
+	// the whole file again.
+	src := "version = 9\n\nlocals {\n  dir = \"./a\"\n}\n\naction \"a\" {\n  uses = \"${local.dir}\"\n}\n"
+	result, err := ParseWithResult(strings.NewReader(src), WithNativeParser())
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+
+	for _, edit := range []struct{ old, new string }{
+		{`"./a"`, `"./b"`},
+		{`dir =`, `path =`},
+	} {
+		result, err = Reparse(result, replaceEdit(string(result.src), edit.old, edit.new))
+		require.NoError(t, err)
+		assertSameAsFullParse(t, result, edit.new)
+	}
+	assert.Equal(t, []string{
+		"4:10 1 WF601 Local `path' is never used",
+		"8:10 2 WF600 Undefined local `dir'",
+		"8:10 2 WF201 The `uses' attribute must be a path, a Docker image, or owner/repo@ref",
+	}, describeErrors(result.Errors))
+}
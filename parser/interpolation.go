@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/model"
+)
+
+// runtimeVariables are the environment variables that Actions sets in
+// every container, in addition to those named in `env' and `secrets'.
+// GITHUB_TOKEN is deliberately absent: it is only set if the action lists
+// it as a secret.
+var runtimeVariables = map[string]bool{
+	"GITHUB_ACTION":     true,
+	"GITHUB_ACTOR":      true,
+	"GITHUB_EVENT_NAME": true,
+	"GITHUB_EVENT_PATH": true,
+	"GITHUB_REF":        true,
+	"GITHUB_REPOSITORY": true,
+	"GITHUB_SHA":        true,
+	"GITHUB_WORKFLOW":   true,
+	"GITHUB_WORKSPACE":  true,
+	"HOME":              true,
+	"PATH":              true,
+}
+
+// variableRef is a shell-style reference to a variable in a string.
+type variableRef struct {
+	Name string
+
+	// HasDefault is true for forms like `${VAR:-default}' that expand to
+	// something even if VAR is unset.
+	HasDefault bool
+}
+
+// findVariableRefs returns the `$VAR' and `${VAR}' references in a
+// string, in order.  `$$' and `\$' are not references.
+func findVariableRefs(str string) []variableRef {
+	var ret []variableRef
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+			continue
+		case '$':
+		default:
+			continue
+		}
+
+		if i+1 < len(str) && str[i+1] == '$' {
+			i++
+			continue
+		}
+
+		braced := i+1 < len(str) && str[i+1] == '{'
+		start := i + 1
+		if braced {
+			start++
+		}
+		end := start
+		for end < len(str) && isVariableChar(str[end], end == start) {
+			end++
+		}
+		if end == start {
+			continue
+		}
+
+		ref := variableRef{Name: str[start:end]}
+		if braced && end < len(str) && str[end] != '}' {
+			rest := str[end:]
+			ref.HasDefault = hasAnyPrefix(rest, ":-", "-", ":=", "=")
+		}
+		ret = append(ret, ref)
+		i = end - 1
+	}
+	return ret
+}
+
+func isVariableChar(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
+
+func hasAnyPrefix(str string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(str, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInterpolation warns about variable references in `runs', `args'
+// and `env' values that will not expand to anything, because the
+// variable is not in the action's `env' or `secrets', or the `env' of a
+// workflow that resolves it, and is not set by the Actions runtime.  It
+// also warns about secrets referenced in `runs' or `args', because the
+// expanded command line, secret included, is visible in the process
+// list.
+func (p *Parser) checkInterpolation() {
+	inherited := make(map[*model.Action][]string)
+	config := &model.Configuration{Actions: p.actions}
+	for _, workflow := range p.workflows {
+		if len(workflow.Env) == 0 {
+			continue
+		}
+		for _, action := range config.ResolvedActions(workflow) {
+			for name := range workflow.Env {
+				inherited[action] = append(inherited[action], name)
+			}
+		}
+	}
+
+	for _, action := range p.actions {
+		defined := make(map[string]bool, len(action.Env)+len(action.Secrets))
+		for name := range action.Env {
+			defined[name] = true
+		}
+		for _, name := range inherited[action] {
+			defined[name] = true
+		}
+		secrets := make(map[string]bool, len(action.Secrets))
+		for _, name := range action.Secrets {
+			defined[name] = true
+			secrets[name] = true
+		}
+
+		p.checkCommandInterpolation(action, "runs", action.Runs, p.posMap[&action.Runs], defined, secrets)
+		p.checkCommandInterpolation(action, "args", action.Args, p.posMap[&action.Args], defined, secrets)
+
+		keys := make([]string, 0, len(action.Env))
+		for key := range action.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p.checkUndefinedVariables(action, "env", action.Env[key], p.posMap[&action.Env], defined)
+		}
+	}
+}
+
+func (p *Parser) checkCommandInterpolation(action *model.Action, name string, cmd model.Command, node ast.Node, defined, secrets map[string]bool) {
+	if cmd == nil {
+		return
+	}
+	for _, str := range cmd.Split() {
+		p.checkUndefinedVariables(action, name, str, node, defined)
+		for _, ref := range findVariableRefs(str) {
+			if secrets[ref.Name] {
+				p.addWarning(node, CodeSecretInCommand, "Secret `%s' is interpolated into `%s' of action `%s', which exposes it in the process list; read it from the environment instead", ref.Name, name, action.Identifier)
+			}
+		}
+	}
+}
+
+func (p *Parser) checkUndefinedVariables(action *model.Action, name, str string, node ast.Node, defined map[string]bool) {
+	for _, ref := range findVariableRefs(str) {
+		if defined[ref.Name] || runtimeVariables[ref.Name] || ref.HasDefault {
+			continue
+		}
+		p.addWarning(node, CodeUndefinedVariable, "Variable `%s' in `%s' of action `%s' is not defined in its `env' or `secrets'", ref.Name, name, action.Identifier)
+	}
+}
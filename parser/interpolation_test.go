@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindVariableRefs(t *testing.T) {
+	cases := []struct {
+		str      string
+		expected []variableRef
+	}{
+		{str: "echo hello", expected: nil},
+		{str: "$FOO", expected: []variableRef{{Name: "FOO"}}},
+		{str: "${FOO}bar $BAR_2", expected: []variableRef{{Name: "FOO"}, {Name: "BAR_2"}}},
+		{str: "${FOO:-x} ${BAR-y} ${BAZ#*/}", expected: []variableRef{{Name: "FOO", HasDefault: true}, {Name: "BAR", HasDefault: true}, {Name: "BAZ"}}},
+		{str: `$$ \$FOO $1 $@ $`, expected: nil},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, findVariableRefs(tc.str), tc.str)
+	}
+}
+
+func TestInterpolationAnalysis(t *testing.T) {
+	src := `
+action "a" {
+	uses = "./x"
+	runs = "echo $GITHUB_SHA $HOME ${value}"
+	args = ["--token", "$TOKEN", "$FOO"]
+	env = {
+		FOO = "foo"
+		BAR = "${FOO}/${MISSING}"
+	}
+	secrets = ["TOKEN"]
+}
+`
+	workflow, err := parseString(src)
+	assertParseSuccess(t, err, 1, 0, workflow)
+
+	workflow, err = parseString(src, WithInterpolationAnalysis())
+	assertParseError(t, err, 1, 0, workflow,
+		"line 4: variable `value' in `runs' of action `a' is not defined",
+		"line 5: secret `token' is interpolated into `args' of action `a'",
+		"line 6: variable `missing' in `env' of action `a' is not defined",
+	)
+}
+
+func TestInterpolationGitHubToken(t *testing.T) {
+	workflow, err := parseString(`action "a" { uses = "./x" runs = "sh -c 'echo $GITHUB_TOKEN'" }`, WithInterpolationAnalysis())
+	assertParseError(t, err, 1, 0, workflow, "variable `github_token' in `runs' of action `a' is not defined")
+}
+
+func TestInterpolationWorkflowEnv(t *testing.T) {
+	src := `version = 1
+workflow "w" {
+	on = "push"
+	resolves = ["a"]
+	env = { REGISTRY = "gcr.io" }
+}
+action "a" { uses = "./x" needs = "b" }
+action "b" { uses = "./x" runs = "push $REGISTRY/image" }
+action "c" { uses = "./x" runs = "push $REGISTRY/image" }
+`
+	workflow, err := parseString(src, WithInterpolationAnalysis())
+	assertParseError(t, err, 3, 1, workflow,
+		"line 9: variable `registry' in `runs' of action `c' is not defined",
+	)
+}
@@ -0,0 +1,399 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+	"github.com/actions/workflow-parser/model"
+)
+
+// ParseJSON parses a workflow file written in the JSON flavor of the
+// language, such as main.workflow.json, and validates it the same way as
+// ParseWithResult.  Diagnostics have the line and column in the JSON.
+//
+// The file is an object.  `version' and `include' are members of it, as
+// they are attributes in HCL, and so is `locals', whose value is the
+// body of the block.  Each other member, such as `action' or `workflow',
+// is an object whose members are blocks of that type, by name:
+//
+//	{
+//	  "version": 8,
+//	  "workflow": {"CI": {"on": "push", "resolves": ["test"]}},
+//	  "action": {"test": {"uses": "docker://golang", "args": "go test"}}
+//	}
+//
+// As in HCL, `version' must come first.  Included files are .workflow
+// files, in HCL.  The grammar of WithStrictGrammar is that of HCL, so it
+// does not apply.
+func ParseJSON(reader io.Reader, options ...OptionFunc) (*ParseResult, error) {
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	root, pe := parseJSONSyntax(b)
+	if pe != nil {
+		return &ParseResult{
+			Configuration: &model.Configuration{},
+			Errors:        errorList{pe},
+			message:       "unable to parse",
+			src:           b,
+			options:       options,
+			json:          true,
+		}, nil
+	}
+
+	p := newParser(options...)
+	p.strictGrammar = false
+	p.jsonSrc = b
+	p.processFile(b, root, nil)
+	result := p.result(b, options)
+	result.json = true
+	return result, nil
+}
+
+// isJSONFile returns true if filename is a workflow file in the JSON
+// flavor, for ParseJSON.
+func isJSONFile(filename string) bool {
+	return strings.HasSuffix(filename, ".json")
+}
+
+// jsonParser builds, from the JSON flavor, the AST that the HCL parser
+// builds from the equivalent .workflow file, so that the rest of the
+// parser cannot tell them apart.
+type jsonParser struct {
+	scanner *jsonScanner
+	tok     jsonToken
+}
+
+// parseJSONSyntax parses the JSON flavor of a workflow file into an AST.
+// A syntax error is returned as a FATAL *ParseError.
+func parseJSONSyntax(src []byte) (root *ast.File, pe *ParseError) {
+	j := &jsonParser{scanner: &jsonScanner{src: src, line: 1, column: 1}}
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if pe, ok = r.(*ParseError); !ok {
+				panic(r)
+			}
+			root = nil
+		}
+	}()
+
+	j.next()
+	list := j.file()
+	j.expect(jsonEOF, "the end of the file")
+	return &ast.File{Node: list}, nil
+}
+
+// file parses the object that is the whole file.
+func (j *jsonParser) file() *ast.ObjectList {
+	list := &ast.ObjectList{}
+	j.members(func(key jsonToken, colon token.Pos) {
+		name := j.astToken(key).Value().(string)
+		switch {
+		case name == "locals":
+			list.Add(&ast.ObjectItem{Keys: []*ast.ObjectKey{j.key(key, name)}, Val: j.object("the body of `locals'")})
+		case j.tok.Type != jsonLBrace:
+			list.Add(&ast.ObjectItem{Keys: []*ast.ObjectKey{j.key(key, name)}, Assign: colon, Val: j.value()})
+		default:
+			// Each member is a block of the type.
+			j.members(func(id jsonToken, _ token.Pos) {
+				keys := []*ast.ObjectKey{j.key(id, name), {Token: j.astToken(id)}}
+				list.Add(&ast.ObjectItem{Keys: keys, Val: j.object(fmt.Sprintf("the body of `%s' %s", name, id.Text))})
+			})
+		}
+	})
+	return list
+}
+
+// key returns a key for the type of a block, or the name of an
+// attribute, as the HCL parser would have read it from an identifier.
+// It is at the position of tok, which for a block is its name.
+func (j *jsonParser) key(tok jsonToken, name string) *ast.ObjectKey {
+	return &ast.ObjectKey{Token: token.Token{Type: token.IDENT, Pos: tok.Pos, Text: name}}
+}
+
+// object parses an object into the body of a block or a map, with an
+// attribute for each member.
+func (j *jsonParser) object(what string) *ast.ObjectType {
+	if j.tok.Type != jsonLBrace {
+		j.fail(j.tok, "Expected an object for %s, got %s", what, describeJSON(j.tok))
+	}
+	ret := &ast.ObjectType{Lbrace: j.tok.Pos, List: &ast.ObjectList{}}
+	ret.Rbrace = j.members(func(key jsonToken, colon token.Pos) {
+		ret.List.Add(&ast.ObjectItem{
+			Keys:   []*ast.ObjectKey{j.key(key, j.astToken(key).Value().(string))},
+			Assign: colon,
+			Val:    j.value(),
+		})
+	})
+	return ret
+}
+
+// members parses the members of an object, calling member with each key
+// and the position of its colon, when the value is the next token.  It
+// returns the position of the closing brace.
+func (j *jsonParser) members(member func(key jsonToken, colon token.Pos)) token.Pos {
+	j.expect(jsonLBrace, "`{'")
+	for j.tok.Type != jsonRBrace {
+		key := j.expect(jsonString, "a string for the name of a member")
+		colon := j.expect(jsonColon, "`:' after the name of a member")
+		member(key, colon.Pos)
+		if j.tok.Type != jsonRBrace {
+			j.expect(jsonComma, "`,' or `}'")
+			if j.tok.Type == jsonRBrace {
+				j.fail(j.tok, "Expected a string for the name of a member, got `}'")
+			}
+		}
+	}
+	return j.expect(jsonRBrace, "`}'").Pos
+}
+
+// value parses the value of an attribute.
+func (j *jsonParser) value() ast.Node {
+	switch j.tok.Type {
+	case jsonLBrace:
+		return j.object("a map")
+	case jsonLBrack:
+		ret := &ast.ListType{Lbrack: j.tok.Pos}
+		j.next()
+		for j.tok.Type != jsonRBrack {
+			if len(ret.List) > 0 {
+				j.expect(jsonComma, "`,' or `]'")
+			}
+			ret.Add(j.value())
+		}
+		ret.Rbrack = j.tok.Pos
+		j.next()
+		return ret
+	case jsonString, jsonNumber, jsonFloat, jsonBool:
+		ret := &ast.LiteralType{Token: j.astToken(j.tok)}
+		j.next()
+		return ret
+	case jsonNull:
+		// Like the HCL parser, null is an empty string.
+		ret := &ast.LiteralType{Token: token.Token{Type: token.STRING, Pos: j.tok.Pos, Text: `""`, JSON: true}}
+		j.next()
+		return ret
+	}
+	j.fail(j.tok, "Expected a value, got %s", describeJSON(j.tok))
+	return nil
+}
+
+// astToken converts a literal to the token of the AST.  A string is
+// decoded as JSON, and quoted again the way the token's Value expects.
+func (j *jsonParser) astToken(tok jsonToken) token.Token {
+	switch tok.Type {
+	case jsonString:
+		var str string
+		if err := json.Unmarshal([]byte(tok.Text), &str); err != nil {
+			j.fail(tok, "Invalid JSON: invalid string %s", tok.Text)
+		}
+		return token.Token{Type: token.STRING, Pos: tok.Pos, Text: strconv.Quote(str), JSON: true}
+	case jsonNumber:
+		return token.Token{Type: token.NUMBER, Pos: tok.Pos, Text: tok.Text}
+	case jsonFloat:
+		return token.Token{Type: token.FLOAT, Pos: tok.Pos, Text: tok.Text}
+	default:
+		return token.Token{Type: token.BOOL, Pos: tok.Pos, Text: tok.Text}
+	}
+}
+
+// next reads the next token.
+func (j *jsonParser) next() {
+	j.tok = j.scanner.scan()
+}
+
+// expect reads a token of type t, described by what, and returns it.
+func (j *jsonParser) expect(t jsonTokenType, what string) jsonToken {
+	tok := j.tok
+	if tok.Type != t {
+		j.fail(tok, "Expected %s, got %s", what, describeJSON(tok))
+	}
+	j.next()
+	return tok
+}
+
+func (j *jsonParser) fail(tok jsonToken, format string, a ...interface{}) {
+	panic(newFatal(ErrorPos{Line: tok.Pos.Line, Column: tok.Pos.Column}, CodeSyntax, format, a...))
+}
+
+// describeJSON describes a token for a syntax error.
+func describeJSON(tok jsonToken) string {
+	switch tok.Type {
+	case jsonEOF:
+		return "end of file"
+	case jsonString:
+		return fmt.Sprintf("string %s", tok.Text)
+	case jsonNumber, jsonFloat:
+		return fmt.Sprintf("number %s", tok.Text)
+	case jsonBool:
+		return fmt.Sprintf("boolean %s", tok.Text)
+	default:
+		return fmt.Sprintf("`%s'", tok.Text)
+	}
+}
+
+type jsonTokenType int
+
+const (
+	jsonEOF jsonTokenType = iota
+	jsonLBrace
+	jsonRBrace
+	jsonLBrack
+	jsonRBrack
+	jsonColon
+	jsonComma
+	jsonString
+	jsonNumber // an integer
+	jsonFloat  // a number with a fraction or an exponent
+	jsonBool
+	jsonNull
+)
+
+// jsonToken is a token of the JSON flavor.  Text is the token as it is
+// in the source, quotes and escapes included.
+type jsonToken struct {
+	Type jsonTokenType
+	Pos  token.Pos
+	Text string
+}
+
+// jsonNumberRe matches a number, as RFC 8259 defines it.
+var jsonNumberRe = regexp.MustCompile(`\A-?(?:0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?`)
+
+// jsonScanner splits the JSON flavor into tokens.  Columns count
+// characters, as those of the HCL scanner do.
+type jsonScanner struct {
+	src          []byte
+	offset       int
+	line, column int
+}
+
+// scan returns the next token.  It panics with a FATAL *ParseError if
+// the source is not valid JSON.
+func (s *jsonScanner) scan() jsonToken {
+	for s.offset < len(s.src) && strings.IndexByte(" \t\r\n", s.src[s.offset]) >= 0 {
+		s.advance(1)
+	}
+	tok := jsonToken{Pos: token.Pos{Offset: s.offset, Line: s.line, Column: s.column}}
+	if s.offset == len(s.src) {
+		return tok
+	}
+
+	n := 1
+	switch ch := s.src[s.offset]; {
+	case ch == '{':
+		tok.Type = jsonLBrace
+	case ch == '}':
+		tok.Type = jsonRBrace
+	case ch == '[':
+		tok.Type = jsonLBrack
+	case ch == ']':
+		tok.Type = jsonRBrack
+	case ch == ':':
+		tok.Type = jsonColon
+	case ch == ',':
+		tok.Type = jsonComma
+	case ch == '"':
+		tok.Type = jsonString
+		n = s.stringLength()
+	case ch == '-' || ch >= '0' && ch <= '9':
+		m := jsonNumberRe.FindSubmatchIndex(s.src[s.offset:])
+		if m == nil {
+			s.fail(0, "Invalid JSON: invalid number")
+		}
+		n = m[1]
+		tok.Type = jsonNumber
+		if m[2] >= 0 || m[4] >= 0 {
+			tok.Type = jsonFloat
+		} else if _, err := strconv.ParseInt(string(s.src[s.offset:s.offset+n]), 10, 64); err != nil {
+			// Too big for an integer.
+			tok.Type = jsonFloat
+		}
+	case ch >= 'a' && ch <= 'z':
+		for s.offset+n < len(s.src) && isJSONWordByte(s.src[s.offset+n]) {
+			n++
+		}
+		switch word := string(s.src[s.offset : s.offset+n]); word {
+		case "true", "false":
+			tok.Type = jsonBool
+		case "null":
+			tok.Type = jsonNull
+		default:
+			s.fail(0, "Invalid JSON: unexpected `%s'", word)
+		}
+	default:
+		r, _ := utf8.DecodeRune(s.src[s.offset:])
+		s.fail(0, "Invalid JSON: unexpected character `%c'", r)
+	}
+	tok.Text = string(s.src[s.offset : s.offset+n])
+	s.advance(n)
+	return tok
+}
+
+// stringLength returns the length of the string that starts at the
+// current offset, quotes included, checking its escapes.
+func (s *jsonScanner) stringLength() int {
+	for i := s.offset + 1; i < len(s.src); i++ {
+		switch ch := s.src[i]; {
+		case ch == '"':
+			return i + 1 - s.offset
+		case ch == '\\':
+			i++
+			switch {
+			case i < len(s.src) && strings.IndexByte(`"\/bfnrt`, s.src[i]) >= 0:
+			case i+4 < len(s.src) && s.src[i] == 'u' && isHex(s.src[i+1:i+5]):
+				i += 4
+			default:
+				s.fail(i-1-s.offset, "Invalid JSON: invalid escape in string")
+			}
+		case ch < 0x20:
+			s.fail(i-s.offset, "Invalid JSON: control character in string")
+		}
+	}
+	s.fail(0, "Invalid JSON: string not terminated")
+	return 0
+}
+
+// advance moves the offset n bytes on.
+func (s *jsonScanner) advance(n int) {
+	for _, r := range string(s.src[s.offset : s.offset+n]) {
+		if r == '\n' {
+			s.line++
+			s.column = 1
+		} else {
+			s.column++
+		}
+	}
+	s.offset += n
+}
+
+// fail reports an error at n bytes after the current offset, on the
+// same line.
+func (s *jsonScanner) fail(n int, format string, a ...interface{}) {
+	pos := ErrorPos{Line: s.line, Column: s.column + utf8.RuneCount(s.src[s.offset:s.offset+n])}
+	panic(newFatal(pos, CodeSyntax, format, a...))
+}
+
+func isJSONWordByte(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_'
+}
+
+func isHex(b []byte) bool {
+	for _, ch := range b {
+		if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package parser
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/actions/workflow-parser/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSON(t *testing.T) {
+	hcl := `version = 9
+
+locals {
+  image = "docker://golang:1.12"
+}
+
+workflow "CI" {
+  on = ["push", "schedule(0 4 * * *)"]
+  resolves = ["deploy"]
+  env = { STAGE = "prod" }
+  branches = "main"
+}
+
+template "go" {
+  uses = "${local.image}"
+  timeout = "10m"
+}
+
+action "test" {
+  extends = "go"
+  runs = ["go", "test"]
+  matrix = { GO = ["1.11", "1.12"] }
+  retries = 2
+}
+
+action "deploy" {
+  uses = "./deploy"
+  needs = "test"
+  args = "--to \"prod\""
+  secrets = ["TOKEN"]
+  if = "event.ref == 'refs/heads/main'"
+}
+`
+	jsonSrc := `{
+  "version": 9,
+  "locals": {"image": "docker://golang:1.12"},
+  "workflow": {
+    "CI": {
+      "on": ["push", "schedule(0 4 * * *)"],
+      "resolves": ["deploy"],
+      "env": {"STAGE": "prod"},
+      "branches": "main"
+    }
+  },
+  "template": {
+    "go": {"uses": "${local.image}", "timeout": "10m"}
+  },
+  "action": {
+    "test": {
+      "extends": "go",
+      "runs": ["go", "test"],
+      "matrix": {"GO": ["1.11", "1.12"]},
+      "retries": 2
+    },
+    "deploy": {
+      "uses": "./deploy",
+      "needs": "test",
+      "args": "--to \"prod\"",
+      "secrets": ["TOKEN"],
+      "if": "event.ref == 'refs/heads/main'"
+    }
+  }
+}
+`
+	expected, err := ParseWithResult(strings.NewReader(hcl))
+	require.NoError(t, err)
+	require.Empty(t, expected.Errors)
+	result, err := ParseJSON(strings.NewReader(jsonSrc))
+	require.NoError(t, err)
+	assert.Empty(t, describeErrors(result.Errors))
+	assert.Equal(t, expected.Configuration, result.Configuration)
+}
+
+func TestParseJSONDiagnostics(t *testing.T) {
+	result, err := ParseJSON(strings.NewReader(`{
+  "version": 8,
+  "workflow": {
+    "CI": {"on": "push", "resolves": ["a", "b"]}
+  },
+  "action": {
+    "a": {"uses": "./a", "needs": "missing", "colour": "blue"},
+    "b": {"runs": 1, "if": "event.x =="}
+  },
+  "block": {"x": {}}
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"7:56 1 WF202 Unknown action attribute `colour'",
+		"7:35 2 WF205 Action `a' needs nonexistent action `missing'",
+		"8:19 2 WF106 Expected string, got number",
+		"8:19 2 WF204 The `runs' attribute must be a string or a list",
+		"8:39 2 WF219 Invalid `if' in action `b': unexpected end of condition",
+		"8:10 2 WF200 Action `b' must have a `uses' attribute",
+		"10:18 2 WF102 Invalid toplevel keyword, `block'",
+	}, describeErrors(result.Errors))
+}
+
+func TestParseJSONSyntaxErrors(t *testing.T) {
+	cases := []struct {
+		src      string
+		expected string
+	}{
+		{``, "1:1 3 WF100 Expected `{', got end of file"},
+		{`[]`, "1:1 3 WF100 Expected `{', got `['"},
+		{`{"version": 8,}`, "1:15 3 WF100 Expected a string for the name of a member, got `}'"},
+		{`{"version" 8}`, "1:12 3 WF100 Expected `:' after the name of a member, got number 8"},
+		{`{"action": {"a": "b"}}`, "1:18 3 WF100 Expected an object for the body of `action' \"a\", got string \"b\""},
+		{`{"action": {"a": {"args": [1 2]}}}`, "1:30 3 WF100 Expected `,' or `]', got number 2"},
+		{`{"version": 8} {}`, "1:16 3 WF100 Expected the end of the file, got `{'"},
+		{`{"version": nope}`, "1:13 3 WF100 Invalid JSON: unexpected `nope'"},
+		{`{"version": 08}`, "1:14 3 WF100 Expected `,' or `}', got number 8"},
+		{`{"version": 'a'}`, "1:13 3 WF100 Invalid JSON: unexpected character `''"},
+		{`{"a": "\UFFFFFFFF"}`, "1:8 3 WF100 Invalid JSON: invalid escape in string"},
+		{`{"a": "é\x41"}`, "1:9 3 WF100 Invalid JSON: invalid escape in string"},
+		{`{"a": "\u12"}`, "1:8 3 WF100 Invalid JSON: invalid escape in string"},
+		{"{\"a\": \"a\tb\"}", "1:9 3 WF100 Invalid JSON: control character in string"},
+		{`{"a": "b}`, "1:7 3 WF100 Invalid JSON: string not terminated"},
+	}
+	for _, c := range cases {
+		result, err := ParseJSON(strings.NewReader(c.src))
+		require.NoError(t, err, c.src)
+		assert.Equal(t, []string{c.expected}, describeErrors(result.Errors), c.src)
+	}
+}
+
+// TestParseJSONStrings checks that strings are decoded as JSON, whatever
+// the escapes of Go or HCL.
+func TestParseJSONStrings(t *testing.T) {
+	result, err := ParseJSON(strings.NewReader(`{
+  "action": {
+    "a": {
+      "uses": "docker:\/\/alpine",
+      "args": ["\u00e9\ud83d\ude00", "${", "\"\\\b\f\n\r\t"],
+      "env": {"A": "\u0000"}
+    }
+  }
+}`))
+	require.NoError(t, err)
+	assert.Empty(t, describeErrors(result.Errors))
+	a := result.Configuration.GetAction("a")
+	require.NotNil(t, a)
+	assert.Equal(t, &model.UsesDockerImage{Image: "alpine"}, a.Uses)
+	assert.Equal(t, &model.ListCommand{Values: []string{"é😀", "${", "\"\\\b\f\n\r\t"}}, a.Args)
+	assert.Equal(t, map[string]string{"A": "\x00"}, a.Env)
+
+	// Errors in conditions are at the column in the JSON, escapes
+	// included.
+	result, err = ParseJSON(strings.NewReader(`{"version": 8, "action": {"a": {"uses": "./a", "if": "event.x == '\u00e9\/\ud83d\ude00' && 'x'"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1:92 2 WF219 Invalid `if' in action `a': `&&' needs booleans, got string"}, describeErrors(result.Errors))
+}
+
+func TestReparseJSON(t *testing.T) {
+	src := `{"workflow": {"w": {"on": "push", "resolves": "a"}}, "action": {"a": {"uses": "./a"}}}`
+	result, err := ParseJSON(strings.NewReader(src))
+	require.NoError(t, err)
+	result, err = Reparse(result, replaceEdit(src, `"./a"`, `"./b"`))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, "./b", result.Configuration.GetAction("a").Uses.String())
+}
+
+func TestParseFilesJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workflow-parser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "main.workflow.json")
+	src := `{"workflow": {"w": {"on": "push", "resolves": "a"}}, "action": {"a": {"uses": "./a"}}}`
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+
+	for _, options := range [][]OptionFunc{nil, {WithCache(NewLRUCache(10))}} {
+		results := ParseFiles([]string{filename}, options...)
+		require.Len(t, results, 1)
+		require.NoError(t, results[0].Err)
+		assert.Empty(t, results[0].Result.Errors)
+		assert.NotNil(t, results[0].Result.Configuration.GetAction("a"))
+	}
+}
+
+// TestJSONSchema checks that workflow.schema.json has the attributes that
+// the parser allows in each block.
+func TestJSONSchema(t *testing.T) {
+	b, err := ioutil.ReadFile("../workflow.schema.json")
+	require.NoError(t, err)
+	var schema struct {
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage
+		}
+	}
+	require.NoError(t, json.Unmarshal(b, &schema))
+
+	c := &grammarChecker{fileVersion: maxVersion}
+	for _, block := range []struct {
+		name       string
+		attributes map[string]func() bool
+	}{
+		{"workflow", c.workflowAttributes()},
+		{"action", c.actionAttributes()},
+	} {
+		var expected, actual []string
+		for name := range block.attributes {
+			expected = append(expected, name)
+		}
+		for name := range schema.Definitions[block.name].Properties {
+			actual = append(actual, name)
+		}
+		sort.Strings(expected)
+		sort.Strings(actual)
+		assert.Equal(t, expected, actual, block.name)
+	}
+}
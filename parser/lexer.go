@@ -0,0 +1,444 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/actions/workflow-parser/internal/token"
+)
+
+// TokenKind is the kind of a lexical token in a .workflow file.
+type TokenKind int
+
+const (
+	TokenEOF        TokenKind = iota
+	TokenIllegal              // anything the lexer could not make sense of
+	TokenWhitespace           // a run of spaces, tabs, and line breaks
+	TokenComment              // `# ...', `// ...', or `/* ... */'
+	TokenIdent                // a bare word, such as `action' or `uses'
+	TokenNumber               // an integer
+	TokenFloat                // a floating-point number
+	TokenBool                 // `true' or `false'
+	TokenString               // a double-quoted string, quotes included
+	TokenHeredoc              // `<<EOF' through the closing `EOF' line
+	TokenLBrace               // {
+	TokenRBrace               // }
+	TokenLBrack               // [
+	TokenRBrack               // ]
+	TokenAssign               // =
+	TokenComma                // ,
+)
+
+var tokenKindNames = [...]string{
+	TokenEOF:        "EOF",
+	TokenIllegal:    "Illegal",
+	TokenWhitespace: "Whitespace",
+	TokenComment:    "Comment",
+	TokenIdent:      "Ident",
+	TokenNumber:     "Number",
+	TokenFloat:      "Float",
+	TokenBool:       "Bool",
+	TokenString:     "String",
+	TokenHeredoc:    "Heredoc",
+	TokenLBrace:     "LBrace",
+	TokenRBrace:     "RBrace",
+	TokenLBrack:     "LBrack",
+	TokenRBrack:     "RBrack",
+	TokenAssign:     "Assign",
+	TokenComma:      "Comma",
+}
+
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// lexToken is a token as the lexer found it.  Every byte of the source
+// belongs to exactly one token, whitespace and comments included, so
+// concatenating the text of all tokens gives back the source.
+type lexToken struct {
+	kind TokenKind
+	text string
+
+	// pos is where the token starts.  Offset is in bytes; Column counts
+	// characters, starting at 1, like the HCL scanner does.
+	pos token.Pos
+
+	// err describes what is wrong with a TokenIllegal.
+	err string
+}
+
+// isTrivia returns true for tokens that do not affect the meaning of a
+// file.
+func (tok lexToken) isTrivia() bool {
+	return tok.kind == TokenWhitespace || tok.kind == TokenComment
+}
+
+var (
+	integerLiteralRe = regexp.MustCompile(`\A-?(?:0[xX][0-9a-fA-F]+|0[0-7]*|[1-9][0-9]*)\z`)
+	floatLiteralRe   = regexp.MustCompile(`\A-?(?:[0-9]+\.[0-9]*(?:[eE][-+]?[0-9]+)?|[0-9]+[eE][-+]?[0-9]+|\.[0-9]+(?:[eE][-+]?[0-9]+)?)\z`)
+)
+
+// lexer splits the source of a .workflow file into tokens.  Unlike the HCL
+// scanner, it does not stop at the first problem: it reports it as a
+// TokenIllegal and carries on after it.
+type lexer struct {
+	src    []byte
+	offset int
+	line   int
+	column int
+
+	// base is the offset of src in the file.
+	base int
+}
+
+// lex returns all the tokens in src, ending with a TokenEOF.
+func lex(src []byte) []lexToken {
+	return lexAt(src, token.Pos{Line: 1, Column: 1})
+}
+
+// lexAt returns all the tokens in src, which is part of a file starting
+// at the given position.
+func lexAt(src []byte, start token.Pos) []lexToken {
+	l := &lexer{src: src, line: start.Line, column: start.Column, base: start.Offset}
+	var tokens []lexToken
+	for {
+		tok := l.scan()
+		tokens = append(tokens, tok)
+		if tok.kind == TokenEOF {
+			return tokens
+		}
+	}
+}
+
+func (l *lexer) scan() lexToken {
+	start := l.offset
+	pos := token.Pos{Offset: l.base + l.offset, Line: l.line, Column: l.column}
+	kind, err := l.scanToken()
+	return lexToken{kind: kind, text: string(l.src[start:l.offset]), pos: pos, err: err}
+}
+
+// scanToken consumes one token and returns its kind, plus a description
+// of the problem for a TokenIllegal.
+func (l *lexer) scanToken() (TokenKind, string) {
+	ch := l.peek(0)
+	switch {
+	case ch == eofRune:
+		return TokenEOF, ""
+	case isSpace(ch):
+		for isSpace(l.peek(0)) {
+			l.advance()
+		}
+		return TokenWhitespace, ""
+	case ch == '#', ch == '/' && l.peek(1) == '/':
+		for l.peek(0) != '\n' && l.peek(0) != eofRune {
+			l.advance()
+		}
+		return TokenComment, ""
+	case ch == '/' && l.peek(1) == '*':
+		return l.scanBlockComment()
+	case isLetter(ch):
+		return l.scanIdent()
+	case isDecimal(ch), (ch == '-' || ch == '.') && isDecimal(l.peek(1)):
+		return l.scanNumber()
+	case ch == '"':
+		return l.scanString()
+	case ch == '<' && l.peek(1) == '<':
+		return l.scanHeredoc()
+	}
+
+	l.advance()
+	switch ch {
+	case '{':
+		return TokenLBrace, ""
+	case '}':
+		return TokenRBrace, ""
+	case '[':
+		return TokenLBrack, ""
+	case ']':
+		return TokenRBrack, ""
+	case '=':
+		return TokenAssign, ""
+	case ',':
+		return TokenComma, ""
+	case '/':
+		return TokenIllegal, "Unexpected `/'; comments start with `#' or `//'"
+	case utf8.RuneError:
+		return TokenIllegal, "Invalid UTF-8 encoding"
+	}
+	if unicode.IsPrint(ch) {
+		return TokenIllegal, fmt.Sprintf("Unexpected character `%c'", ch)
+	}
+	return TokenIllegal, fmt.Sprintf("Unexpected character %U", ch)
+}
+
+func (l *lexer) scanBlockComment() (TokenKind, string) {
+	l.advance()
+	l.advance()
+	for {
+		switch {
+		case l.peek(0) == eofRune:
+			return TokenIllegal, "Comment starting with `/*' is not terminated"
+		case l.peek(0) == '*' && l.peek(1) == '/':
+			l.advance()
+			l.advance()
+			return TokenComment, ""
+		}
+		l.advance()
+	}
+}
+
+// scanIdent scans a bare identifier, such as `action' or `uses'.  As in
+// HCL, identifiers may contain `-' and `.' after the first character.
+func (l *lexer) scanIdent() (TokenKind, string) {
+	start := l.offset
+	for ch := l.peek(0); isLetter(ch) || isDigit(ch) || ch == '-' || ch == '.'; ch = l.peek(0) {
+		l.advance()
+	}
+	switch string(l.src[start:l.offset]) {
+	case "true", "false":
+		return TokenBool, ""
+	}
+	return TokenIdent, ""
+}
+
+// scanNumber scans an integer (decimal, octal, or hexadecimal) or a
+// floating-point number.  Anything that runs on from a number, such as
+// the `abc' in `12abc', is part of the same, invalid, token.
+func (l *lexer) scanNumber() (TokenKind, string) {
+	start := l.offset
+	l.advance()
+	for {
+		ch := l.peek(0)
+		prev := l.src[l.offset-1]
+		hex := strings.HasPrefix(strings.TrimPrefix(string(l.src[start:l.offset]), "-"), "0x")
+		if isLetter(ch) || isDigit(ch) || ch == '.' ||
+			(ch == '-' || ch == '+') && (prev == 'e' || prev == 'E') && !hex {
+			l.advance()
+			continue
+		}
+		break
+	}
+
+	text := string(l.src[start:l.offset])
+	switch {
+	case integerLiteralRe.MatchString(text):
+		if problem := literalProblem(token.NUMBER, text); problem != "" {
+			return TokenIllegal, problem
+		}
+		return TokenNumber, ""
+	case floatLiteralRe.MatchString(text):
+		if problem := literalProblem(token.FLOAT, text); problem != "" {
+			return TokenIllegal, problem
+		}
+		return TokenFloat, ""
+	default:
+		return TokenIllegal, fmt.Sprintf("Invalid number `%s'", text)
+	}
+}
+
+// literalProblem describes what is wrong with the text of a STRING,
+// NUMBER, or FLOAT token whose value cannot be worked out, or returns ""
+// if there is nothing wrong with it.
+func literalProblem(t token.Type, text string) string {
+	var err error
+	switch t {
+	case token.STRING:
+		if _, err := token.Unquote(text); err != nil {
+			if strings.Contains(text, "${") {
+				return "String has a `${' without a matching `}'"
+			}
+			return fmt.Sprintf("Invalid string %s", text)
+		}
+		return ""
+	case token.NUMBER:
+		_, err = strconv.ParseInt(text, 0, 64)
+	case token.FLOAT:
+		_, err = strconv.ParseFloat(text, 64)
+	}
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, strconv.ErrRange):
+		return fmt.Sprintf("Number `%s' is out of range", text)
+	default:
+		return fmt.Sprintf("Invalid number `%s'", text)
+	}
+}
+
+// scanString scans a double-quoted string.  Escape sequences are those of
+// HCL; `${...}' sequences are kept as they are.
+func (l *lexer) scanString() (TokenKind, string) {
+	start := l.offset
+	l.advance()
+	var problem string
+	for {
+		ch := l.peek(0)
+		switch {
+		case ch == eofRune, ch == '\n', ch == '\r' && l.peek(1) == '\n':
+			return TokenIllegal, "String is not terminated; use a heredoc for text that spans lines"
+		case ch == '"':
+			l.advance()
+			if problem != "" {
+				return TokenIllegal, problem
+			}
+			if problem := literalProblem(token.STRING, string(l.src[start:l.offset])); problem != "" {
+				return TokenIllegal, problem
+			}
+			return TokenString, ""
+		case ch == '\\':
+			l.advance()
+			if p := l.scanEscape(); problem == "" {
+				problem = p
+			}
+			continue
+		case ch < ' ' && problem == "":
+			problem = fmt.Sprintf("Control character %U in string; use an escape sequence", ch)
+		}
+		l.advance()
+	}
+}
+
+// scanEscape scans the part of an escape sequence after the backslash.
+func (l *lexer) scanEscape() string {
+	ch := l.peek(0)
+	digits, base := 0, 0
+	switch ch {
+	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', '"':
+		l.advance()
+		return ""
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		digits, base = 3, 8
+	case 'x':
+		l.advance()
+		digits, base = 2, 16
+	case 'u':
+		l.advance()
+		digits, base = 4, 16
+	case 'U':
+		l.advance()
+		digits, base = 8, 16
+	case eofRune, '\n':
+		return "Invalid escape sequence `\\' at end of line"
+	default:
+		l.advance()
+		return fmt.Sprintf("Invalid escape sequence `\\%c'", ch)
+	}
+
+	for i := 0; i < digits; i++ {
+		if digitVal(l.peek(0)) >= base {
+			return fmt.Sprintf("Escape sequence `\\%c' needs %d digits", ch, digits)
+		}
+		l.advance()
+	}
+	return ""
+}
+
+// scanHeredoc scans a `<<ANCHOR' or `<<-ANCHOR' heredoc, up to and
+// including the line break after the closing anchor.  The closing anchor
+// may be indented, and may be the last thing in the file.
+func (l *lexer) scanHeredoc() (TokenKind, string) {
+	l.advance()
+	l.advance()
+	if l.peek(0) == '-' {
+		l.advance()
+	}
+	anchorStart := l.offset
+	for isLetter(l.peek(0)) || isDigit(l.peek(0)) {
+		l.advance()
+	}
+	anchor := string(l.src[anchorStart:l.offset])
+	if anchor == "" {
+		return TokenIllegal, "Heredoc needs an anchor, as in `<<EOF'"
+	}
+	if l.peek(0) == '\r' && l.peek(1) == '\n' {
+		l.advance()
+	}
+	if l.peek(0) != '\n' {
+		for l.peek(0) != '\n' && l.peek(0) != eofRune {
+			l.advance()
+		}
+		return TokenIllegal, fmt.Sprintf("Heredoc anchor `%s' must be followed by a line break", anchor)
+	}
+	l.advance()
+
+	for l.peek(0) != eofRune {
+		lineStart := l.offset
+		for l.peek(0) != '\n' && l.peek(0) != eofRune {
+			l.advance()
+		}
+		line := strings.TrimRight(string(l.src[lineStart:l.offset]), "\r")
+		if l.peek(0) == '\n' {
+			l.advance()
+		}
+		if strings.TrimLeft(line, " \t\v\f") == anchor {
+			return TokenHeredoc, ""
+		}
+	}
+	return TokenIllegal, fmt.Sprintf("Heredoc is not terminated; expected a line containing only `%s'", anchor)
+}
+
+const eofRune = -1
+
+// peek returns the character n characters ahead without consuming
+// anything, or eofRune at the end of the source.
+func (l *lexer) peek(n int) rune {
+	offset := l.offset
+	for ; n > 0 && offset < len(l.src); n-- {
+		_, size := utf8.DecodeRune(l.src[offset:])
+		offset += size
+	}
+	if offset >= len(l.src) {
+		return eofRune
+	}
+	ch, _ := utf8.DecodeRune(l.src[offset:])
+	return ch
+}
+
+// advance consumes one character, keeping track of the line and column.
+func (l *lexer) advance() {
+	ch, size := utf8.DecodeRune(l.src[l.offset:])
+	l.offset += size
+	if ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+}
+
+func isSpace(ch rune) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9' || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
+}
+
+func isDecimal(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func digitVal(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return int(ch - 'A' + 10)
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+)
+
+// localRefRe matches a reference to a local in a string, such as
+// `${local.registry}'.  Other `${...}' references, such as `${HOME}' in
+// `runs', are left alone.
+var localRefRe = regexp.MustCompile(`\$\{local\.([A-Za-z_][A-Za-z0-9_-]*)\}`)
+
+// isLocals returns true if item is a top-level `locals { ... }' block.
+func (p *Parser) isLocals(item *ast.ObjectItem) bool {
+	if len(item.Keys) != 1 || item.Assign.IsValid() {
+		return false
+	}
+	_, ok := item.Val.(*ast.ObjectType)
+	return ok && p.identString(item.Keys[0].Token) == "locals"
+}
+
+// parseLocals parses every top-level `locals' block among items, filling
+// in p.locals.  A local can use the locals defined before it, but not
+// itself or the ones after it.
+func (p *Parser) parseLocals(items []*ast.ObjectItem) {
+	p.locals = make(map[string]string)
+	p.localNodes = make(map[string]ast.Node)
+	p.usedLocals = make(map[string]bool)
+	p.pendingLocals = make(map[string]bool)
+	var names []string
+	for _, item := range items {
+		if !p.isLocals(item) {
+			continue
+		}
+		if p.version < versionLocals {
+			p.addError(item, CodeInvalidDeclaration, "`locals' blocks require `version = %d' or later", versionLocals)
+			continue
+		}
+		obj := item.Val.(*ast.ObjectType)
+		p.checkAssignmentsOnly(obj.List, "")
+		for _, local := range obj.List.Items {
+			if !isAssignment(local) {
+				continue
+			}
+			name := p.identString(local.Keys[0].Token)
+			if name == "" {
+				continue
+			}
+			if _, found := p.localNodes[name]; found {
+				p.addError(local, CodeLocalRedefined, "Local `%s' redefined", name)
+				continue
+			}
+			p.localNodes[name] = local.Val
+			p.pendingLocals[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		p.definingLocal = name
+		errors := len(p.errors)
+		value, ok := p.literalToText(p.localNodes[name])
+		if ok && len(p.errors) == errors {
+			p.locals[name] = value
+		}
+		delete(p.pendingLocals, name)
+	}
+	p.definingLocal = ""
+}
+
+// substituteLocals replaces each `${local.NAME}' in str, which is the
+// value of node, with the value of the local.  Before the version that
+// added locals, str is returned as it is.
+func (p *Parser) substituteLocals(node ast.Node, str string) string {
+	if p.version < versionLocals || !strings.Contains(str, "${local.") {
+		return str
+	}
+	return localRefRe.ReplaceAllStringFunc(str, func(ref string) string {
+		name := localRefRe.FindStringSubmatch(ref)[1]
+		value, ok := p.locals[name]
+		switch {
+		case ok:
+		case name == p.definingLocal:
+			p.addError(node, CodeUndefinedLocal, "Local `%s' refers to itself", name)
+			return ref
+		case p.pendingLocals[name]:
+			p.usedLocals[name] = true
+			p.addError(node, CodeUndefinedLocal, "Local `%s' is defined after `%s'", name, p.definingLocal)
+			return ref
+		default:
+			if _, defined := p.localNodes[name]; !defined {
+				p.addError(node, CodeUndefinedLocal, "Undefined local `%s'", name)
+			}
+			return ref
+		}
+		p.usedLocals[name] = true
+		return value
+	})
+}
+
+// sourceOffset maps a byte offset in what substituteLocals made of str
+// back to the offset in str.  An offset in the value of a local maps to
+// the start of the reference to it.
+func (p *Parser) sourceOffset(str string, offset int) int {
+	if p.version < versionLocals {
+		return offset
+	}
+	// shift is how much longer the substituted string is, up to the
+	// current reference.
+	shift := 0
+	for _, m := range localRefRe.FindAllStringSubmatchIndex(str, -1) {
+		value, ok := p.locals[str[m[2]:m[3]]]
+		if !ok {
+			continue
+		}
+		start := m[0] + shift
+		if offset < start {
+			break
+		}
+		if offset < start+len(value) {
+			return m[0]
+		}
+		shift += len(value) - (m[1] - m[0])
+	}
+	return offset - shift
+}
+
+// checkUnusedLocals warns about each local that no string used.
+func (p *Parser) checkUnusedLocals() {
+	for _, name := range sortedKeys(p.locals) {
+		if !p.usedLocals[name] {
+			p.addWarning(p.localNodes[name], CodeUnusedLocal, "Local `%s' is never used", name)
+		}
+	}
+}
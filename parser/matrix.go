@@ -0,0 +1,271 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/model"
+)
+
+// defaultMaxExpansions is the default limit on the number of actions that
+// all the matrices in a file expand to, combined.
+const defaultMaxExpansions = 256
+
+// parseMatrix parses the `matrix' attribute of an action or template,
+// which maps variables to a string or list of strings.
+func (p *Parser) parseMatrix(action *model.Action, node ast.Node) {
+	if action.Matrix != nil {
+		p.addWarning(node, CodeAttributeRedefined, "`matrix' redefined in %s `%s'", p.blockType, action.Identifier)
+		// continue, allowing the redefinition
+	}
+	obj, ok := node.(*ast.ObjectType)
+	if !ok {
+		p.addError(node, CodeInvalidMatrix, "Invalid format for `matrix' in %s `%s', expected object", p.blockType, action.Identifier)
+		return
+	}
+	p.checkAssignmentsOnly(obj.List, action.Identifier)
+
+	matrix := make(map[string][]string)
+	for _, item := range obj.List.Items {
+		if !isAssignment(item) {
+			continue
+		}
+		key := p.identString(item.Keys[0].Token)
+		if key == "" {
+			continue
+		}
+		values, ok := p.literalToStringArray(item.Val, true)
+		if !ok {
+			continue
+		}
+		if len(values) == 0 {
+			p.addError(item.Val, CodeInvalidMatrix, "Matrix variable `%s' in %s `%s' must have at least one value", key, p.blockType, action.Identifier)
+			continue
+		}
+		if _, found := matrix[key]; found {
+			p.addWarning(node, CodeEnvRedefined, "Matrix variable `%s' redefined", key)
+		}
+		p.checkEnvironmentVariable(key, item.Val)
+		matrix[key] = uniqStrings(values)
+	}
+	if len(matrix) == 0 {
+		p.addError(node, CodeInvalidMatrix, "`matrix' in %s `%s' must have at least one variable", p.blockType, action.Identifier)
+		return
+	}
+	action.Matrix = matrix
+	p.posMap[&action.Matrix] = node
+}
+
+// expandMatrices replaces each action that has a matrix with one action
+// for each combination of the matrix's values.  The variables are taken
+// in order of their names, with the last changing fastest, and the values
+// of each in the order written.  Each of those actions has the
+// combination in Cell and in Env, and an identifier such as
+// "test (GO=1.11, OS=linux)".  Wherever a `needs' or `resolves' names an
+// action with a matrix, it names all of its expansions instead.
+//
+// The action as written stays in p.posMap, so that the syntax tree links
+// its block to it, rather than to any one of the expansions.
+func (p *Parser) expandMatrices() {
+	identifiers := make(map[string]bool)
+	for _, action := range p.actions {
+		identifiers[action.Identifier] = true
+	}
+	for _, workflow := range p.workflows {
+		identifiers[workflow.Identifier] = true
+	}
+	for _, template := range p.templates {
+		identifiers[template.Identifier] = true
+	}
+
+	fanOut := make(map[string][]string)
+	total := 0
+	actions := make([]*model.Action, 0, len(p.actions))
+	for _, action := range p.actions {
+		if action.Matrix == nil {
+			actions = append(actions, action)
+			continue
+		}
+		node := p.posMap[&action.Matrix]
+		for _, k := range sortedMatrixKeys(action.Matrix) {
+			if _, found := action.Env[k]; found {
+				p.addError(node, CodeMatrixConflict, "Matrix variable `%s' of action `%s' conflicts with an environment variable with the same name", k, action.Identifier)
+			}
+		}
+		size := matrixSize(action.Matrix, p.maxExpansions-total)
+		if size > p.maxExpansions-total {
+			p.addError(node, CodeMatrixTooLarge, "All matrices combined must not expand to more than %d actions", p.maxExpansions)
+			actions = append(actions, action)
+			continue
+		}
+		total += size
+
+		cells := matrixCells(action.Matrix)
+		ids := make([]string, 0, len(cells))
+		for _, cell := range cells {
+			expanded := p.expandCell(action, cell)
+			if identifiers[expanded.Identifier] {
+				p.addError(node, CodeIdentifierRedefined, "Identifier `%s', from the matrix of action `%s', is already defined", expanded.Identifier, action.Identifier)
+				continue
+			}
+			identifiers[expanded.Identifier] = true
+			ids = append(ids, expanded.Identifier)
+			actions = append(actions, expanded)
+		}
+		fanOut[action.Identifier] = ids
+	}
+	p.actions = actions
+	if len(fanOut) == 0 {
+		return
+	}
+
+	// Actions and workflows that name an action with a matrix are copied,
+	// rather than changed, since Reparse may reuse them.
+	for i, action := range p.actions {
+		if needs, ok := fanIn(action.Needs, fanOut); ok {
+			copied := p.copyAction(action)
+			copied.Needs = needs
+			delete(p.posMap, action)
+			p.actions[i] = copied
+		}
+	}
+	for i, workflow := range p.workflows {
+		if resolves, ok := fanIn(workflow.Resolves, fanOut); ok {
+			copied := p.copyWorkflow(workflow)
+			copied.Resolves = resolves
+			delete(p.posMap, workflow)
+			p.workflows[i] = copied
+		}
+	}
+}
+
+// expandCell returns the action for one combination of the values in
+// action's matrix.
+func (p *Parser) expandCell(action *model.Action, cell map[string]string) *model.Action {
+	ret := p.copyAction(action)
+	ret.Matrix = nil
+	ret.Cell = cell
+
+	keys := sortedKeys(cell)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", k, cell[k])
+	}
+	ret.Identifier = fmt.Sprintf("%s (%s)", action.Identifier, strings.Join(parts, ", "))
+
+	ret.Env = make(map[string]string, len(action.Env)+len(cell))
+	for k, v := range action.Env {
+		ret.Env[k] = v
+	}
+	for k, v := range cell {
+		ret.Env[k] = v
+	}
+	if _, ok := p.posMap[&ret.Env]; !ok {
+		p.position(&ret.Env, &action.Matrix)
+	}
+	return ret
+}
+
+// copyAction returns a copy of action, with the same positions.  The
+// variable names of the copy are checked with those of action as
+// written; see checkAction.
+func (p *Parser) copyAction(action *model.Action) *model.Action {
+	ret := *action
+	p.position(&ret, action)
+	p.position(&ret.Runs, &action.Runs)
+	p.position(&ret.Args, &action.Args)
+	p.position(&ret.Needs, &action.Needs)
+	p.position(&ret.Env, &action.Env)
+	p.position(&ret.Secrets, &action.Secrets)
+	p.position(&ret.Extends, &action.Extends)
+	p.position(&ret.Matrix, &action.Matrix)
+	p.position(&ret.Timeout, &action.Timeout)
+	p.position(&ret.Retries, &action.Retries)
+	p.position(&ret.If, &action.If)
+	if own, ok := p.own[action]; ok {
+		p.own[&ret] = own
+	} else {
+		p.own[&ret] = action
+	}
+	return &ret
+}
+
+// copyWorkflow returns a copy of workflow, with the same positions.
+func (p *Parser) copyWorkflow(workflow *model.Workflow) *model.Workflow {
+	ret := *workflow
+	p.position(&ret, workflow)
+	p.position(&ret.Resolves, &workflow.Resolves)
+	p.position(&ret.Env, &workflow.Env)
+	p.position(&ret.Types, &workflow.Types)
+	p.position(&ret.Branches, &workflow.Branches)
+	return &ret
+}
+
+// fanIn returns names with each name in fanOut replaced by the names it
+// maps to, and true, or nil and false if no name is in fanOut.
+func fanIn(names []string, fanOut map[string][]string) ([]string, bool) {
+	found := false
+	for _, name := range names {
+		if _, ok := fanOut[name]; ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	ret := make([]string, 0, len(names))
+	for _, name := range names {
+		if ids, ok := fanOut[name]; ok {
+			ret = append(ret, ids...)
+		} else {
+			ret = append(ret, name)
+		}
+	}
+	return ret, true
+}
+
+// matrixSize returns the number of combinations of the values in a
+// matrix, or limit+1 if there are more than limit.
+func matrixSize(matrix map[string][]string, limit int) int {
+	size := 1
+	for _, values := range matrix {
+		size *= len(values)
+		if size > limit {
+			return limit + 1
+		}
+	}
+	return size
+}
+
+// matrixCells returns every combination of the values in a matrix.
+func matrixCells(matrix map[string][]string) []map[string]string {
+	cells := []map[string]string{{}}
+	for _, k := range sortedMatrixKeys(matrix) {
+		next := make([]map[string]string, 0, len(cells)*len(matrix[k]))
+		for _, cell := range cells {
+			for _, v := range matrix[k] {
+				c := make(map[string]string, len(cell)+1)
+				for ck, cv := range cell {
+					c[ck] = cv
+				}
+				c[k] = v
+				next = append(next, c)
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+func sortedMatrixKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
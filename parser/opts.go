@@ -1,5 +1,9 @@
 package parser
 
+import (
+	"github.com/actions/workflow-parser/model"
+)
+
 type OptionFunc func(*Parser)
 
 func WithSuppressWarnings() OptionFunc {
@@ -13,3 +17,148 @@ func WithSuppressErrors() OptionFunc {
 		ps.suppressSeverity = ERROR
 	}
 }
+
+// WithMaxSecrets sets the maximum number of unique secrets that all
+// actions in a file may use combined.  The default is 100.
+func WithMaxSecrets(max int) OptionFunc {
+	return func(ps *Parser) {
+		ps.maxSecrets = max
+	}
+}
+
+// WithMaxMatrixExpansions sets the maximum number of actions that all the
+// matrices in a file may expand to, combined.  The default is 256.
+func WithMaxMatrixExpansions(max int) OptionFunc {
+	return func(ps *Parser) {
+		ps.maxExpansions = max
+	}
+}
+
+// WithVersionRange sets the range of `version = N` values the parser
+// accepts.  Versions outside the range the parser implements are still
+// rejected.
+func WithVersionRange(min, max int) OptionFunc {
+	return func(ps *Parser) {
+		ps.minVersion = min
+		ps.maxVersion = max
+	}
+}
+
+// WithEventTypes replaces the registry of event types that workflows may
+// use in their `on' attribute.
+func WithEventTypes(types ...EventType) OptionFunc {
+	return func(ps *Parser) {
+		ps.eventTypes = makeEventTypeMap(types)
+	}
+}
+
+// WithAdditionalEventTypes adds event types to the registry, on top of
+// the default ones or those set by WithEventTypes.  An event type with
+// the same name as an existing entry replaces it.
+func WithAdditionalEventTypes(types ...EventType) OptionFunc {
+	return func(ps *Parser) {
+		registry := make(map[string]EventType, len(ps.eventTypes)+len(types))
+		for name, et := range ps.eventTypes {
+			registry[name] = et
+		}
+		for name, et := range makeEventTypeMap(types) {
+			registry[name] = et
+		}
+		ps.eventTypes = registry
+	}
+}
+
+// WithCodeSeverity reports diagnostics with the given code at a different
+// severity, e.g., to promote CodeSecretRedefined to an ERROR.  Syntax
+// errors are always FATAL.
+func WithCodeSeverity(code Code, severity Severity) OptionFunc {
+	return func(ps *Parser) {
+		if ps.codeSeverities == nil {
+			ps.codeSeverities = make(map[Code]Severity)
+		}
+		ps.codeSeverities[code] = severity
+	}
+}
+
+// WithIgnoredCodes silences all diagnostics with the given codes.
+func WithIgnoredCodes(codes ...Code) OptionFunc {
+	return func(ps *Parser) {
+		if ps.ignoredCodes == nil {
+			ps.ignoredCodes = make(map[Code]bool)
+		}
+		for _, code := range codes {
+			ps.ignoredCodes[code] = true
+		}
+	}
+}
+
+// WithPreserveHeredocs keeps newlines and other whitespace in heredoc
+// strings, so that multi-line scripts in `runs' and values such as
+// certificates in `env' come through intact, final newline included.
+// Indentation common to all lines of a heredoc is removed, and so is the
+// final newline of a name or a reference, such as the value of `uses' or
+// `on'.  By default, all whitespace in a heredoc is compressed to single
+// spaces.
+func WithPreserveHeredocs() OptionFunc {
+	return func(ps *Parser) {
+		ps.heredocs = model.HeredocVerbatim
+	}
+}
+
+// WithInterpolationAnalysis enables warnings about shell-style variable
+// references (`$VAR' and `${VAR}') in `runs', `args' and `env' values.
+// The parser never expands these references itself, but the container
+// will.  See checkInterpolation for the rules.
+func WithInterpolationAnalysis() OptionFunc {
+	return func(ps *Parser) {
+		ps.analyzeInterpolation = true
+	}
+}
+
+// WithStrictGrammar reports an error for anything in the file that the
+// grammar in language.md does not allow, even if the HCL-based parser
+// accepts it: quoted keys, heredocs, block comments, commas between
+// attributes, secrets that are not identifiers, `uses' values that are
+// not one of the documented forms, and so on.
+func WithStrictGrammar() OptionFunc {
+	return func(ps *Parser) {
+		ps.strictGrammar = true
+	}
+}
+
+// WithNativeParser parses the file with the lexer and parser in this
+// package instead of the HCL library.  It accepts the same files and
+// produces the same configuration and diagnostics, but its syntax errors
+// are clearer and more precisely placed, and it allows heredocs anywhere
+// a string can go.  It will become the default once it has seen enough
+// use.
+func WithNativeParser() OptionFunc {
+	return func(ps *Parser) {
+		ps.nativeSyntax = true
+	}
+}
+
+// WithWorkers sets the number of files that ParseFiles and ParseDir parse
+// at once.  The default is GOMAXPROCS.
+func WithWorkers(n int) OptionFunc {
+	return func(ps *Parser) {
+		ps.workers = n
+	}
+}
+
+// WithFileSystem sets where the files named by `include' are read from.
+// Without it, every `include' is an error.
+func WithFileSystem(fs FileSystem) OptionFunc {
+	return func(ps *Parser) {
+		ps.fileSystem = fs
+	}
+}
+
+// WithCache makes ParseFiles and ParseDir look up each file in cache
+// before parsing it, and add the result afterwards.  See NewLRUCache and
+// NewDiskCache.
+func WithCache(cache Cache) OptionFunc {
+	return func(ps *Parser) {
+		ps.cache = cache
+	}
+}
@@ -5,94 +5,257 @@ import (
 	"io"
 	"io/ioutil"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
 	"github.com/actions/workflow-parser/model"
-	"github.com/hashicorp/hcl"
-	"github.com/hashicorp/hcl/hcl/ast"
-	hclparser "github.com/hashicorp/hcl/hcl/parser"
-	"github.com/hashicorp/hcl/hcl/token"
 	"github.com/soniakeys/graph"
 )
 
+// minVersion and maxVersion are the range of language versions this
+// parser implements.
 const minVersion = 0
-const maxVersion = 0
-const maxSecrets = 100
+const maxVersion = 9
+
+// The first language version with each feature added since version 0.
+const (
+	versionWorkflowEnv = 1
+	versionOnList      = 2
+	versionFilters     = 3
+	versionTemplates   = 4
+	versionInclude     = 5
+	versionMatrix      = 6
+	versionTimeout     = 7
+	versionIf          = 8
+	versionLocals      = 9
+)
+
+// attributeVersions gives, for each kind of block, the first language
+// version that allows each attribute added since version 0.  In earlier
+// versions, the attribute is unknown.
+var attributeVersions = map[string]map[string]int{
+	"workflow": {
+		"env":      versionWorkflowEnv,
+		"types":    versionFilters,
+		"branches": versionFilters,
+	},
+	"action": {
+		"extends": versionTemplates,
+		"matrix":  versionMatrix,
+		"timeout": versionTimeout,
+		"retries": versionTimeout,
+		"if":      versionIf,
+	},
+	"template": {
+		"matrix":  versionMatrix,
+		"timeout": versionTimeout,
+		"retries": versionTimeout,
+		"if":      versionIf,
+	},
+}
+
+const defaultMaxSecrets = 100
 
 type Parser struct {
 	version   int
 	actions   []*model.Action
 	workflows []*model.Workflow
+	templates []*model.Action
 	errors    errorList
 
+	// blockType is the type of the block being parsed, "action" or
+	// "template", for use in diagnostics about their attributes.
+	blockType string
+
+	// own maps each action that was expanded from templates to the
+	// action as written, without the inherited attributes.
+	own map[*model.Action]*model.Action
+
+	// included lists the files included, directly or indirectly, and
+	// includes is the state shared with the parsers of those files.
+	included   []string
+	includes   *includeState
+	fileSystem FileSystem
+
+	// locals maps the name of each local to its value, and localNodes to
+	// its position.  usedLocals holds the locals that strings have used.
+	// While parseLocals works out the value of definingLocal,
+	// pendingLocals holds it and the locals defined after it.
+	locals        map[string]string
+	localNodes    map[string]ast.Node
+	usedLocals    map[string]bool
+	definingLocal string
+	pendingLocals map[string]bool
+
 	posMap           map[interface{}]ast.Node
 	suppressSeverity Severity
+	codeSeverities   map[Code]Severity
+	ignoredCodes     map[Code]bool
+	suppressions     []*suppression
+
+	heredocs             model.HeredocMode
+	analyzeInterpolation bool
+	strictGrammar        bool
+	nativeSyntax         bool
+	syntaxTree           *SyntaxTree
+	root                 *ast.File
+	blocks               []*blockState
+	workers              int
+	cache                Cache
+	maxSecrets           int
+	maxExpansions        int
+	minVersion           int
+	maxVersion           int
+	eventTypes           map[string]EventType
+
+	// jsonSrc is the source of a file in the JSON flavor, whose strings
+	// conditionPos reads.
+	jsonSrc []byte
 }
 
 // Parse parses a .workflow file and return the actions and global variables found within.
+//
+// If any diagnostics survive suppression, Parse returns a nil
+// configuration and an *Error.  Use ParseWithResult to get the
+// best-effort configuration together with all diagnostics.
 func Parse(reader io.Reader, options ...OptionFunc) (*model.Configuration, error) {
+	result, err := ParseWithResult(reader, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return result.Configuration, nil
+}
+
+// ParseWithResult parses a .workflow file and returns the best-effort
+// configuration along with every diagnostic that survived suppression.
+// The returned error is only non-nil for system errors, such as a failure
+// to read from reader; problems in the file itself are reported in
+// ParseResult.Errors.
+func ParseWithResult(reader io.Reader, options ...OptionFunc) (*ParseResult, error) {
 	// FIXME - check context for deadline?
 	b, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
 
-	root, err := hcl.ParseBytes(b)
+	p := newParser(options...)
+	root, err := p.parseSyntax(b)
 	if err != nil {
-		if pe, ok := err.(*hclparser.PosError); ok {
-			pos := ErrorPos{File: pe.Pos.Filename, Line: pe.Pos.Line, Column: pe.Pos.Column}
-			errors := errorList{newFatal(pos, pe.Err.Error())}
-			return nil, &Error{
-				message: "unable to parse",
-				Errors:  errors,
-			}
+		if pe, ok := err.(*ParseError); ok {
+			return &ParseResult{
+				Configuration: &model.Configuration{},
+				Errors:        errorList{pe},
+				message:       "unable to parse",
+				src:           b,
+				options:       options,
+			}, nil
 		}
 		return nil, err
 	}
 
-	p := parseAndValidate(root.Node, options...)
-	if len(p.errors) > 0 {
-		return nil, &Error{
-			message:   "unable to parse and validate",
-			Errors:    p.errors,
+	p.processFile(b, root, nil)
+	return p.result(b, options), nil
+}
+
+// result returns the ParseResult for a file that was successfully parsed
+// into an AST.
+func (p *Parser) result(src []byte, options []OptionFunc) *ParseResult {
+	if p.syntaxTree != nil {
+		p.syntaxTree.link(p.posMap)
+	}
+	return &ParseResult{
+		Configuration: &model.Configuration{
+			Version:   p.version,
+			Heredocs:  p.heredocs,
 			Actions:   p.actions,
 			Workflows: p.workflows,
-		}
+		},
+		Errors:     p.errors,
+		SyntaxTree: p.syntaxTree,
+		Includes:   p.included,
+		message:    "unable to parse and validate",
+		src:        src,
+		options:    options,
+		parser:     p,
 	}
-
-	return &model.Configuration{
-		Actions:   p.actions,
-		Workflows: p.workflows,
-	}, nil
 }
 
-// parseAndValidate converts a HCL AST into a Parser and validates
-// high-level structure.
+// parseAndValidate converts the AST of a .workflow file into a Parser
+// and validates high-level structure.
 // Parameters:
-//  - root - the contents of a .workflow file, as AST
+//   - src - the contents of a .workflow file, as bytes
+//   - root - the contents of a .workflow file, as AST
+//
 // Returns:
-//  - a Parser structure containing actions and workflow definitions
-func parseAndValidate(root ast.Node, options ...OptionFunc) *Parser {
+//   - a Parser structure containing actions and workflow definitions
+func parseAndValidate(src []byte, root *ast.File, options ...OptionFunc) *Parser {
+	p := newParser(options...)
+	p.processFile(src, root, nil)
+	return p
+}
+
+// newParser returns a Parser with the given options applied.
+func newParser(options ...OptionFunc) *Parser {
 	p := &Parser{
-		posMap: make(map[interface{}]ast.Node),
+		posMap:        make(map[interface{}]ast.Node),
+		maxSecrets:    defaultMaxSecrets,
+		maxExpansions: defaultMaxExpansions,
+		minVersion:    minVersion,
+		maxVersion:    maxVersion,
+		eventTypes:    eventTypeWhitelist,
 	}
 
 	for _, option := range options {
 		option(p)
 	}
+	return p
+}
 
-	p.parseRoot(root)
+// parseSyntax parses the contents of a .workflow file into an AST, using
+// the HCL parser or, with WithNativeParser, the one in this package.  A
+// syntax error is returned as a FATAL *ParseError; any other error is a
+// system error.
+func (p *Parser) parseSyntax(src []byte) (*ast.File, error) {
+	if p.nativeSyntax {
+		root, tree, err := parseNative(src)
+		p.syntaxTree = tree
+		return root, err
+	}
+
+	return parseHCL(src)
+}
+
+// processFile fills in the Parser from the AST of a .workflow file and
+// validates it, reusing any cached blocks.
+func (p *Parser) processFile(src []byte, root *ast.File, cached []*blockState) {
+	p.root = root
+	p.parseSuppressions(root.Comments)
+	if p.strictGrammar {
+		p.checkStrictGrammar(src)
+	}
+	p.parseRoot(root.Node, cached)
+	p.expandTemplates()
+	p.expandMatrices()
 	p.validate()
+	p.applySuppressions()
 	p.errors.sort()
-
-	return p
+	p.errors = p.errors.dedupe()
 }
 
 func (p *Parser) validate() {
 	p.analyzeDependencies()
 	p.checkCircularDependencies()
-	p.checkActions()
+	p.checkBlocks()
+	p.checkSecretCount()
 	p.checkFlows()
+	if p.analyzeInterpolation {
+		p.checkInterpolation()
+	}
 }
 
 func uniqStrings(items []string) []string {
@@ -133,52 +296,149 @@ func (p *Parser) checkCircularDependencies() {
 	g := graph.Directed{AdjacencyList: adjList}
 	g.Cycles(func(cycle []graph.NI) bool {
 		node := p.posMap[&p.actions[cycle[len(cycle)-1]].Needs]
-		p.addFatal(node, "Circular dependency on `%s'", p.actions[cycle[0]].Identifier)
+		p.addFatal(node, CodeCircularDependency, "Circular dependency on `%s'", p.actions[cycle[0]].Identifier)
 		return true
 	})
 }
 
-// checkActions returns error if any actions are syntactically correct but
-// have structural errors
-func (p *Parser) checkActions() {
-	secrets := make(map[string]bool)
-	for _, t := range p.actions {
-		// Ensure the Action has a `uses` attribute
-		if t.Uses == nil {
-			p.addError(p.posMap[t], "Action `%s' must have a `uses' attribute", t.Identifier)
-			// continue, checking other actions
+// checkBlocks runs the checks that look at one block at a time.  A block
+// that Reparse reused keeps the diagnostics from when it was last
+// checked, unless its action extends templates, which may have changed,
+// or has a matrix, whose expansions depend on the other blocks.
+// Actions, templates, and workflows that are in no block, such as those
+// from included files, are checked every time.
+func (p *Parser) checkBlocks() {
+	// An action as written may have become several, or a copy, when
+	// templates and matrices were expanded.
+	own := func(action *model.Action) *model.Action {
+		if o, ok := p.own[action]; ok {
+			return o
+		}
+		return action
+	}
+	expansions := make(map[*model.Action][]*model.Action)
+	for _, action := range p.actions {
+		expansions[own(action)] = append(expansions[own(action)], action)
+	}
+
+	inBlock := make(map[*model.Action]bool)
+	workflows := 0
+	for _, state := range p.blocks {
+		if state == nil {
+			continue
+		}
+		// The workflows are those of the blocks, in order, though
+		// matrices may have replaced some with copies.
+		var workflow *model.Workflow
+		if state.workflow != nil {
+			workflow = p.workflows[workflows]
+			workflows++
+		}
+		if !state.checked || state.action != nil && (state.action.Extends != nil || state.action.Matrix != nil) {
+			state.checkErrors = p.collect(func() {
+				switch {
+				case state.action != nil:
+					for _, action := range expansions[state.action] {
+						p.checkAction(action)
+					}
+				case state.template != nil:
+					p.checkVariableNames(state.template)
+				case workflow != nil:
+					p.checkWorkflow(workflow)
+				}
+			})
+			state.checked = true
+		}
+		p.errors = append(p.errors, state.checkErrors...)
+		if state.action != nil {
+			inBlock[state.action] = true
+		}
+		if state.template != nil {
+			inBlock[state.template] = true
 		}
+	}
 
-		// Ensure there aren't too many secrets
+	for _, template := range p.templates {
+		if !inBlock[template] {
+			p.checkVariableNames(template)
+		}
+	}
+	for _, action := range p.actions {
+		if !inBlock[own(action)] {
+			p.checkAction(action)
+		}
+	}
+	for _, workflow := range p.workflows[workflows:] {
+		p.checkWorkflow(workflow)
+	}
+}
+
+// collect runs check, and returns the diagnostics it adds rather than
+// keeping them.
+func (p *Parser) collect(check func()) errorList {
+	saved := p.errors
+	p.errors = nil
+	check()
+	ret := p.errors
+	p.errors = saved
+	return ret
+}
+
+// checkAction returns error if an action is syntactically correct but
+// has structural errors.
+func (p *Parser) checkAction(t *model.Action) {
+	// Ensure the Action has a `uses` attribute
+	if t.Uses == nil {
+		p.addError(p.posMap[t], CodeMissingUses, "Action `%s' must have a `uses' attribute", t.Identifier)
+	}
+
+	// Names inherited from templates are checked with the template.
+	own := t
+	if o, ok := p.own[t]; ok {
+		own = o
+	}
+	p.checkVariableNames(own)
+
+	// Ensure that the same key name isn't used more than once
+	// between env and secrets, combined.
+	for _, k := range t.Secrets {
+		if _, found := t.Env[k]; found {
+			p.addError(p.posMap[&t.Secrets], CodeSecretConflict, "Secret `%s' conflicts with an environment variable with the same name", k)
+		}
+	}
+}
+
+// checkSecretCount ensures that there aren't too many secrets, across
+// all actions.
+func (p *Parser) checkSecretCount() {
+	secrets := make(map[string]bool)
+	for _, t := range p.actions {
 		for _, str := range t.Secrets {
 			if !secrets[str] {
 				secrets[str] = true
-				if len(secrets) == maxSecrets+1 {
-					p.addError(p.posMap[&t.Secrets], "All actions combined must not have more than %d unique secrets", maxSecrets)
+				if len(secrets) == p.maxSecrets+1 {
+					p.addError(p.posMap[&t.Secrets], CodeTooManySecrets, "All actions combined must not have more than %d unique secrets", p.maxSecrets)
 				}
 			}
 		}
+	}
+}
 
-		// Ensure that no environment variable or secret begins with
-		// "GITHUB_", unless it's "GITHUB_TOKEN".
-		// Also ensure that all environment variable names come from the legal
-		// form for environment variable names.
-		// Finally, ensure that the same key name isn't used more than once
-		// between env and secrets, combined.
-		for k := range t.Env {
-			p.checkEnvironmentVariable(k, p.posMap[&t.Env])
-		}
-		secretVars := make(map[string]bool)
-		for _, k := range t.Secrets {
-			p.checkEnvironmentVariable(k, p.posMap[&t.Secrets])
-			if _, found := t.Env[k]; found {
-				p.addError(p.posMap[&t.Secrets], "Secret `%s' conflicts with an environment variable with the same name", k)
-			}
-			if secretVars[k] {
-				p.addWarning(p.posMap[&t.Secrets], "Secret `%s' redefined", k)
-			}
-			secretVars[k] = true
+// checkVariableNames ensures that no environment variable or secret of an
+// action or template begins with "GITHUB_", unless it's "GITHUB_TOKEN",
+// that all of them have the legal form for environment variable names,
+// and that no secret is listed twice.
+func (p *Parser) checkVariableNames(t *model.Action) {
+	for k := range t.Env {
+		p.checkEnvironmentVariable(k, p.posMap[&t.Env])
+	}
+	secretVars := make(map[string]bool)
+	for _, k := range t.Secrets {
+		p.checkEnvironmentVariable(k, p.posMap[&t.Secrets])
+		if secretVars[k] {
+			p.addWarning(p.posMap[&t.Secrets], CodeSecretRedefined, "Secret `%s' redefined", k)
 		}
+		secretVars[k] = true
 	}
 }
 
@@ -186,33 +446,64 @@ var envVarChecker = regexp.MustCompile(`\A[A-Za-z_][A-Za-z_0-9]*\z`)
 
 func (p *Parser) checkEnvironmentVariable(key string, node ast.Node) {
 	if key != "GITHUB_TOKEN" && strings.HasPrefix(key, "GITHUB_") {
-		p.addWarning(node, "Environment variables and secrets beginning with `GITHUB_' are reserved")
+		p.addWarning(node, CodeReservedVariable, "Environment variables and secrets beginning with `GITHUB_' are reserved")
 	}
 	if !envVarChecker.MatchString(key) {
-		p.addWarning(node, "Environment variables and secrets must contain only A-Z, a-z, 0-9, and _ characters, got `%s'", key)
+		p.addWarning(node, CodeInvalidVariableName, "Environment variables and secrets must contain only A-Z, a-z, 0-9, and _ characters, got `%s'", key)
+	}
+}
+
+// checkWorkflow appends an error if a workflow is syntactically correct
+// but has structural errors of its own.
+func (p *Parser) checkWorkflow(f *model.Workflow) {
+	// make sure on attribute is present
+	if f.On == nil {
+		p.addError(p.posMap[f], CodeMissingOn, "Workflow `%s' must have an `on' attribute", f.Identifier)
+	}
+	// the workflow's env is subject to the same rules as an action's
+	for _, k := range sortedKeys(f.Env) {
+		p.checkEnvironmentVariable(k, p.posMap[&f.Env])
 	}
 }
 
 // checkFlows appends an error if any workflows are syntactically correct but
-// have structural errors
+// have structural errors that involve their actions
 func (p *Parser) checkFlows() {
 	actionmap := makeActionMap(p.actions)
+	config := &model.Configuration{Actions: p.actions}
 	for _, f := range p.workflows {
-		// make sure on attribute is present
-		if f.On == nil {
-			p.addError(p.posMap[f], "Workflow `%s' must have an `on' attribute", f.Identifier)
+		// the rules for the workflow's env apply to every action that
+		// inherits it
+		if len(f.Env) > 0 {
+			for _, action := range config.ResolvedActions(f) {
+				for _, k := range action.Secrets {
+					_, inWorkflow := f.Env[k]
+					if _, inAction := action.Env[k]; inWorkflow && !inAction {
+						p.addError(p.posMap[&action.Secrets], CodeSecretConflict, "Secret `%s' in action `%s' conflicts with an environment variable with the same name in workflow `%s'", k, action.Identifier, f.Identifier)
+					}
+				}
+			}
 		}
 		// make sure that the actions that are resolved all exist
 		for _, actionID := range f.Resolves {
 			_, ok := actionmap[actionID]
 			if !ok {
-				p.addError(p.posMap[&f.Resolves], "Workflow `%s' resolves unknown action `%s'", f.Identifier, actionID)
+				p.addError(p.posMap[&f.Resolves], CodeUnknownResolves, "Workflow `%s' resolves unknown action `%s'", f.Identifier, actionID)
 				// continue, checking other workflows
 			}
 		}
 	}
 }
 
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func makeActionMap(actions []*model.Action) map[string]*model.Action {
 	actionmap := make(map[string]*model.Action)
 	for _, action := range actions {
@@ -245,7 +536,7 @@ func (p *Parser) analyzeNeeds(action *model.Action, actionmap map[string]*model.
 	for _, need := range action.Needs {
 		_, ok := actionmap[need]
 		if !ok {
-			p.addError(p.posMap[&action.Needs], "Action `%s' needs nonexistent action `%s'", action.Identifier, need)
+			p.addError(p.posMap[&action.Needs], CodeUnknownDependency, "Action `%s' needs nonexistent action `%s'", action.Identifier, need)
 			// continue, checking other actions
 		}
 	}
@@ -262,7 +553,7 @@ func (p *Parser) literalToStringMap(node ast.Node) map[string]string {
 	obj, ok := node.(*ast.ObjectType)
 
 	if !ok {
-		p.addError(node, "Expected object, got %s", typename(node))
+		p.addError(node, CodeTypeMismatch, "Expected object, got %s", typename(node))
 		return nil
 	}
 
@@ -273,12 +564,12 @@ func (p *Parser) literalToStringMap(node ast.Node) map[string]string {
 		if !isAssignment(item) {
 			continue
 		}
-		str, ok := p.literalToString(item.Val)
+		str, ok := p.literalToText(item.Val)
 		if ok {
 			key := p.identString(item.Keys[0].Token)
 			if key != "" {
 				if _, found := ret[key]; found {
-					p.addWarning(node, "Environment variable `%s' redefined", key)
+					p.addWarning(node, CodeEnvRedefined, "Environment variable `%s' redefined", key)
 				}
 				ret[key] = str
 			}
@@ -295,7 +586,7 @@ func (p *Parser) identString(t token.Token) string {
 	case token.IDENT:
 		return t.Text
 	default:
-		p.addErrorFromToken(t,
+		p.addErrorFromToken(t, CodeInvalidIdentifier,
 			"Each identifier should be a string, got %s",
 			strings.ToLower(t.Type.String()))
 		return ""
@@ -317,16 +608,16 @@ func (p *Parser) literalToStringArray(node ast.Node, promoteScalars bool) ([]str
 		if promoteScalars && literal.Token.Type == token.STRING {
 			return []string{literal.Token.Value().(string)}, true
 		} else if promoteScalars {
-			p.addError(node, "Expected list or string, got %s", typename(node))
+			p.addError(node, CodeTypeMismatch, "Expected list or string, got %s", typename(node))
 		} else {
-			p.addError(node, "Expected list, got %s", typename(node))
+			p.addError(node, CodeTypeMismatch, "Expected list, got %s", typename(node))
 		}
 		return nil, false
 	}
 
 	list, ok := node.(*ast.ListType)
 	if !ok {
-		p.addError(node, "Expected list, got %s", typename(node))
+		p.addError(node, CodeTypeMismatch, "Expected list, got %s", typename(node))
 		return nil, false
 	}
 
@@ -345,7 +636,19 @@ func (p *Parser) literalToStringArray(node ast.Node, promoteScalars bool) ([]str
 // If the value isn't a scalar or isn't a string, the function appends an
 // appropriate error and returns "", false.
 func (p *Parser) literalToString(node ast.Node) (string, bool) {
-	val := p.literalCast(node, token.STRING)
+	val := p.literalCast(node, token.STRING, false)
+	if val == nil {
+		return "", false
+	}
+	return val.(string), true
+}
+
+// literalToText is literalToString for text, rather than a name or a
+// reference: the value of an environment variable or a local, or a
+// command.  With WithPreserveHeredocs, text from a heredoc keeps its final
+// newline.
+func (p *Parser) literalToText(node ast.Node) (string, bool) {
+	val := p.literalCast(node, token.STRING, true)
 	if val == nil {
 		return "", false
 	}
@@ -358,7 +661,7 @@ func (p *Parser) literalToString(node ast.Node) (string, bool) {
 // If the value isn't a scalar or isn't a number, the function appends an
 // appropriate error and returns 0, false.
 func (p *Parser) literalToInt(node ast.Node) (int64, bool) {
-	val := p.literalCast(node, token.NUMBER)
+	val := p.literalCast(node, token.NUMBER, false)
 	if val == nil {
 		return 0, false
 	}
@@ -367,103 +670,236 @@ func (p *Parser) literalToInt(node ast.Node) (int64, bool) {
 
 var whitespaceRe = regexp.MustCompile(`\s+`)
 
-func (p *Parser) literalCast(node ast.Node, t token.Type) interface{} {
+func (p *Parser) literalCast(node ast.Node, t token.Type, text bool) interface{} {
 	literal, ok := node.(*ast.LiteralType)
 	if !ok {
-		p.addError(node, "Expected %s, got %s", strings.ToLower(t.String()), typename(node))
+		p.addError(node, CodeTypeMismatch, "Expected %s, got %s", strings.ToLower(t.String()), typename(node))
 		return nil
 	}
 
-	// Allow heredocs anywhere a string is required.  In practice, the HCL
-	// parser only allows heredocs as the right-hand value for a key in an
-	// object context.  So you can use heredocs as string values (uses, cmd,
-	// args, etc) and as the values inside string maps (env), but not as
-	// values inside string arrays (secrets, the array forms of cmd and
-	// args).
+	// Allow heredocs anywhere a string is required: as string values
+	// (uses, cmd, args, etc), as the values inside string maps (env), and
+	// as values inside string arrays.
+	//
+	// By default, we compress and trim all whitespace from a heredoc
+	// before returning it.  Any string of whitespace (including tabs and
+	// newlines) compresses to a single space.  So, when specifying a shell
+	// string, expect newlines to come through as IFS argument separators,
+	// not as command separators.  Use semicolons if you need to.
 	//
-	// We compress and trim all whitespace from a heredoc before returning
-	// it.  Any string of whitespace (including tabs and newlines)
-	// compresses to a single space.  So, when specifying a shell string,
-	// expect newlines to come through as IFS argument separators, not as
-	// command separators.  Use semicolons if you need to.
+	// WithPreserveHeredocs keeps the content instead, removing only the
+	// common indentation, and the final newline of a name or a reference,
+	// such as `uses' or `on', which cannot end in one.  See
+	// verbatimHeredoc.
 	if t == token.STRING && literal.Token.Type == token.HEREDOC {
+		if p.heredocs == model.HeredocVerbatim {
+			str := verbatimHeredoc(literal.Token.Text)
+			if !text {
+				str = strings.TrimSuffix(str, "\n")
+			}
+			return p.substituteLocals(node, str)
+		}
 		str, ok := literal.Token.Value().(string)
 		if !ok {
-			p.addError(node, "Expected %s, got %s", strings.ToLower(t.String()), typename(node))
+			p.addError(node, CodeTypeMismatch, "Expected %s, got %s", strings.ToLower(t.String()), typename(node))
 			return nil
 		}
 		str = whitespaceRe.ReplaceAllString(str, " ")
 		str = strings.TrimSpace(str)
-		return str
+		return p.substituteLocals(node, str)
 	}
 
 	if literal.Token.Type != t {
-		p.addError(node, "Expected %s, got %s", strings.ToLower(t.String()), typename(node))
+		p.addError(node, CodeTypeMismatch, "Expected %s, got %s", strings.ToLower(t.String()), typename(node))
 		return nil
 	}
 
+	if t == token.STRING {
+		return p.substituteLocals(node, literal.Token.Value().(string))
+	}
 	return literal.Token.Value()
 }
 
+// verbatimHeredoc returns the content of a heredoc token, given its raw
+// text, without the marker lines.  Whitespace that is common to the start
+// of every non-blank line is removed, whether the heredoc was introduced
+// with `<<' or `<<-'.  All other whitespace, including newlines and the
+// newline before the closing marker, is kept.
+func verbatimHeredoc(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	if len(lines) < 2 {
+		return ""
+	}
+	lines = lines[1 : len(lines)-1]
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent = lineIndent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent) + "\n"
+	}
+	return strings.Join(lines, "")
+}
+
 // parseRoot parses the root of the AST, filling in p.version, p.actions,
-// and p.workflows.
-func (p *Parser) parseRoot(node ast.Node) {
+// and p.workflows.  Blocks that have an entry in cached are not parsed
+// again; see Reparse.
+func (p *Parser) parseRoot(node ast.Node, cached []*blockState) {
 	objectList, ok := node.(*ast.ObjectList)
 	if !ok {
 		// It should be impossible for HCL to return anything other than an
 		// ObjectList as the root node.  This error should never happen.
-		p.addError(node, "Internal error: root node must be an ObjectList")
+		p.addError(node, CodeInternal, "Internal error: root node must be an ObjectList")
 		return
 	}
 
 	p.actions = make([]*model.Action, 0, len(objectList.Items))
 	p.workflows = make([]*model.Workflow, 0, len(objectList.Items))
-	identifiers := make(map[string]bool)
-	for idx, item := range objectList.Items {
+	p.templates = nil
+	p.blocks = make([]*blockState, len(objectList.Items))
+	if p.includes == nil {
+		p.includes = &includeState{done: make(map[string]bool)}
+	}
+
+	// The version decides whether locals are allowed, and the locals are
+	// parsed before everything else that can use them.
+	items := objectList.Items
+	start := 0
+	if len(items) > 0 && items[0].Assign.IsValid() && !p.isInclude(items[0]) {
+		p.parseVersion(0, items[0])
+		start = 1
+	}
+	p.parseLocals(items[start:])
+
+	// identifiers maps each identifier to the file it is defined in, or
+	// "" for this one.
+	identifiers := make(map[string]string)
+	afterBlocks := false
+	for idx := start; idx < len(items); idx++ {
+		item := items[idx]
+		if item.Assign.IsValid() && p.isInclude(item) {
+			p.parseInclude(item, identifiers, afterBlocks)
+			continue
+		}
 		if item.Assign.IsValid() {
 			p.parseVersion(idx, item)
 			continue
 		}
-		p.parseBlock(item, identifiers)
+		afterBlocks = true
+		if p.isLocals(item) {
+			continue
+		}
+		if idx < len(cached) && cached[idx] != nil {
+			p.blocks[idx] = cached[idx]
+		} else {
+			p.blocks[idx] = p.parseBlock(item)
+		}
+		p.addBlock(p.blocks[idx], identifiers)
 	}
+	p.checkUnusedLocals()
 }
 
-// parseBlock parses a single, top-level "action" or "workflow" block,
-// appending it to p.actions or p.workflows as appropriate.
-func (p *Parser) parseBlock(item *ast.ObjectItem, identifiers map[string]bool) {
+// parseBlock parses a single, top-level "action", "workflow", or
+// "template" block.
+// The result depends on nothing outside the block, so Reparse can keep
+// it for as long as the block is unchanged.
+func (p *Parser) parseBlock(item *ast.ObjectItem) *blockState {
+	sub := p.blockParser()
+	state := &blockState{item: item}
+	defer func() {
+		state.errors = sub.errors
+		state.posMap = sub.posMap
+		state.usedLocals = sub.usedLocals
+	}()
+
 	if len(item.Keys) != 2 {
-		p.addError(item, "Invalid toplevel declaration")
+		sub.addError(item, CodeInvalidDeclaration, "Invalid toplevel declaration")
 		if len(item.Keys) < 2 {
-			return
+			return state
 		}
 	}
 
-	cmd := p.identString(item.Keys[0].Token)
-	var id string
+	cmd := sub.identString(item.Keys[0].Token)
 
 	switch cmd {
 	case "action":
-		action := p.actionifyItem(item)
-		if action != nil {
-			id = action.Identifier
-			p.actions = append(p.actions, action)
+		state.action = sub.actionifyItem(item, cmd)
+		if state.action != nil {
+			state.id = state.action.Identifier
+		}
+	case "template":
+		if sub.version < versionTemplates {
+			sub.addError(item, CodeInvalidDeclaration, "`template' blocks require `version = %d' or later", versionTemplates)
+			return state
+		}
+		state.template = sub.actionifyItem(item, cmd)
+		if state.template != nil {
+			state.id = state.template.Identifier
 		}
 	case "workflow":
-		workflow := p.workflowifyItem(item)
-		if workflow != nil {
-			id = workflow.Identifier
-			p.workflows = append(p.workflows, workflow)
+		state.workflow = sub.workflowifyItem(item)
+		if state.workflow != nil {
+			state.id = state.workflow.Identifier
 		}
 	default:
-		p.addError(item, "Invalid toplevel keyword, `%s'", cmd)
+		sub.addError(item, CodeInvalidDeclaration, "Invalid toplevel keyword, `%s'", cmd)
+		return state
+	}
+
+	state.declares = true
+	return state
+}
+
+// addBlock adds the action or workflow from a parsed block, along with
+// its diagnostics, and checks that its identifier is unique.
+func (p *Parser) addBlock(state *blockState, identifiers map[string]string) {
+	p.errors = append(p.errors, state.errors...)
+	for key, node := range state.posMap {
+		p.posMap[key] = node
+	}
+	for name := range state.usedLocals {
+		p.usedLocals[name] = true
+	}
+	if state.action != nil {
+		p.actions = append(p.actions, state.action)
+	}
+	if state.workflow != nil {
+		p.workflows = append(p.workflows, state.workflow)
+	}
+	if state.template != nil {
+		p.templates = append(p.templates, state.template)
+	}
+	if !state.declares {
 		return
 	}
 
-	if identifiers[id] {
-		p.addError(item, "Identifier `%s' redefined", id)
+	if file, ok := identifiers[state.id]; ok {
+		if file == "" {
+			p.addError(state.item, CodeIdentifierRedefined, "Identifier `%s' redefined", state.id)
+		} else {
+			p.addError(state.item, CodeIdentifierRedefined, "Identifier `%s' redefined; it is already defined in %s", state.id, describeFile(file))
+		}
+		return
 	}
 
-	identifiers[id] = true
+	identifiers[state.id] = ""
 }
 
 // parseVersion parses a top-level `version=N` statement, filling in
@@ -471,19 +907,20 @@ func (p *Parser) parseBlock(item *ast.ObjectItem, identifiers map[string]bool) {
 func (p *Parser) parseVersion(idx int, item *ast.ObjectItem) {
 	if len(item.Keys) != 1 || p.identString(item.Keys[0].Token) != "version" {
 		// not a valid `version` declaration
-		p.addError(item.Val, "Toplevel declarations cannot be assignments")
+		p.addError(item.Val, CodeInvalidDeclaration, "Toplevel declarations cannot be assignments")
 		return
 	}
 	if idx != 0 {
-		p.addError(item.Val, "`version` must be the first declaration")
+		p.addError(item.Val, CodeInvalidVersion, "`version` must be the first declaration")
 		return
 	}
 	version, ok := p.literalToInt(item.Val)
 	if !ok {
 		return
 	}
-	if version < minVersion || version > maxVersion {
-		p.addError(item.Val, "`version = %d` is not supported", version)
+	if version < minVersion || version > maxVersion ||
+		version < int64(p.minVersion) || version > int64(p.maxVersion) {
+		p.addError(item.Val, CodeInvalidVersion, "`version = %d` is not supported", version)
 		return
 	}
 	p.version = int(version)
@@ -496,33 +933,34 @@ func (p *Parser) parseVersion(idx int, item *ast.ObjectItem) {
 func (p *Parser) parseIdentifier(key *ast.ObjectKey) (string, bool) {
 	id := key.Token.Text
 	if len(id) < 2 || id[0] != '"' || id[len(id)-1] != '"' {
-		p.addError(key, "Invalid format for identifier `%s'", id)
+		p.addError(key, CodeInvalidIdentifier, "Invalid format for identifier `%s'", id)
 		return "", false
 	}
 
 	ret := id[1 : len(id)-1]
 	if ret == "" {
-		p.addError(key, "Invalid format for identifier `%s'", id)
+		p.addError(key, CodeInvalidIdentifier, "Invalid format for identifier `%s'", id)
 	}
 	return ret, true
 }
 
 // parseRequiredString parses a string value, setting its value into the
 // out-parameter `value` and returning true if successful.
-func (p *Parser) parseRequiredString(value *string, val ast.Node, nodeType, name, id string) bool {
+// Format errors are reported with the given code.
+func (p *Parser) parseRequiredString(value *string, val ast.Node, code Code, nodeType, name, id string) bool {
 	if *value != "" {
-		p.addWarning(val, "`%s' redefined in %s `%s'", name, nodeType, id)
+		p.addWarning(val, CodeAttributeRedefined, "`%s' redefined in %s `%s'", name, nodeType, id)
 		// continue, allowing the redefinition
 	}
 
 	newVal, ok := p.literalToString(val)
 	if !ok {
-		p.addError(val, "Invalid format for `%s' in %s `%s', expected string", name, nodeType, id)
+		p.addError(val, code, "Invalid format for `%s' in %s `%s', expected string", name, nodeType, id)
 		return false
 	}
 
 	if newVal == "" {
-		p.addError(val, "`%s' value in %s `%s' cannot be blank", name, nodeType, id)
+		p.addError(val, code, "`%s' value in %s `%s' cannot be blank", name, nodeType, id)
 		return false
 	}
 
@@ -541,7 +979,7 @@ func (p *Parser) parseBlockPreamble(item *ast.ObjectItem, nodeType string) (stri
 	node := item.Val
 	obj, ok := node.(*ast.ObjectType)
 	if !ok {
-		p.addError(node, "Each %s must have an { ... } block", nodeType)
+		p.addError(node, CodeTypeMismatch, "Each %s must have an { ... } block", nodeType)
 		return "", nil
 	}
 
@@ -550,9 +988,11 @@ func (p *Parser) parseBlockPreamble(item *ast.ObjectItem, nodeType string) (stri
 	return id, obj
 }
 
-// actionifyItem converts an AST block to an Action object.
-func (p *Parser) actionifyItem(item *ast.ObjectItem) *model.Action {
-	id, obj := p.parseBlockPreamble(item, "action")
+// actionifyItem converts an AST block to an Action object.  Templates
+// are converted the same way, with blockType "template".
+func (p *Parser) actionifyItem(item *ast.ObjectItem, blockType string) *model.Action {
+	p.blockType = blockType
+	id, obj := p.parseBlockPreamble(item, blockType)
 	if obj == nil {
 		return nil
 	}
@@ -576,6 +1016,10 @@ func (p *Parser) actionifyItem(item *ast.ObjectItem) *model.Action {
 // gocyclo linter to ignore it.
 // nolint: gocyclo
 func (p *Parser) parseActionAttribute(name string, action *model.Action, val ast.Node) {
+	if !p.checkAttributeVersion(p.blockType, name, val, CodeUnknownActionAttribute) {
+		return
+	}
+
 	switch name {
 	case "uses":
 		p.parseUses(action, val)
@@ -587,10 +1031,12 @@ func (p *Parser) parseActionAttribute(name string, action *model.Action, val ast
 	case "runs":
 		if runs := p.parseCommand(action, action.Runs, name, val, false); runs != nil {
 			action.Runs = runs
+			p.posMap[&action.Runs] = val
 		}
 	case "args":
 		if args := p.parseCommand(action, action.Args, name, val, true); args != nil {
 			action.Args = args
+			p.posMap[&action.Args] = val
 		}
 	case "env":
 		if env := p.literalToStringMap(val); env != nil {
@@ -602,8 +1048,18 @@ func (p *Parser) parseActionAttribute(name string, action *model.Action, val ast
 			action.Secrets = secrets
 			p.posMap[&action.Secrets] = val
 		}
+	case "extends":
+		p.parseExtends(action, val)
+	case "matrix":
+		p.parseMatrix(action, val)
+	case "timeout":
+		p.parseTimeout(action, val)
+	case "retries":
+		p.parseRetries(action, val)
+	case "if":
+		p.parseIf(action, val)
 	default:
-		p.addWarning(val, "Unknown action attribute `%s'", name)
+		p.addWarning(val, CodeUnknownActionAttribute, "Unknown %s attribute `%s'", p.blockType, name)
 	}
 }
 
@@ -611,35 +1067,64 @@ func (p *Parser) parseActionAttribute(name string, action *model.Action, val ast
 // node.  This function enforces formatting requirements on the value.
 func (p *Parser) parseOn(workflow *model.Workflow, node ast.Node) {
 	if workflow.On != nil {
-		p.addWarning(node, "`on' redefined in workflow `%s'", workflow.Identifier)
+		p.addWarning(node, CodeAttributeRedefined, "`on' redefined in workflow `%s'", workflow.Identifier)
 		// continue, allowing the redefinition
 	}
 
-	var strVal string
-	if ok := p.parseRequiredString(&strVal, node, "workflow", "on", workflow.Identifier); !ok {
-		workflow.On = &model.OnInvalid{Raw: strVal}
+	list, ok := node.(*ast.ListType)
+	if !ok {
+		workflow.On = []model.On{p.parseOnValue(workflow, node)}
+		return
+	}
+	if p.version < versionOnList {
+		p.addError(node, CodeInvalidOn, "Workflow `%s' lists several events in `on', which requires `version = %d' or later", workflow.Identifier, versionOnList)
+		workflow.On = []model.On{&model.OnInvalid{}}
 		return
 	}
 
+	workflow.On = make([]model.On, 0, len(list.List))
+	if len(list.List) == 0 {
+		p.addError(node, CodeInvalidOn, "`on' in workflow `%s' must list at least one event", workflow.Identifier)
+	}
+	seen := make(map[string]bool, len(list.List))
+	for _, elem := range list.List {
+		on := p.parseOnValue(workflow, elem)
+		if _, invalid := on.(*model.OnInvalid); !invalid {
+			key := strings.ToLower(on.String())
+			if seen[key] {
+				p.addWarning(elem, CodeInvalidOn, "`%s' is listed more than once in `on' of workflow `%s'", on, workflow.Identifier)
+			}
+			seen[key] = true
+		}
+		workflow.On = append(workflow.On, on)
+	}
+}
+
+// parseOnValue parses a single event or schedule expression from the
+// `on' attribute of a workflow.
+func (p *Parser) parseOnValue(workflow *model.Workflow, node ast.Node) model.On {
+	var strVal string
+	if ok := p.parseRequiredString(&strVal, node, CodeInvalidOn, "workflow", "on", workflow.Identifier); !ok {
+		return &model.OnInvalid{Raw: strVal}
+	}
+
 	if IsSchedule(strVal) {
-		workflow.On = &model.OnSchedule{Expression: strVal}
-		return
+		return &model.OnSchedule{Expression: strVal}
 	}
 
-	if isAllowedEventType(strVal) {
-		workflow.On = &model.OnEvent{Event: strVal}
-		return
+	if p.isAllowedEventType(strVal) {
+		return p.onEvent(strVal)
 	}
 
-	p.addError(node, "Workflow `%s' has an invalid `on' attribute `%s' - must be a known event type or schedule expression", workflow.Identifier, strVal)
-	workflow.On = &model.OnInvalid{Raw: strVal}
+	p.addError(node, CodeInvalidOn, "Workflow `%s' has an invalid `on' attribute `%s' - must be a known event type or schedule expression", workflow.Identifier, strVal)
+	return &model.OnInvalid{Raw: strVal}
 }
 
 // parseUses sets the action.Uses value based on the contents of the AST
 // node.  This function enforces formatting requirements on the value.
 func (p *Parser) parseUses(action *model.Action, node ast.Node) {
 	if action.Uses != nil {
-		p.addWarning(node, "`uses' redefined in action `%s'", action.Identifier)
+		p.addWarning(node, CodeAttributeRedefined, "`uses' redefined in %s `%s'", p.blockType, action.Identifier)
 		// continue, allowing the redefinition
 	}
 	strVal, ok := p.literalToString(node)
@@ -649,37 +1134,40 @@ func (p *Parser) parseUses(action *model.Action, node ast.Node) {
 
 	if strVal == "" {
 		action.Uses = &model.UsesInvalid{}
-		p.addError(node, "`uses' value in action `%s' cannot be blank", action.Identifier)
+		p.addError(node, CodeInvalidUses, "`uses' value in %s `%s' cannot be blank", p.blockType, action.Identifier)
 		return
 	}
+	action.Uses = usesFromString(strVal)
+	if _, ok := action.Uses.(*model.UsesInvalid); ok {
+		p.addError(node, CodeInvalidUses, "The `uses' attribute must be a path, a Docker image, or owner/repo@ref")
+	}
+}
+
+// usesFromString returns the Uses for the value of a `uses' attribute,
+// which is a *model.UsesInvalid if the value is not valid.
+func usesFromString(strVal string) model.Uses {
 	if strings.HasPrefix(strVal, "./") {
-		action.Uses = &model.UsesPath{Path: strings.TrimPrefix(strVal, "./")}
-		return
+		return &model.UsesPath{Path: strings.TrimPrefix(strVal, "./")}
 	}
 
 	if strings.HasPrefix(strVal, "docker://") {
-		action.Uses = &model.UsesDockerImage{Image: strings.TrimPrefix(strVal, "docker://")}
-		return
+		return &model.UsesDockerImage{Image: strings.TrimPrefix(strVal, "docker://")}
 	}
 
 	tok := strings.Split(strVal, "@")
 	if len(tok) != 2 {
-		action.Uses = &model.UsesInvalid{Raw: strVal}
-		p.addError(node, "The `uses' attribute must be a path, a Docker image, or owner/repo@ref")
-		return
+		return &model.UsesInvalid{Raw: strVal}
 	}
 	ref := tok[1]
 	tok = strings.SplitN(tok[0], "/", 3)
 	if len(tok) < 2 {
-		action.Uses = &model.UsesInvalid{Raw: strVal}
-		p.addError(node, "The `uses' attribute must be a path, a Docker image, or owner/repo@ref")
-		return
+		return &model.UsesInvalid{Raw: strVal}
 	}
 	usesRepo := &model.UsesRepository{Repository: tok[0] + "/" + tok[1], Ref: ref}
-	action.Uses = usesRepo
 	if len(tok) == 3 {
 		usesRepo.Path = tok[2]
 	}
+	return usesRepo
 }
 
 // parseCommand sets the action.Runs or action.Args value based on the
@@ -687,7 +1175,7 @@ func (p *Parser) parseUses(action *model.Action, node ast.Node) {
 // requirements on the value.
 func (p *Parser) parseCommand(action *model.Action, cmd model.Command, name string, node ast.Node, allowBlank bool) model.Command {
 	if cmd != nil {
-		p.addWarning(node, "`%s' redefined in action `%s'", name, action.Identifier)
+		p.addWarning(node, CodeAttributeRedefined, "`%s' redefined in %s `%s'", name, p.blockType, action.Identifier)
 		// continue, allowing the redefinition
 	}
 
@@ -702,12 +1190,12 @@ func (p *Parser) parseCommand(action *model.Action, cmd model.Command, name stri
 	// If not, parse a whitespace-separated string into a list.
 	var raw string
 	var ok bool
-	if raw, ok = p.literalToString(node); !ok {
-		p.addError(node, "The `%s' attribute must be a string or a list", name)
+	if raw, ok = p.literalToText(node); !ok {
+		p.addError(node, CodeInvalidCommand, "The `%s' attribute must be a string or a list", name)
 		return nil
 	}
 	if raw == "" && !allowBlank {
-		p.addError(node, "`%s' value in action `%s' cannot be blank", name, action.Identifier)
+		p.addError(node, CodeInvalidCommand, "`%s' value in %s `%s' cannot be blank", name, p.blockType, action.Identifier)
 		return nil
 	}
 	return &model.StringCommand{Value: raw}
@@ -738,31 +1226,129 @@ func (p *Parser) workflowifyItem(item *ast.ObjectItem) *model.Workflow {
 
 	for _, item := range obj.List.Items {
 		name := p.identString(item.Keys[0].Token)
+		if !p.checkAttributeVersion("workflow", name, item.Val, CodeUnknownWorkflowAttribute) {
+			continue
+		}
 
 		switch name {
 		case "on":
 			p.parseOn(workflow, item.Val)
+		case "env":
+			if env := p.literalToStringMap(item.Val); env != nil {
+				workflow.Env = env
+			}
+			p.posMap[&workflow.Env] = item.Val
 		case "resolves":
 			if workflow.Resolves != nil {
-				p.addWarning(item.Val, "`resolves' redefined in workflow `%s'", id)
+				p.addWarning(item.Val, CodeAttributeRedefined, "`resolves' redefined in workflow `%s'", id)
 				// continue, allowing the redefinition
 			}
 			workflow.Resolves, ok = p.literalToStringArray(item.Val, true)
 			p.posMap[&workflow.Resolves] = item
 			if !ok {
-				p.addError(item.Val, "Invalid format for `resolves' in workflow `%s', expected list of strings", id)
+				p.addError(item.Val, CodeInvalidResolves, "Invalid format for `resolves' in workflow `%s', expected list of strings", id)
 				// continue, allowing workflow with no `resolves`
 			}
+		case "types":
+			workflow.Types = p.parseFilter(workflow, workflow.Types, name, item.Val)
+			p.posMap[&workflow.Types] = item.Val
+		case "branches":
+			workflow.Branches = p.parseFilter(workflow, workflow.Branches, name, item.Val)
+			p.posMap[&workflow.Branches] = item.Val
 		default:
-			p.addWarning(item.Val, "Unknown workflow attribute `%s'", name)
+			p.addWarning(item.Val, CodeUnknownWorkflowAttribute, "Unknown workflow attribute `%s'", name)
 			// continue, treat as no-op
 		}
 	}
 
+	p.checkFilters(workflow)
 	p.posMap[workflow] = item
 	return workflow
 }
 
+// parseFilter parses the `types' or `branches' attribute of a workflow,
+// which is a string or a list of non-blank strings.  prev is the value of
+// an earlier definition of the attribute, if any.
+func (p *Parser) parseFilter(workflow *model.Workflow, prev []string, name string, node ast.Node) []string {
+	if prev != nil {
+		p.addWarning(node, CodeAttributeRedefined, "`%s' redefined in workflow `%s'", name, workflow.Identifier)
+		// continue, allowing the redefinition
+	}
+	values, ok := p.literalToStringArray(node, true)
+	if !ok {
+		p.addError(node, CodeInvalidFilter, "Invalid format for `%s' in workflow `%s', expected list of strings", name, workflow.Identifier)
+		return nil
+	}
+	if len(values) == 0 {
+		p.addError(node, CodeInvalidFilter, "`%s' in workflow `%s' must list at least one value", name, workflow.Identifier)
+	}
+	for _, value := range values {
+		if value == "" {
+			p.addError(node, CodeInvalidFilter, "`%s' in workflow `%s' cannot contain a blank value", name, workflow.Identifier)
+			break
+		}
+	}
+	return values
+}
+
+// checkFilters checks the `types' and `branches' of a workflow against the
+// events in its `on' attribute.  Each activity type must belong to at
+// least one of the events, and at least one of the events must be for a
+// branch if there are branch filters.  The filters are not checked if
+// `on' is missing or invalid, since that is already an error.
+func (p *Parser) checkFilters(workflow *model.Workflow) {
+	if workflow.Types == nil && workflow.Branches == nil {
+		return
+	}
+	var events []EventType
+	for _, on := range workflow.On {
+		switch on := on.(type) {
+		case *model.OnInvalid:
+			return
+		case *model.OnEvent:
+			events = append(events, p.eventTypes[strings.ToLower(on.Event)])
+		}
+	}
+	if len(workflow.On) == 0 {
+		return
+	}
+
+	for _, activityType := range workflow.Types {
+		found := false
+		for _, et := range events {
+			if et.hasActivityType(activityType) {
+				found = true
+				break
+			}
+		}
+		if !found && activityType != "" {
+			p.addError(p.posMap[&workflow.Types], CodeInvalidFilter, "`%s' is not an activity type of any event in `on' of workflow `%s'", activityType, workflow.Identifier)
+		}
+	}
+
+	if workflow.Branches != nil {
+		found := false
+		for _, et := range events {
+			found = found || et.Branches
+		}
+		if !found {
+			p.addError(p.posMap[&workflow.Branches], CodeInvalidFilter, "Workflow `%s' has `branches', but none of the events in its `on' attribute are for a branch", workflow.Identifier)
+		}
+	}
+}
+
+// checkAttributeVersion returns true if the file's version allows the
+// attribute name in a block of type nodeType.  If not, it reports the
+// attribute as unknown, with the given code, and returns false.
+func (p *Parser) checkAttributeVersion(nodeType, name string, val ast.Node, code Code) bool {
+	version := attributeVersions[nodeType][name]
+	if p.version >= version {
+		return true
+	}
+	p.addWarning(val, code, "Unknown %s attribute `%s'; it requires `version = %d' or later", nodeType, name, version)
+	return false
+}
+
 func isAssignment(item *ast.ObjectItem) bool {
 	return len(item.Keys) == 1 && item.Assign.IsValid()
 }
@@ -778,7 +1364,7 @@ func (p *Parser) checkAssignmentsOnly(objectList *ast.ObjectList, actionID strin
 			} else {
 				desc = fmt.Sprintf("action `%s'", actionID)
 			}
-			p.addErrorFromObjectItem(item, "Each attribute of %s must be an assignment", desc)
+			p.addErrorFromObjectItem(item, CodeTypeMismatch, "Each attribute of %s must be an assignment", desc)
 			continue
 		}
 
@@ -789,34 +1375,35 @@ func (p *Parser) checkAssignmentsOnly(objectList *ast.ObjectList, actionID strin
 	}
 }
 
-func (p *Parser) addWarning(node ast.Node, format string, a ...interface{}) {
-	if p.suppressSeverity < WARNING {
-		p.errors = append(p.errors, newWarning(posFromNode(node), format, a...))
-	}
+func (p *Parser) addWarning(node ast.Node, code Code, format string, a ...interface{}) {
+	p.addDiagnostic(newWarning(posFromNode(node), code, format, a...))
 }
 
-func (p *Parser) addError(node ast.Node, format string, a ...interface{}) {
-	if p.suppressSeverity < ERROR {
-		p.errors = append(p.errors, newError(posFromNode(node), format, a...))
-	}
+func (p *Parser) addError(node ast.Node, code Code, format string, a ...interface{}) {
+	p.addDiagnostic(newError(posFromNode(node), code, format, a...))
 }
 
-func (p *Parser) addErrorFromToken(t token.Token, format string, a ...interface{}) {
-	if p.suppressSeverity < ERROR {
-		p.errors = append(p.errors, newError(posFromToken(t), format, a...))
-	}
+func (p *Parser) addErrorFromToken(t token.Token, code Code, format string, a ...interface{}) {
+	p.addDiagnostic(newError(posFromToken(t), code, format, a...))
 }
 
-func (p *Parser) addErrorFromObjectItem(objectItem *ast.ObjectItem, format string, a ...interface{}) {
-	if p.suppressSeverity < ERROR {
-		p.errors = append(p.errors, newError(posFromObjectItem(objectItem), format, a...))
-	}
+func (p *Parser) addErrorFromObjectItem(objectItem *ast.ObjectItem, code Code, format string, a ...interface{}) {
+	p.addDiagnostic(newError(posFromObjectItem(objectItem), code, format, a...))
+}
+
+func (p *Parser) addFatal(node ast.Node, code Code, format string, a ...interface{}) {
+	p.addDiagnostic(newFatal(posFromNode(node), code, format, a...))
 }
 
-func (p *Parser) addFatal(node ast.Node, format string, a ...interface{}) {
-	if p.suppressSeverity < FATAL {
-		p.errors = append(p.errors, newFatal(posFromNode(node), format, a...))
+// addDiagnostic records a diagnostic, applying the severity configured
+// for its code, if any.  Diagnostics below the suppression level, or
+// with a code in WithIgnoredCodes, are kept until applySuppressions runs,
+// so that they still count as uses of inline `workflow:ignore' comments.
+func (p *Parser) addDiagnostic(pe *ParseError) {
+	if severity, ok := p.codeSeverities[pe.Code]; ok {
+		pe.Severity = severity
 	}
+	p.errors = append(p.errors, pe)
 }
 
 // posFromNode returns an ErrorPos (file, line, and column) from an AST
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/actions/workflow-parser/model"
 	"github.com/stretchr/testify/assert"
@@ -51,6 +52,20 @@ func TestFileVersion42(t *testing.T) {
 	fixture(t, "invalid/version-42.workflow")
 }
 
+func TestFileVersion1(t *testing.T) {
+	workflow, _ := fixture(t, "valid/version-1.workflow")
+	assert.Equal(t, 1, workflow.Version)
+	flow := workflow.Workflows[0]
+	assert.Equal(t, map[string]string{"GOFLAGS": "-mod=vendor", "LEVEL": "workflow"}, flow.Env)
+	assert.Equal(t, map[string]string{"GOFLAGS": "-mod=vendor", "LEVEL": "workflow"}, flow.ActionEnv(workflow.Actions[0]))
+	assert.Equal(t, map[string]string{"GOFLAGS": "-mod=vendor", "LEVEL": "action"}, flow.ActionEnv(workflow.Actions[1]))
+}
+
+func TestWorkflowEnv(t *testing.T) {
+	fixture(t, "invalid/workflow-env.workflow")
+	fixture(t, "invalid/workflow-env-version-0.workflow")
+}
+
 func TestFileVersionMustComeFirst(t *testing.T) {
 	fixture(t, "invalid/version-must-come-first.workflow")
 }
@@ -63,7 +78,8 @@ func TestUnscopedVariableNames(t *testing.T) {
 func TestScheduleTypes(t *testing.T) {
 	workflow, _ := fixture(t, "valid/schedule-types.workflow")
 	for _, w := range workflow.Workflows {
-		assert.IsType(t, &model.OnSchedule{}, w.On)
+		require.Len(t, w.On, 1)
+		assert.IsType(t, &model.OnSchedule{}, w.On[0])
 	}
 }
 
@@ -77,6 +93,10 @@ func TestBadHCL(t *testing.T) {
 	fixture(t, "invalid/bad-hcl-3.workflow")
 	fixture(t, "invalid/bad-hcl-4.workflow")
 	fixture(t, "invalid/bad-hcl-5.workflow")
+	fixture(t, "invalid/bad-hcl-6.workflow")
+
+	_, err := parseString("action \"a\" {\n  uses = \"./a\"\n  args = \"${x\"\n}\n", WithStrictGrammar())
+	assertParseError(t, err, 0, 0, nil, "line 3: string has a `${' without a matching `}'")
 }
 
 func TestCircularDependencySelf(t *testing.T) {
@@ -89,23 +109,23 @@ func TestCircularDependencyOther(t *testing.T) {
 
 func TestFlowMapping(t *testing.T) {
 	workflow, _ := fixture(t, "valid/flow-mapping.workflow")
-	assert.Equal(t, "push", workflow.Workflows[0].On.String())
-	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On)
+	assert.Equal(t, "push", workflow.Workflows[0].On[0].String())
+	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On[0])
 	assert.ElementsMatch(t, []string{"a", "b"}, workflow.Workflows[0].Resolves)
 }
 
 func TestFlowOneResolve(t *testing.T) {
 	workflow, _ := fixture(t, "valid/one-resolve.workflow")
-	assert.Equal(t, "push", workflow.Workflows[0].On.String())
-	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On)
+	assert.Equal(t, "push", workflow.Workflows[0].On[0].String())
+	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On[0])
 	assert.Len(t, workflow.Workflows[0].Resolves[0], 1)
 	assert.Equal(t, "a", workflow.Workflows[0].Resolves[0])
 }
 
 func TestFlowNoResolves(t *testing.T) {
 	workflow, _ := fixture(t, "valid/no-resolves.workflow")
-	assert.Equal(t, "push", workflow.Workflows[0].On.String())
-	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On)
+	assert.Equal(t, "push", workflow.Workflows[0].On[0].String())
+	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On[0])
 	assert.Len(t, workflow.Workflows[0].Resolves, 0)
 	assert.Empty(t, workflow.Workflows[0].Resolves)
 }
@@ -165,7 +185,7 @@ func TestGetCommandFailure(t *testing.T) {
 func TestCommas(t *testing.T) {
 	workflow, _ := fixture(t, "valid/commas.workflow")
 	for _, ac := range workflow.Actions {
-		assert.Equal(t, map[string]string{"FOO":"1", "BAR":"2", "BAZ":"3"}, ac.Env)
+		assert.Equal(t, map[string]string{"FOO": "1", "BAR": "2", "BAZ": "3"}, ac.Env)
 	}
 }
 
@@ -213,14 +233,14 @@ func TestUsesCustomActionsShortPath(t *testing.T) {
 func TestTwoFlows(t *testing.T) {
 	workflow, _ := fixture(t, "valid/two-flows.workflow")
 
-	assert.Equal(t, "push", workflow.Workflows[0].On.String())
-	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On)
+	assert.Equal(t, "push", workflow.Workflows[0].On[0].String())
+	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[0].On[0])
 	assert.Len(t, workflow.Workflows[0].Resolves[0], 1)
 	assert.Equal(t, []string{"a"}, workflow.Workflows[0].Resolves)
 	assert.Len(t, workflow.GetWorkflows("push"), 1)
 
-	assert.Equal(t, "pull_request", workflow.Workflows[1].On.String())
-	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[1].On)
+	assert.Equal(t, "pull_request", workflow.Workflows[1].On[0].String())
+	assert.IsType(t, &model.OnEvent{}, workflow.Workflows[1].On[0])
 	assert.Len(t, workflow.Workflows[1].Resolves[0], 1)
 	assert.Equal(t, []string{"a", "b"}, workflow.Workflows[1].Resolves)
 	assert.Len(t, workflow.GetWorkflows("pull_request"), 1)
@@ -228,6 +248,178 @@ func TestTwoFlows(t *testing.T) {
 	assert.Len(t, workflow.GetWorkflows("blah"), 0)
 }
 
+func TestMultipleEvents(t *testing.T) {
+	workflow, _ := fixture(t, "valid/multiple-events.workflow")
+	ci := workflow.Workflows[0]
+	assert.Equal(t, []model.On{
+		&model.OnEvent{Event: "push", Branches: true},
+		&model.OnEvent{Event: "pull_request", Branches: true},
+		&model.OnSchedule{Expression: "schedule(0 4 * * *)"},
+	}, ci.On)
+	assert.Equal(t, []*model.Workflow{ci}, workflow.GetWorkflows("pull_request"))
+	assert.Equal(t, []*model.Workflow{ci}, workflow.GetWorkflows("PUSH"))
+	assert.Len(t, workflow.GetWorkflows("release"), 1)
+
+	fixture(t, "invalid/multiple-events.workflow")
+	fixture(t, "invalid/multiple-events-version-1.workflow")
+}
+
+func TestEventFilters(t *testing.T) {
+	workflow, _ := fixture(t, "valid/event-filters.workflow")
+	pulls, pushes := workflow.Workflows[0], workflow.Workflows[1]
+	assert.Equal(t, []string{"opened", "synchronize"}, pulls.Types)
+	assert.Equal(t, []string{"main", "release/*"}, pulls.Branches)
+	assert.Equal(t, []string{"created"}, pushes.Types)
+	assert.Equal(t, []string{"**"}, pushes.Branches)
+
+	event, err := model.NewEvent("pull_request", []byte(`{"action": "opened", "pull_request": {"base": {"ref": "release/v1"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Workflow{pulls}, workflow.TriggeredWorkflows(event))
+	event, err = model.NewEvent("push", []byte(`{"ref": "refs/heads/feature/x"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Workflow{pushes}, workflow.TriggeredWorkflows(event))
+
+	fixture(t, "invalid/event-filters.workflow")
+	fixture(t, "invalid/event-filters-version-2.workflow")
+}
+
+func TestTemplates(t *testing.T) {
+	workflow, _ := fixture(t, "valid/templates.workflow")
+	require.Len(t, workflow.Actions, 3)
+	build, staging, production := workflow.Actions[0], workflow.Actions[1], workflow.Actions[2]
+	assert.Equal(t, &model.Action{
+		Identifier: "build",
+		Uses:       &model.UsesDockerImage{Image: "alpine"},
+		Runs:       &model.StringCommand{Value: "build"},
+		Env:        map[string]string{"REGISTRY": "ghcr.io", "REGION": "us"},
+		Extends:    []string{"docker"},
+	}, build)
+	assert.Equal(t, &model.Action{
+		Identifier: "deploy staging",
+		Uses:       &model.UsesDockerImage{Image: "alpine"},
+		Runs:       &model.StringCommand{Value: "deploy"},
+		Args:       &model.StringCommand{Value: "staging"},
+		Needs:      []string{"build"},
+		Env:        map[string]string{"REGISTRY": "ghcr.io", "REGION": "us"},
+		Secrets:    []string{"TOKEN"},
+		Extends:    []string{"deploy"},
+	}, staging)
+	assert.Equal(t, map[string]string{"REGISTRY": "ghcr.io", "REGION": "eu"}, production.Env)
+	assert.Equal(t, []string{"TOKEN", "PRODUCTION_TOKEN"}, production.Secrets)
+
+	fixture(t, "invalid/templates.workflow")
+	fixture(t, "invalid/templates-version-3.workflow")
+}
+
+func TestMatrix(t *testing.T) {
+	workflow, _ := fixture(t, "valid/matrix.workflow")
+	var ids []string
+	for _, action := range workflow.Actions {
+		ids = append(ids, action.Identifier)
+	}
+	assert.Equal(t, []string{
+		"setup",
+		"test (GO=1.11)",
+		"test (GO=1.12)",
+		"lint (LINTER=golint, OS=linux)",
+		"lint (LINTER=golint, OS=darwin)",
+		"report",
+	}, ids)
+	assert.Equal(t, &model.Action{
+		Identifier: "test (GO=1.12)",
+		Uses:       &model.UsesDockerImage{Image: "golang"},
+		Runs:       &model.StringCommand{Value: "go test ./..."},
+		Needs:      []string{"setup"},
+		Env:        map[string]string{"CGO_ENABLED": "0", "GO": "1.12"},
+		Extends:    []string{"go"},
+		Cell:       map[string]string{"GO": "1.12"},
+	}, workflow.Actions[2])
+	assert.Equal(t, ids[1:5], workflow.Actions[5].Needs)
+	assert.Equal(t, []string{"report"}, workflow.Workflows[0].Resolves)
+
+	fixture(t, "invalid/matrix.workflow")
+	fixture(t, "invalid/matrix-version-5.workflow")
+
+	src := "version = 6\naction \"a\" {\n  uses = \"./a\"\n  matrix = { X = [\"1\", \"2\", \"3\"] }\n}\naction \"b\" {\n  uses = \"./b\"\n  matrix = { Y = [\"1\", \"2\"] }\n}\n"
+	result, err := ParseWithResult(strings.NewReader(src), WithMaxMatrixExpansions(4))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8:12 2 WF216 All matrices combined must not expand to more than 4 actions"}, describeErrors(result.Errors))
+	assert.Len(t, result.Configuration.Actions, 4)
+}
+
+func TestTimeout(t *testing.T) {
+	workflow, _ := fixture(t, "valid/timeout.workflow")
+	require.Len(t, workflow.Actions, 2)
+	build, deploy := workflow.Actions[0], workflow.Actions[1]
+	assert.Equal(t, 90*time.Minute, build.Timeout)
+	assert.Equal(t, 0, build.Retries)
+	assert.Equal(t, 90*time.Second, deploy.Timeout, "the action's own timeout wins")
+	assert.Equal(t, 3, deploy.Retries, "retries are inherited from the template")
+
+	fixture(t, "invalid/timeout.workflow")
+	fixture(t, "invalid/timeout-version-6.workflow")
+}
+
+func TestIf(t *testing.T) {
+	workflow, _ := fixture(t, "valid/if.workflow")
+	require.Len(t, workflow.Actions, 3)
+	test, deploy, notify := workflow.Actions[0], workflow.Actions[1], workflow.Actions[2]
+	assert.Nil(t, test.If)
+	require.NotNil(t, deploy.If)
+	assert.Equal(t, "event.ref == 'refs/heads/main'", deploy.If.String(), "inherited from the template")
+
+	push := &model.Event{Name: "push", Payload: map[string]interface{}{"ref": "refs/tags/v1"}}
+	assert.True(t, test.RunsFor(push))
+	assert.False(t, deploy.RunsFor(push))
+	assert.True(t, notify.RunsFor(push))
+
+	fixture(t, "invalid/if.workflow")
+	fixture(t, "invalid/if-version-7.workflow")
+
+	// Errors point at the column in the condition, allowing for escapes
+	// and multibyte characters before it.
+	src := "version = 8\naction \"a\" {\n  uses = \"./a\"\n  if = \"event.title == '\\\"é\\\"' && 'x'\"\n}\n"
+	result, err := ParseWithResult(strings.NewReader(src))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4:35 2 WF219 Invalid `if' in action `a': `&&' needs booleans, got string"}, describeErrors(result.Errors))
+
+	// And for the values of locals, which are substituted first.
+	for _, c := range []struct {
+		local    string
+		cond     string
+		expected string
+	}{
+		{"event.ref == 'refs/heads/main'", `${local.x} && 'x'`, "5:23 2 WF219 Invalid `if' in action `a': `&&' needs booleans, got string"},
+		{"event.ref == 'refs/heads/main'", `${local.x} == 'x'`, "5:20 2 WF219 Invalid `if' in action `a': unexpected `=='"},
+		{"github.ref", `'x' == ${local.x}`, "5:16 2 WF219 Invalid `if' in action `a': unknown name `github'; conditions can only refer to `event'"},
+	} {
+		src := "version = 9\nlocals { x = \"" + c.local + "\" }\naction \"a\" {\n  uses = \"./a\"\n  if = \"" + c.cond + "\"\n}\n"
+		result, err := ParseWithResult(strings.NewReader(src))
+		require.NoError(t, err)
+		assert.Equal(t, []string{c.expected}, describeErrors(result.Errors), c.cond)
+	}
+}
+
+func TestLocals(t *testing.T) {
+	workflow, _ := fixture(t, "valid/locals.workflow")
+	require.Len(t, workflow.Actions, 2)
+	build, publish := workflow.Actions[0], workflow.Actions[1]
+	assert.Equal(t, &model.UsesDockerImage{Image: "gcr.io/example/builder:v1.2"}, build.Uses)
+	assert.Equal(t, publish.Uses, build.Uses)
+	assert.Equal(t, []string{"sh", "-c", "make -C ${HOME}/src"}, build.Runs.Split(), "shell-style variables are left alone")
+	assert.Equal(t, []string{"push", "gcr.io/example/app:v1.2"}, publish.Args.Split())
+
+	fixture(t, "invalid/locals.workflow")
+	fixture(t, "invalid/locals-version-8.workflow")
+
+	// Before version 9, `${local.NAME}' is an ordinary string.
+	src := "version = 8\naction \"a\" {\n  uses = \"./a\"\n  args = \"${local.x}\"\n}\n"
+	result, err := ParseWithResult(strings.NewReader(src))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, []string{"${local.x}"}, result.Configuration.GetAction("a").Args.Split())
+}
+
 func TestNeeds(t *testing.T) {
 	workflow, _ := fixture(t, "valid/needs.workflow")
 	needsValues := workflow.Actions[0].Needs
@@ -283,12 +475,16 @@ func TestHCLSubset(t *testing.T) {
 	pe := extractParserError(t, err)
 	require.Equal(t, 4, len(pe.Actions))
 	assert.Equal(t, "a", pe.Actions[0].Identifier)
-	assert.Equal(t, "",  pe.Actions[1].Identifier)
+	assert.Equal(t, "", pe.Actions[1].Identifier)
 	assert.Equal(t, "b", pe.Actions[2].Identifier)
 	assert.Equal(t, "c", pe.Actions[3].Identifier)
 	assert.Equal(t, "./foo", pe.Actions[3].Uses.String())
 }
 
+func TestHCLSubsetStrict(t *testing.T) {
+	fixture(t, "invalid/strict-grammar.workflow")
+}
+
 func TestSecrets(t *testing.T) {
 	workflow, _ := fixture(t, "valid/secrets.workflow")
 	assert.Equal(t, 5, len(workflow.Actions[0].Secrets))
@@ -299,18 +495,52 @@ func TestTooManySecrets(t *testing.T) {
 	fixture(t, "invalid/too-many-secrets.workflow")
 }
 
+func TestMaxSecretsOption(t *testing.T) {
+	src := `action "a" { uses="./x" secrets=["A", "B"] } action "b" { uses="./y" secrets=["B", "C"] }`
+	workflow, err := parseString(src)
+	assertParseSuccess(t, err, 2, 0, workflow)
+
+	workflow, err = parseString(src, WithMaxSecrets(2))
+	assertParseError(t, err, 2, 0, workflow,
+		"all actions combined must not have more than 2 unique secrets",
+	)
+}
+
+func TestVersionRangeOption(t *testing.T) {
+	workflow, err := parseString(`version = 0`, WithVersionRange(1, 1))
+	assertParseError(t, err, 0, 0, workflow, "`version = 0` is not supported")
+}
+
+func TestEventTypesOptions(t *testing.T) {
+	src := `workflow "a" { on = "installation" } workflow "b" { on = "push" }`
+	installation := EventType{Name: "Installation", Description: "An app is installed."}
+
+	workflow, err := parseString(src)
+	assertParseError(t, err, 0, 2, workflow, "workflow `a' has an invalid `on' attribute `installation'")
+
+	workflow, err = parseString(src, WithAdditionalEventTypes(installation))
+	assertParseSuccess(t, err, 0, 2, workflow)
+
+	workflow, err = parseString(src, WithEventTypes(installation))
+	assertParseError(t, err, 0, 2, workflow, "workflow `b' has an invalid `on' attribute `push'")
+
+	// The default registry must not be modified by the options.
+	assert.False(t, isAllowedEventType("installation"))
+}
+
 func TestUnknownAttributes(t *testing.T) {
 	fixture(t, "invalid/bad-attributes.workflow")
 }
 
 func TestHeredocs(t *testing.T) {
 	workflow, _ := fixture(t, "valid/heredocs.workflow")
+	assert.Equal(t, model.HeredocCompressed, workflow.Heredocs)
 
 	assert.Equal(t, []string{"a"}, workflow.Workflows[0].Resolves)
-	assert.Equal(t, &model.UsesPath{"x"}, workflow.Actions[0].Uses)
-	assert.Equal(t, &model.UsesPath{"y"}, workflow.Actions[1].Uses)
+	assert.Equal(t, &model.UsesPath{Path: "x"}, workflow.Actions[0].Uses)
+	assert.Equal(t, &model.UsesPath{Path: "y"}, workflow.Actions[1].Uses)
 
-	assert.Equal(t, map[string]string{"a":"b", "c":"foo"}, workflow.Actions[0].Env)
+	assert.Equal(t, map[string]string{"a": "b", "c": "foo"}, workflow.Actions[0].Env)
 	assert.Equal(t, &model.StringCommand{Value: "cmd; 2; 3"}, workflow.Actions[0].Runs)
 	assert.Equal(t, []string{"cmd;", "2;", "3"}, workflow.Actions[0].Runs.Split())
 
@@ -318,6 +548,42 @@ func TestHeredocs(t *testing.T) {
 	assert.Equal(t, []string{"foo", "bar", "baz"}, workflow.Actions[1].Args.Split())
 }
 
+func TestHeredocsPreserved(t *testing.T) {
+	bytes, err := ioutil.ReadFile("../tests/valid/heredocs.workflow")
+	require.NoError(t, err)
+	workflow, err := parseString(string(bytes), WithPreserveHeredocs())
+	assertParseSuccess(t, err, 2, 1, workflow)
+	assert.Equal(t, model.HeredocVerbatim, workflow.Heredocs)
+
+	assert.Equal(t, "push", workflow.Workflows[0].On[0].String())
+	assert.Equal(t, &model.UsesPath{Path: "x"}, workflow.Actions[0].Uses)
+	assert.Equal(t, map[string]string{"a": "b", "c": "foo\n"}, workflow.Actions[0].Env)
+	assert.Equal(t, &model.StringCommand{Value: "cmd;\n2;\n3\n"}, workflow.Actions[0].Runs)
+	assert.Equal(t, &model.StringCommand{Value: "foo\nbar\nbaz\n"}, workflow.Actions[1].Args)
+}
+
+func TestHeredocsPreserveRelativeIndentation(t *testing.T) {
+	workflow, err := parseString("action \"a\" {\n"+
+		"  uses = \"./x\"\n"+
+		"  runs = <<-EOF\n"+
+		"    if true; then\n"+
+		"      echo yes\n"+
+		"\n"+
+		"    fi\n"+
+		"  EOF\n"+
+		"  env = {\n"+
+		"    CERT = <<EOF\n"+
+		"-----BEGIN CERTIFICATE-----\n"+
+		"MIIB\n"+
+		"-----END CERTIFICATE-----\n"+
+		"EOF\n"+
+		"  }\n"+
+		"}\n", WithPreserveHeredocs())
+	assertParseSuccess(t, err, 1, 0, workflow)
+	assert.Equal(t, &model.StringCommand{Value: "if true; then\n  echo yes\n\nfi\n"}, workflow.Actions[0].Runs)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n", workflow.Actions[0].Env["CERT"])
+}
+
 func TestReservedVariables(t *testing.T) {
 	_, err := fixture(t, "invalid/reserved-variables.workflow")
 	pe := extractParserError(t, err)
@@ -368,6 +634,13 @@ func TestUsesForm(t *testing.T) {
 	}
 }
 
+func TestParseErrorString(t *testing.T) {
+	assert.Equal(t, "bad", (&ParseError{message: "bad"}).Error())
+	assert.Equal(t, "Line 3: bad", (&ParseError{message: "bad", Pos: ErrorPos{Line: 3}}).Error())
+	assert.Equal(t, "a.workflow: Line 3: bad", (&ParseError{message: "bad", Pos: ErrorPos{File: "a.workflow", Line: 3}}).Error())
+	assert.Equal(t, "a.workflow: bad", (&ParseError{message: "bad", Pos: ErrorPos{File: "a.workflow"}}).Error())
+}
+
 func TestMultilineErrors(t *testing.T) {
 	_, err := fixture(t, "invalid/bad-on.workflow")
 	require.Error(t, err)
@@ -445,6 +718,30 @@ func parseString(workflowFile string, options ...OptionFunc) (*model.Configurati
 	return Parse(strings.NewReader(workflowFile), options...)
 }
 
+func parseAndValidateString(t *testing.T, src string, options ...OptionFunc) *Parser {
+	root, err := parseHCL([]byte(src))
+	require.NoError(t, err)
+	return parseAndValidate([]byte(src), root, options...)
+}
+
+func parseAndValidateFile(t *testing.T, filename string, options ...OptionFunc) *Parser {
+	bytes, err := ioutil.ReadFile("../tests/" + filename)
+	require.NoError(t, err)
+	return parseAndValidateString(t, string(bytes), options...)
+}
+
+func fixtureFiles(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir("../tests/" + dir)
+	require.NoError(t, err)
+	ret := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), ".workflow") {
+			ret = append(ret, info.Name())
+		}
+	}
+	return ret
+}
+
 func extractParserError(t *testing.T, err error) *Error {
 	if pe, ok := err.(*Error); ok {
 		return pe
@@ -462,11 +759,19 @@ type parseErrorExpectation struct {
 
 type parseExpectation struct {
 	Result       string
+	Options      []string
 	NumActions   int
 	NumWorkflows int
 	Errors       []parseErrorExpectation
 }
 
+// fixtureOptions maps the names that fixtures can list in their
+// "options" to the corresponding parser options.
+var fixtureOptions = map[string]OptionFunc{
+	"strictGrammar": WithStrictGrammar(),
+	"include":       WithFileSystem(DirFileSystem("../tests")),
+}
+
 var assertStartRegexp = regexp.MustCompile(`^#\s*ASSERT\s*{\s*$`)
 var assertEndRegexp = regexp.MustCompile(`^#\s*}`)
 
@@ -518,10 +823,19 @@ func fixture(t *testing.T, filename string) (*model.Configuration, error) {
 	assertions := parseAssertions(t, str)
 	assert.True(t, len(assertions) > 0)
 
+	var options []OptionFunc
+	for _, a := range assertions {
+		for _, name := range a.Options {
+			option, ok := fixtureOptions[name]
+			require.True(t, ok, "unknown fixture option `%s'", name)
+			options = append(options, option)
+		}
+	}
+
 	var workflow *model.Configuration
 	for _, level := range levels {
 		t.Logf("suppressing `%s'", level.ignore)
-		workflow, err = parseString(str, level.args...)
+		workflow, err = parseString(str, append(options, level.args...)...)
 		for _, a := range assertions {
 			switch a.Result {
 			case "failure":
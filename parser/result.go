@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"github.com/actions/workflow-parser/model"
+)
+
+// ParseResult is the outcome of parsing a .workflow file.  Unlike Parse,
+// which discards the configuration whenever the file has problems,
+// ParseResult always carries the best-effort configuration alongside the
+// diagnostics, so callers such as editors can display a partially valid
+// file.
+type ParseResult struct {
+	// Configuration holds every action and workflow that could be
+	// parsed, plus the file's version.  It is never nil, but it is empty
+	// if the file has a syntax error.
+	Configuration *model.Configuration
+
+	// Errors lists all diagnostics that survived suppression, sorted by
+	// line.
+	Errors []*ParseError
+
+	// SyntaxTree is the lossless syntax tree of the file, with each
+	// top-level block linked to its action or workflow.  It is only set
+	// when the file was parsed with WithNativeParser and has no syntax
+	// errors.
+	SyntaxTree *SyntaxTree
+
+	// Includes lists the files that the file includes, directly or
+	// indirectly, including any that could not be read.  The result
+	// depends on their contents as well as the file's.
+	Includes []string
+
+	message string
+
+	// src, options and parser are what Reparse needs to parse the file
+	// again after an edit.  yaml and json are true if the file was parsed
+	// with ParseYAML or ParseJSON.
+	src     []byte
+	options []OptionFunc
+	parser  *Parser
+	yaml    bool
+	json    bool
+}
+
+// HasSeverity returns true if any diagnostic is at or above the given
+// severity.  `result.HasSeverity(parser.WARNING)` is true for any file
+// that will not work with Actions exactly as written.
+func (r *ParseResult) HasSeverity(severity Severity) bool {
+	return r.FirstError(severity) != nil
+}
+
+// FirstError returns the first diagnostic at or above the given severity,
+// or nil if there is none.
+func (r *ParseResult) FirstError(severity Severity) error {
+	for _, pe := range r.Errors {
+		if pe.Severity >= severity {
+			return pe
+		}
+	}
+	return nil
+}
+
+// Err returns all diagnostics as an *Error, in the same form that Parse
+// returns them, or nil if there are no diagnostics.
+func (r *ParseResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return &Error{
+		message:   r.message,
+		Errors:    r.Errors,
+		Actions:   r.Configuration.Actions,
+		Workflows: r.Configuration.Workflows,
+	}
+}
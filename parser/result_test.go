@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWithResultValid(t *testing.T) {
+	result, err := ParseWithResult(strings.NewReader(`version = 0 action "a" { uses = "./x" }`))
+	require.NoError(t, err)
+	require.NotNil(t, result.Configuration)
+	assert.Equal(t, 0, result.Configuration.Version)
+	assert.Len(t, result.Configuration.Actions, 1)
+	assert.Empty(t, result.Errors)
+	assert.False(t, result.HasSeverity(WARNING))
+	assert.NoError(t, result.Err())
+}
+
+func TestParseWithResultKeepsConfiguration(t *testing.T) {
+	result, err := ParseWithResult(strings.NewReader(`
+workflow "w" {
+	on = "push"
+	resolves = ["a", "missing"]
+}
+action "a" {
+	uses = "./x"
+	unknown = "y"
+}
+`))
+	require.NoError(t, err)
+	require.NotNil(t, result.Configuration)
+	assert.Len(t, result.Configuration.Actions, 1)
+	assert.Len(t, result.Configuration.Workflows, 1)
+	require.Len(t, result.Errors, 2)
+
+	assert.True(t, result.HasSeverity(WARNING))
+	assert.True(t, result.HasSeverity(ERROR))
+	assert.False(t, result.HasSeverity(FATAL))
+	assert.Contains(t, result.FirstError(ERROR).Error(), "resolves unknown action `missing'")
+
+	pe := extractParserError(t, result.Err())
+	assert.Len(t, pe.Errors, 2)
+	assert.Equal(t, result.Configuration.Actions, pe.Actions)
+}
+
+func TestParseWithResultSyntaxError(t *testing.T) {
+	result, err := ParseWithResult(strings.NewReader(`action "a" {`))
+	require.NoError(t, err)
+	require.NotNil(t, result.Configuration)
+	assert.Empty(t, result.Configuration.Actions)
+	assert.True(t, result.HasSeverity(FATAL))
+	assert.Contains(t, result.Err().Error(), "unable to parse\n")
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/actions/workflow-parser/model"
+)
+
+// schemaID is the $id of the schema that ModelSchema returns, which is
+// committed as model.schema.json.
+const schemaID = "https://github.com/actions/workflow-parser/model.schema.json"
+
+// The formats of the parts of a `uses' attribute, as usesFromString
+// splits them: owner/repo, the path in the repository, and the ref.
+// None of them has an `@'.
+var (
+	usesRepositoryRe = regexp.MustCompile(`\A[^/@]+/[^/@]+\z`)
+	usesRepoPathRe   = regexp.MustCompile(`\A[^@]*\z`)
+	usesRefRe        = regexp.MustCompile(`\A[^@]+\z`)
+)
+
+// schema is a JSON Schema, or a part of one.
+type schema map[string]interface{}
+
+// ModelSchema returns a JSON Schema (draft-07) for the JSON encoding of a
+// *model.Configuration, as encoding/json makes it, for programs in other
+// languages that read or write configurations.  It describes the
+// configurations that the parser returns for files without errors:
+// events are those of EventTypes, in any case, and `uses' and `on'
+// cannot be invalid.  Names of environment variables and secrets must be
+// those that the parser does not warn about.  Timeouts are in
+// nanoseconds, as time.Duration is encoded.
+//
+// The schema is committed as model.schema.json; `parser schema' prints
+// it.
+func ModelSchema() []byte {
+	var events []string
+	for _, et := range EventTypes() {
+		events = append(events, et.Name)
+	}
+
+	s := schema{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         schemaID,
+		"title":       "Workflow configuration",
+		"description": "The JSON encoding of model.Configuration, as returned by the parser for a file without errors.  Generated by parser.ModelSchema; do not edit.",
+		"type":        "object",
+		"properties": schema{
+			"Version":   schema{"type": "integer", "minimum": 0, "maximum": maxVersion},
+			"Heredocs":  schema{"enum": []model.HeredocMode{model.HeredocCompressed, model.HeredocVerbatim}},
+			"Actions":   nullable(schema{"type": "array", "items": ref("Action")}),
+			"Workflows": nullable(schema{"type": "array", "items": ref("Workflow")}),
+		},
+		"required":             []string{"Version", "Heredocs", "Actions", "Workflows"},
+		"additionalProperties": false,
+		"definitions": schema{
+			"Action": object(schema{
+				"Identifier": schema{"type": "string"},
+				"Uses": schema{"oneOf": []schema{
+					ref("UsesDockerImage"),
+					ref("UsesRepository"),
+					ref("UsesPath"),
+				}},
+				"Runs":    nullable(ref("Command")),
+				"Args":    nullable(ref("Command")),
+				"Needs":   nullable(stringArray()),
+				"Env":     nullable(ref("Env")),
+				"Secrets": nullable(schema{"type": "array", "items": ref("VariableName")}),
+				"Extends": nullable(stringArray()),
+				"Matrix": nullable(schema{
+					"type":                 "object",
+					"propertyNames":        ref("VariableName"),
+					"additionalProperties": stringArray(),
+				}),
+				"Cell": nullable(ref("Env")),
+				"Timeout": schema{
+					"description": "Nanoseconds, or 0 for no limit of its own.",
+					"type":        "integer",
+					"anyOf": []schema{
+						{"const": 0},
+						{"minimum": minTimeout, "maximum": maxTimeout, "multipleOf": 1000000000},
+					},
+				},
+				"Retries": schema{"type": "integer", "minimum": 0, "maximum": maxRetries},
+				"If": schema{
+					"description": "The source of the condition.",
+					"type":        []string{"string", "null"},
+				},
+			}),
+			"Workflow": object(schema{
+				"Identifier": schema{"type": "string"},
+				"On": schema{
+					"type":     "array",
+					"items":    schema{"oneOf": []schema{ref("OnEvent"), ref("OnSchedule")}},
+					"minItems": 1,
+				},
+				"Resolves": nullable(stringArray()),
+				"Env":      nullable(ref("Env")),
+				"Types":    nullable(stringArray()),
+				"Branches": nullable(stringArray()),
+			}),
+			"UsesDockerImage": object(schema{
+				"Image": schema{"type": "string", "minLength": 1},
+			}),
+			"UsesRepository": object(schema{
+				"Repository": pattern(usesRepositoryRe),
+				"Path":       pattern(usesRepoPathRe),
+				"Ref":        pattern(usesRefRe),
+			}),
+			"UsesPath": object(schema{
+				"Path": schema{"description": "The path after `./'.", "type": "string"},
+			}),
+			"Command": schema{"oneOf": []schema{
+				ref("StringCommand"),
+				ref("ListCommand"),
+			}},
+			"StringCommand": object(schema{
+				"Value": schema{"type": "string"},
+			}),
+			"ListCommand": object(schema{
+				"Values": nullable(stringArray()),
+			}),
+			"OnEvent": object(schema{
+				"Event": schema{
+					"description": "An event, in any case.",
+					"type":        "string",
+					"pattern":     caseInsensitive(events),
+				},
+				"Branches": schema{
+					"description": "Whether the event is for a branch, so that the workflow's Branches apply to it.",
+					"type":        "boolean",
+				},
+			}),
+			"OnSchedule": object(schema{
+				"Expression": pattern(scheduleRegex),
+			}),
+			"Env": schema{
+				"type":                 "object",
+				"propertyNames":        ref("VariableName"),
+				"additionalProperties": schema{"type": "string"},
+			},
+			"VariableName": pattern(envVarChecker),
+		},
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append(b, '\n')
+}
+
+// object returns the schema of a struct with properties, which the
+// encoding always has all of.
+func object(properties schema) schema {
+	var required []string
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return schema{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// nullable returns a schema that also allows null, which is how nil
+// slices, maps, and pointers are encoded.
+func nullable(s schema) schema {
+	return schema{"oneOf": []schema{{"type": "null"}, s}}
+}
+
+func ref(definition string) schema {
+	return schema{"$ref": "#/definitions/" + definition}
+}
+
+func stringArray() schema {
+	return schema{"type": "array", "items": schema{"type": "string"}}
+}
+
+// caseInsensitive returns a pattern that matches any of names in any
+// case, as the parser matches events.  The regular expressions of JSON
+// Schema have no flag for that.
+func caseInsensitive(names []string) string {
+	alternatives := make([]string, len(names))
+	for i, name := range names {
+		var b strings.Builder
+		for _, r := range name {
+			if upper := unicode.ToUpper(r); upper != r {
+				b.WriteString("[" + string(r) + string(upper) + "]")
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		alternatives[i] = b.String()
+	}
+	return "^(?:" + strings.Join(alternatives, "|") + ")$"
+}
+
+// pattern returns the schema of a string that re matches.  re must be
+// anchored with \A and \z, which become ^ and $, and otherwise be written
+// the same way in RE2 and in the regular expressions of JSON Schema.
+func pattern(re *regexp.Regexp) schema {
+	expr := re.String()
+	if !strings.HasPrefix(expr, `\A`) || !strings.HasSuffix(expr, `\z`) {
+		panic("parser: pattern for the schema is not anchored: " + expr)
+	}
+	return schema{"type": "string", "pattern": "^" + expr[2:len(expr)-2] + "$"}
+}
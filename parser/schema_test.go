@@ -0,0 +1,331 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/actions/workflow-parser/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestModelSchemaFile checks that model.schema.json is what ModelSchema
+// returns.
+func TestModelSchemaFile(t *testing.T) {
+	b, err := ioutil.ReadFile("../model.schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(ModelSchema()), string(b), "model.schema.json is out of date; run `go run cmd/main.go schema > model.schema.json'")
+}
+
+// TestModelSchemaFields checks that the definitions of the schema have
+// the fields of the model types.
+func TestModelSchemaFields(t *testing.T) {
+	s := decodeSchema(t)
+	for _, v := range []interface{}{
+		model.Configuration{},
+		model.Action{},
+		model.Workflow{},
+		model.UsesDockerImage{},
+		model.UsesRepository{},
+		model.UsesPath{},
+		model.StringCommand{},
+		model.ListCommand{},
+		model.OnEvent{},
+		model.OnSchedule{},
+	} {
+		typ := reflect.TypeOf(v)
+		def := s
+		if typ.Name() != "Configuration" {
+			def, _ = s["definitions"].(map[string]interface{})[typ.Name()].(map[string]interface{})
+			require.NotNil(t, def, typ.Name())
+		}
+		var expected, actual []string
+		for i := 0; i < typ.NumField(); i++ {
+			expected = append(expected, typ.Field(i).Name)
+		}
+		for name := range def["properties"].(map[string]interface{}) {
+			actual = append(actual, name)
+		}
+		sort.Strings(expected)
+		sort.Strings(actual)
+		assert.Equal(t, expected, actual, typ.Name())
+	}
+}
+
+// TestModelSchemaFixtures checks that the configurations of the valid
+// fixtures match the schema.
+func TestModelSchemaFixtures(t *testing.T) {
+	s := decodeSchema(t)
+	for _, filename := range fixtureFiles(t, "valid") {
+		b, err := ioutil.ReadFile("../tests/valid/" + filename)
+		require.NoError(t, err)
+		var options []OptionFunc
+		for _, a := range parseAssertions(t, string(b)) {
+			for _, name := range a.Options {
+				options = append(options, fixtureOptions[name])
+			}
+		}
+		result, err := ParseWithResult(bytes.NewReader(b), options...)
+		require.NoError(t, err, filename)
+		require.Empty(t, describeErrors(result.Errors), filename)
+		assert.Empty(t, schemaErrors(t, s, result.Configuration), filename)
+	}
+}
+
+func TestModelSchemaRejects(t *testing.T) {
+	s := decodeSchema(t)
+	var events []string
+	for _, et := range EventTypes() {
+		events = append(events, et.Name)
+	}
+	config := &model.Configuration{
+		Version: 1,
+		Actions: []*model.Action{
+			{Identifier: "a", Uses: &model.UsesRepository{Repository: "owner", Ref: "master"}, Env: map[string]string{"A-B": "1"}, Timeout: 1},
+			{Identifier: "b", Uses: &model.UsesInvalid{Raw: "x"}, Secrets: []string{"1A"}},
+		},
+		Workflows: []*model.Workflow{
+			{Identifier: "w", On: []model.On{&model.OnEvent{Event: "tag"}, &model.OnSchedule{Expression: "schedule(*"}}},
+		},
+	}
+	assert.Equal(t, []string{
+		"/Actions/0/Env: name `A-B' does not match ^[A-Za-z_][A-Za-z_0-9]*$",
+		"/Actions/0/Timeout: matches none of anyOf",
+		"/Actions/0/Uses/Repository: `owner' does not match ^[^/@]+/[^/@]+$",
+		"/Actions/1/Secrets/0: `1A' does not match ^[A-Za-z_][A-Za-z_0-9]*$",
+		"/Actions/1/Uses: has no Image",
+		"/Actions/1/Uses: has unknown property Raw",
+		"/Workflows/0/On/0/Event: `tag' does not match " + caseInsensitive(events),
+		"/Workflows/0/On/1/Expression: `schedule(*' does not match ^schedule\\([^)]*\\)$",
+	}, schemaErrors(t, s, config))
+
+	// The tests above would pass for a schema that allowed nothing.
+	assert.Empty(t, schemaErrors(t, s, &model.Configuration{}))
+}
+
+func TestSchemaPattern(t *testing.T) {
+	assert.Equal(t, "^[A-Za-z_][A-Za-z_0-9]*$", pattern(envVarChecker)["pattern"])
+	assert.Equal(t, `^schedule\([^)]*\)$`, pattern(scheduleRegex)["pattern"])
+	assert.Panics(t, func() { pattern(regexp.MustCompile(`^a$`)) })
+
+	re := regexp.MustCompile(caseInsensitive([]string{"push", "check_run"}))
+	assert.Equal(t, `^(?:[pP][uU][sS][hH]|[cC][hH][eE][cC][kK]_[rR][uU][nN])$`, re.String())
+	assert.True(t, re.MatchString("Check_RUN"))
+	assert.False(t, re.MatchString("pushx"))
+}
+
+// TestUsesPatterns checks that the patterns for UsesRepository match how
+// usesFromString splits the values that the parser accepts.
+func TestUsesPatterns(t *testing.T) {
+	for _, str := range []string{
+		"owner/repo@master",
+		"owner/repo/path@v1",
+		"owner/repo/deep/path/@refs/heads/a-b",
+		"o.w-n_er/r.e-p_o@1234abcd",
+	} {
+		uses, ok := usesFromString(str).(*model.UsesRepository)
+		require.True(t, ok, str)
+		assert.Regexp(t, usesRepositoryRe, uses.Repository, str)
+		assert.Regexp(t, usesRepoPathRe, uses.Path, str)
+		assert.Regexp(t, usesRefRe, uses.Ref, str)
+	}
+}
+
+func decodeSchema(t *testing.T) map[string]interface{} {
+	var s map[string]interface{}
+	require.NoError(t, json.Unmarshal(ModelSchema(), &s))
+	return s
+}
+
+// schemaErrors validates the JSON encoding of config against the schema
+// root, returning the errors sorted.  It only knows the keywords that
+// ModelSchema uses.
+func schemaErrors(t *testing.T, root map[string]interface{}, config *model.Configuration) []string {
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+	var value interface{}
+	require.NoError(t, json.Unmarshal(b, &value))
+
+	var errs []string
+	validateSchema(t, root, root, value, "", &errs)
+	sort.Strings(errs)
+	return errs
+}
+
+func validateSchema(t *testing.T, root, s map[string]interface{}, value interface{}, path string, errs *[]string) {
+	fail := func(format string, a ...interface{}) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, a...))
+	}
+	matches := func(sub interface{}) bool {
+		var subErrs []string
+		validateSchema(t, root, sub.(map[string]interface{}), value, path, &subErrs)
+		return len(subErrs) == 0
+	}
+
+	for key, arg := range s {
+		switch key {
+		case "$schema", "$id", "title", "description", "definitions":
+		case "$ref":
+			ref := strings.TrimPrefix(arg.(string), "#/definitions/")
+			def, ok := root["definitions"].(map[string]interface{})[ref].(map[string]interface{})
+			require.True(t, ok, "unknown $ref %s", arg)
+			validateSchema(t, root, def, value, path, errs)
+		case "type":
+			types, ok := arg.([]interface{})
+			if !ok {
+				types = []interface{}{arg}
+			}
+			matched := false
+			for _, typ := range types {
+				matched = matched || jsonType(value) == typ || typ == "number" && jsonType(value) == "integer"
+			}
+			if !matched {
+				fail("%s is not of type %v", jsonType(value), arg)
+			}
+		case "enum":
+			found := false
+			for _, v := range arg.([]interface{}) {
+				found = found || reflect.DeepEqual(v, value)
+			}
+			if !found {
+				fail("%v is not in the enum", value)
+			}
+		case "const":
+			if !reflect.DeepEqual(arg, value) {
+				fail("%v is not %v", value, arg)
+			}
+		case "oneOf":
+			// If nothing matches, the errors are those of the closest
+			// match, which has the type of the value.
+			n := 0
+			var closest []string
+			for _, sub := range arg.([]interface{}) {
+				var subErrs []string
+				validateSchema(t, root, sub.(map[string]interface{}), value, path, &subErrs)
+				if len(subErrs) == 0 {
+					n++
+				} else if !hasTypeError(subErrs, path) && (closest == nil || len(subErrs) < len(closest)) {
+					closest = subErrs
+				}
+			}
+			if n == 0 && closest != nil {
+				*errs = append(*errs, closest...)
+			} else if n != 1 {
+				fail("matches %d of oneOf", n)
+			}
+		case "anyOf":
+			matched := false
+			for _, sub := range arg.([]interface{}) {
+				matched = matched || matches(sub)
+			}
+			if !matched {
+				fail("matches none of anyOf")
+			}
+		case "pattern":
+			if str, ok := value.(string); ok && !regexp.MustCompile(arg.(string)).MatchString(str) {
+				fail("`%s' does not match %s", str, arg)
+			}
+		case "minLength":
+			if str, ok := value.(string); ok && float64(len(str)) < arg.(float64) {
+				fail("`%s' is shorter than %v", str, arg)
+			}
+		case "minimum":
+			if n, ok := value.(float64); ok && n < arg.(float64) {
+				fail("%v is less than %v", n, arg)
+			}
+		case "maximum":
+			if n, ok := value.(float64); ok && n > arg.(float64) {
+				fail("%v is more than %v", n, arg)
+			}
+		case "multipleOf":
+			if n, ok := value.(float64); ok && math.Mod(n, arg.(float64)) != 0 {
+				fail("%v is not a multiple of %v", n, arg)
+			}
+		case "minItems":
+			if list, ok := value.([]interface{}); ok && float64(len(list)) < arg.(float64) {
+				fail("has fewer than %v items", arg)
+			}
+		case "items":
+			if list, ok := value.([]interface{}); ok {
+				for i, v := range list {
+					validateSchema(t, root, arg.(map[string]interface{}), v, fmt.Sprintf("%s/%d", path, i), errs)
+				}
+			}
+		case "required":
+			if obj, ok := value.(map[string]interface{}); ok {
+				for _, name := range arg.([]interface{}) {
+					if _, ok := obj[name.(string)]; !ok {
+						fail("has no %s", name)
+					}
+				}
+			}
+		case "properties", "additionalProperties", "propertyNames":
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			properties, _ := s["properties"].(map[string]interface{})
+			for name, v := range obj {
+				switch {
+				case key == "properties" && properties[name] != nil:
+					validateSchema(t, root, properties[name].(map[string]interface{}), v, path+"/"+name, errs)
+				case key == "additionalProperties" && properties[name] == nil:
+					if arg == false {
+						fail("has unknown property %s", name)
+					} else {
+						validateSchema(t, root, arg.(map[string]interface{}), v, path+"/"+name, errs)
+					}
+				case key == "propertyNames":
+					var nameErrs []string
+					validateSchema(t, root, arg.(map[string]interface{}), name, path, &nameErrs)
+					for _, e := range nameErrs {
+						fail("name %s", strings.TrimPrefix(e, path+": "))
+					}
+				}
+			}
+		default:
+			require.Fail(t, "unknown keyword", key)
+		}
+	}
+}
+
+// hasTypeError returns true if errs says that the value at path is not
+// of the type of the schema.
+func hasTypeError(errs []string, path string) bool {
+	for _, e := range errs {
+		if strings.HasPrefix(e, path+": ") && strings.Contains(e, " is not of type ") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a value that encoding/json
+// decoded.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
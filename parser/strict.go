@@ -0,0 +1,464 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/token"
+)
+
+// The lexical rules of the grammar in language.md, applied to the raw
+// text of STRING tokens, including the quotes.
+var (
+	quotedIdentifierRe = regexp.MustCompile(`\A"[a-zA-Z_][a-zA-Z0-9_]*"\z`)
+	scheduleStringRe   = regexp.MustCompile(`\A"schedule\([^"\\\x00-\x1f\x7f]*\)"\z`)
+	localUsesRe        = regexp.MustCompile(`\A"\./[^"\\\x00-\x1f\x7f]*"\z`)
+	dockerUsesRe       = regexp.MustCompile(`\A"docker://` +
+		`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+		`[a-zA-Z0-9]+(?:[._-][a-zA-Z0-9]+)*(?:/[a-zA-Z0-9]+(?:[._-][a-zA-Z0-9]+)*)*` +
+		`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]*|@[A-Za-z][A-Za-z0-9]*(?:[-+._][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]+)?"\z`)
+	remoteUsesRe = regexp.MustCompile(`\A"` +
+		`[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?/[a-zA-Z0-9_.-]+(?:/[^/"]+)*/?` +
+		`@[^/"?*\[ ^~:\\\x00-\x1f][^"?*\[ ^~:\\\x00-\x1f]*"\z`)
+	integerRe = regexp.MustCompile(`\A[0-9]+\z`)
+)
+
+// grammarChecker is a recognizer for the ANTLR grammar in language.md.
+// It runs over the tokens of lex, but only sees files that have already
+// parsed, and reports everything that the parsers accept but the grammar
+// does not.  After an error, it skips to the end of the current block and
+// carries on with the next one.
+type grammarChecker struct {
+	p      *Parser
+	tokens []token.Token
+	pos    int
+
+	// depth is the number of unclosed `{' and `[' so far, and last is the
+	// most recently consumed token.
+	depth int
+	last  token.Token
+
+	// fileVersion is the file's `version = N', which decides the attributes
+	// that blocks may have.
+	fileVersion int
+}
+
+// checkStrictGrammar reports an error for each part of src that does not
+// conform to the grammar in language.md.
+func (p *Parser) checkStrictGrammar(src []byte) {
+	c := &grammarChecker{p: p}
+	for _, lt := range lex(src) {
+		if lt.kind == TokenWhitespace {
+			continue
+		}
+		tok := lt.astToken()
+		if tok.Type == token.COMMENT {
+			c.comment(tok)
+			continue
+		}
+		c.tokens = append(c.tokens, tok)
+		if tok.Type == token.EOF || tok.Type == token.ILLEGAL {
+			break
+		}
+	}
+
+	c.file()
+}
+
+// file : version? include* (locals | workflow | action | template)* ;
+func (c *grammarChecker) file() {
+	if tok := c.peek(); tok.Type == token.IDENT && tok.Text == "version" {
+		c.version()
+	}
+	for tok := c.peek(); c.fileVersion >= versionInclude && tok.Type == token.IDENT && tok.Text == "include"; tok = c.peek() {
+		c.include()
+	}
+	for c.peek().Type != token.EOF && c.peek().Type != token.ILLEGAL {
+		if !c.block() {
+			c.skipBlock()
+		}
+	}
+}
+
+// version : 'version' '=' INTEGER ;
+func (c *grammarChecker) version() bool {
+	c.next()
+	if !c.expect(token.ASSIGN) {
+		return false
+	}
+	tok := c.peek()
+	if !c.integer() {
+		return false
+	}
+	c.fileVersion, _ = strconv.Atoi(tok.Text)
+	return true
+}
+
+// INTEGER : [0-9]+ ;
+func (c *grammarChecker) integer() bool {
+	tok := c.next()
+	if tok.Type != token.NUMBER || !integerRe.MatchString(tok.Text) {
+		c.errorf(tok, "expected decimal integer, got %s", describeToken(tok))
+		return false
+	}
+	return true
+}
+
+// include : 'include' '=' LOCAL_USES ;
+func (c *grammarChecker) include() bool {
+	c.next()
+	if !c.expect(token.ASSIGN) {
+		return false
+	}
+	return c.stringMatching("path", localUsesRe)
+}
+
+// workflow : 'workflow' str '{' (on_kvp | resolves_kvp | env_kvp | types_kvp | branches_kvp)* '}' ;
+// action : 'action' str '{' action_kvps '}' ;
+// template : 'template' str '{' action_kvps '}' ;
+// locals : 'locals' '{' local_var* '}' ;
+// local_var : IDENTIFIER '=' str ','? ;
+func (c *grammarChecker) block() bool {
+	tok := c.next()
+	if tok.Type != token.IDENT {
+		c.errorf(tok, "expected identifier, got %s", describeToken(tok))
+		return false
+	}
+
+	var attributes map[string]func() bool
+	switch {
+	case tok.Text == "workflow":
+		attributes = c.workflowAttributes()
+	case tok.Text == "action":
+		attributes = c.actionAttributes()
+	case tok.Text == "template" && c.fileVersion >= versionTemplates:
+		attributes = c.actionAttributes()
+	case tok.Text == "locals" && c.fileVersion >= versionLocals:
+		return c.env()
+	case c.fileVersion >= versionLocals:
+		c.errorf(tok, "expected `workflow', `action', `template', or `locals', got %s", describeToken(tok))
+		return false
+	case c.fileVersion >= versionTemplates:
+		c.errorf(tok, "expected `workflow', `action', or `template', got %s", describeToken(tok))
+		return false
+	default:
+		c.errorf(tok, "expected `workflow' or `action', got %s", describeToken(tok))
+		return false
+	}
+
+	if !c.str() || !c.expect(token.LBRACE) {
+		return false
+	}
+	for c.peek().Type != token.RBRACE && c.peek().Type != token.EOF {
+		if !c.attribute(attributes) {
+			return false
+		}
+	}
+	return c.expect(token.RBRACE)
+}
+
+func (c *grammarChecker) workflowAttributes() map[string]func() bool {
+	return c.versioned("workflow", map[string]func() bool{
+		"on":       c.on,
+		"resolves": c.stringOrArray,
+		"env":      c.env,
+		"types":    c.stringOrArray,
+		"branches": c.stringOrArray,
+	})
+}
+
+func (c *grammarChecker) actionAttributes() map[string]func() bool {
+	return c.versioned("action", map[string]func() bool{
+		"uses":    c.uses,
+		"needs":   c.stringOrArray,
+		"runs":    c.stringOrArray,
+		"args":    c.stringOrArray,
+		"env":     c.env,
+		"secrets": c.secrets,
+		"extends": c.stringOrArray,
+		"matrix":  c.matrix,
+		"timeout": c.timeout,
+		"retries": c.integer,
+		"if":      c.str,
+	})
+}
+
+// versioned removes the attributes that the file's version does not
+// allow yet; see attributeVersions.
+func (c *grammarChecker) versioned(nodeType string, attributes map[string]func() bool) map[string]func() bool {
+	for name := range attributes {
+		if attributeVersions[nodeType][name] > c.fileVersion {
+			delete(attributes, name)
+		}
+	}
+	return attributes
+}
+
+// attribute parses a single `key = value' pair, where the key must be one
+// of the given attributes.
+func (c *grammarChecker) attribute(attributes map[string]func() bool) bool {
+	key := c.next()
+	if key.Type != token.IDENT {
+		c.errorf(key, "expected identifier, got %s", describeToken(key))
+		return false
+	}
+	value, ok := attributes[key.Text]
+	if !ok {
+		c.errorf(key, "unexpected attribute `%s'", key.Text)
+		return false
+	}
+	return c.expect(token.ASSIGN) && value()
+}
+
+// on_kvp : 'on' '=' (on_value | on_array) ;
+// on_value : event_string | SCHEDULE_STRING ;
+// on_array : '[' ((on_value ',')* on_value ','?)? ']' ;
+// event_string : QUOTED_IDENTIFIER ;
+func (c *grammarChecker) on() bool {
+	if c.fileVersion >= versionOnList && c.peek().Type == token.LBRACK {
+		return c.array(c.onValue)
+	}
+	return c.onValue()
+}
+
+func (c *grammarChecker) onValue() bool {
+	return c.stringMatching("event name or schedule expression", quotedIdentifierRe, scheduleStringRe)
+}
+
+// uses_kvp : 'uses' '=' (DOCKER_USES | LOCAL_USES | REMOTE_USES) ;
+func (c *grammarChecker) uses() bool {
+	return c.stringMatching("Docker image, path, or owner/repo@ref", dockerUsesRe, localUsesRe, remoteUsesRe)
+}
+
+// env_kvp : 'env' '=' '{' env_var* '}' ;
+// env_var : IDENTIFIER '=' str ','? ;
+func (c *grammarChecker) env() bool {
+	if !c.expect(token.LBRACE) {
+		return false
+	}
+	for c.peek().Type != token.RBRACE && c.peek().Type != token.EOF {
+		key := c.next()
+		if key.Type != token.IDENT {
+			c.errorf(key, "expected identifier, got %s", describeToken(key))
+			return false
+		}
+		if !c.expect(token.ASSIGN) || !c.str() {
+			return false
+		}
+		if c.peek().Type == token.COMMA {
+			c.next()
+		}
+	}
+	return c.expect(token.RBRACE)
+}
+
+// matrix_kvp : 'matrix' '=' '{' matrix_var* '}' ;
+// matrix_var : IDENTIFIER '=' string_or_array ','? ;
+func (c *grammarChecker) matrix() bool {
+	if !c.expect(token.LBRACE) {
+		return false
+	}
+	for c.peek().Type != token.RBRACE && c.peek().Type != token.EOF {
+		key := c.next()
+		if key.Type != token.IDENT {
+			c.errorf(key, "expected identifier, got %s", describeToken(key))
+			return false
+		}
+		if !c.expect(token.ASSIGN) || !c.stringOrArray() {
+			return false
+		}
+		if c.peek().Type == token.COMMA {
+			c.next()
+		}
+	}
+	return c.expect(token.RBRACE)
+}
+
+// timeout_kvp : 'timeout' '=' TIMEOUT_STRING ;
+// TIMEOUT_STRING : '"' ([0-9]+ 'h')? ([0-9]+ 'm')? ([0-9]+ 's')? '"' ;
+func (c *grammarChecker) timeout() bool {
+	// The durations are those that parseTimeout allows, which have no
+	// escapes, so the string between the quotes is the value.
+	if tok := c.peek(); tok.Type == token.STRING && timeoutRe.MatchString(tok.Text[1:len(tok.Text)-1]) {
+		c.next()
+		return true
+	}
+	return c.stringMatching("duration such as \"10m\"")
+}
+
+// secrets_kvp : 'secrets' '=' ident_array ;
+// ident_array : '[' ((QUOTED_IDENTIFIER ',')* QUOTED_IDENTIFIER ','?)? ']';
+func (c *grammarChecker) secrets() bool {
+	return c.array(func() bool {
+		return c.stringMatching("quoted identifier", quotedIdentifierRe)
+	})
+}
+
+// string_or_array : str | string_array ;
+// string_array : '[' (( str ',' )* str ','?)? ']' ;
+func (c *grammarChecker) stringOrArray() bool {
+	if c.peek().Type == token.LBRACK {
+		return c.array(c.str)
+	}
+	return c.str()
+}
+
+func (c *grammarChecker) array(element func() bool) bool {
+	if !c.expect(token.LBRACK) {
+		return false
+	}
+	for c.peek().Type != token.RBRACK {
+		if !element() {
+			return false
+		}
+		if c.peek().Type != token.COMMA {
+			break
+		}
+		c.next()
+	}
+	return c.expect(token.RBRACK)
+}
+
+// str : QUOTED_IDENTIFIER | STRING;
+// STRING : '"' ( ESC | SAFECODEPOINT )* '"' ;
+// ESC : '\\' ( ["\\/bfnrt] ) ;
+// SAFECODEPOINT : ~ ["\\\u0000-\u001F\u007F] ;
+func (c *grammarChecker) str() bool {
+	tok := c.next()
+	if tok.Type != token.STRING {
+		c.errorf(tok, "expected string, got %s", describeToken(tok))
+		return false
+	}
+
+	text := tok.Text[1 : len(tok.Text)-1]
+	for i := 0; i < len(text); i++ {
+		switch ch := text[i]; {
+		case ch == '\\':
+			i++
+			if i >= len(text) || !strings.ContainsRune(`"\/bfnrt`, rune(text[i])) {
+				c.errorf(tok, "invalid escape sequence in string; only \\\", \\\\, \\/, \\b, \\f, \\n, \\r, and \\t are allowed")
+				return false
+			}
+		case ch < 0x20 || ch == 0x7f:
+			c.errorf(tok, "control character in string")
+			return false
+		}
+	}
+	return true
+}
+
+// stringMatching parses a string whose raw text, quotes included, must
+// match one of the given lexical rules.  A string that uses a local is
+// not known until the local is substituted, so it is left to the parser.
+func (c *grammarChecker) stringMatching(expected string, rules ...*regexp.Regexp) bool {
+	tok := c.peek()
+	if !c.str() {
+		return false
+	}
+	if c.fileVersion >= versionLocals && localRefRe.MatchString(tok.Text) {
+		return true
+	}
+	for _, rule := range rules {
+		if rule.MatchString(tok.Text) {
+			return true
+		}
+	}
+	c.errorf(tok, "expected %s, got %s", expected, tok.Text)
+	return false
+}
+
+// comment : LINE_COMMENT ;
+// LINE_COMMENT : ('#' | '//') ~[\r\n]* -> skip ;
+func (c *grammarChecker) comment(tok token.Token) {
+	if strings.HasPrefix(tok.Text, "/*") {
+		c.errorf(tok, "block comments are not allowed; use `#' or `//'")
+	}
+}
+
+func (c *grammarChecker) expect(t token.Type) bool {
+	tok := c.next()
+	if tok.Type != t {
+		c.errorf(tok, "expected %s, got %s", describeType(t), describeToken(tok))
+		return false
+	}
+	return true
+}
+
+// skipBlock skips tokens up to and including the `}' that closes the
+// current top-level block, after an error.
+func (c *grammarChecker) skipBlock() {
+	if c.depth == 0 && c.last.Type == token.RBRACE {
+		return
+	}
+	for {
+		switch c.peek().Type {
+		case token.EOF, token.ILLEGAL:
+			return
+		}
+		tok := c.next()
+		if c.depth == 0 && tok.Type == token.RBRACE {
+			return
+		}
+	}
+}
+
+func (c *grammarChecker) peek() token.Token {
+	return c.tokens[c.pos]
+}
+
+func (c *grammarChecker) next() token.Token {
+	tok := c.tokens[c.pos]
+	if c.pos < len(c.tokens)-1 {
+		c.pos++
+	}
+	switch tok.Type {
+	case token.LBRACE, token.LBRACK:
+		c.depth++
+	case token.RBRACE, token.RBRACK:
+		if c.depth > 0 {
+			c.depth--
+		}
+	}
+	c.last = tok
+	return tok
+}
+
+func (c *grammarChecker) errorf(tok token.Token, format string, a ...interface{}) {
+	c.p.addErrorFromToken(tok, CodeGrammar, "Strict grammar: %s", fmt.Sprintf(format, a...))
+}
+
+func describeType(t token.Type) string {
+	switch t {
+	case token.ASSIGN:
+		return "`='"
+	case token.LBRACE:
+		return "`{'"
+	case token.RBRACE:
+		return "`}'"
+	case token.LBRACK:
+		return "`['"
+	case token.RBRACK:
+		return "`]'"
+	case token.COMMA:
+		return "`,'"
+	case token.IDENT:
+		return "identifier"
+	default:
+		return strings.ToLower(t.String())
+	}
+}
+
+func describeToken(tok token.Token) string {
+	switch {
+	case tok.Type == token.EOF:
+		return "end of file"
+	case tok.Type == token.IDENT:
+		return fmt.Sprintf("identifier `%s'", tok.Text)
+	case tok.Type.IsOperator():
+		return fmt.Sprintf("`%s'", tok.Text)
+	default:
+		return describeType(tok.Type)
+	}
+}
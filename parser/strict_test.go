@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStrictGrammarConformance checks WithStrictGrammar against every
+// production of the grammar in language.md.  Each case is a complete
+// file and the strict grammar errors expected for it, if any.
+func TestStrictGrammarConformance(t *testing.T) {
+	cases := []struct {
+		production string
+		src        string
+		errors     []string
+	}{
+		// workflow_file : version? (workflow | action)* ;
+		{"workflow_file", ``, nil},
+		{"workflow_file", `action "a" { uses = "./a" } workflow "w" { on = "push" }`, nil},
+		{"workflow_file", `{}`, []string{"line 1: strict grammar: expected identifier, got `{'"}},
+		{"workflow_file", `"action" "a" { uses = "./a" }`, []string{"line 1: strict grammar: expected identifier, got string"}},
+		{"workflow_file", `hello "a" { }`, []string{"line 1: strict grammar: expected `workflow' or `action', got identifier `hello'"}},
+
+		// version : 'version' '=' INTEGER;
+		{"version", "version = 0\naction \"a\" { uses = \"./a\" }", nil},
+		{"version", `version = 0x0`, []string{"line 1: strict grammar: expected decimal integer, got number"}},
+		{"version", `version = "0"`, []string{"line 1: strict grammar: expected decimal integer, got string"}},
+
+		// workflow : 'workflow' str '{' (on_kvp | resolves_kvp | env_kvp | types_kvp | branches_kvp)* '}' ;
+		{"workflow", `workflow "w" { }`, nil},
+		{"workflow", `workflow w { }`, []string{"line 1: strict grammar: expected string, got identifier `w'"}},
+		{"workflow", `workflow "a" "b" { }`, []string{"line 1: strict grammar: expected `{', got string"}},
+		{"workflow", `workflow "w" { "on" = "push" }`, []string{"line 1: strict grammar: expected identifier, got string"}},
+		{"workflow", `workflow "w" { on = "push", resolves = "a" }`, []string{"line 1: strict grammar: expected identifier, got `,'"}},
+		{"workflow", `workflow "w" { on = "push" foo = "bar" }`, []string{"line 1: strict grammar: unexpected attribute `foo'"}},
+		{"workflow", "version = 1\nworkflow \"w\" { on = \"push\" env = { A = \"1\" } }", nil},
+		{"workflow", `workflow "w" { on = "push" env = { A = "1" } }`, []string{"line 1: strict grammar: unexpected attribute `env'"}},
+		{"workflow", "version = 3\nworkflow \"w\" { on = \"push\" types = \"a\" branches = [\"main\", \"release/*\"] }", nil},
+		{"workflow", "version = 2\nworkflow \"w\" { on = \"push\" branches = \"main\" }", []string{"line 2: strict grammar: unexpected attribute `branches'"}},
+
+		// types_kvp : 'types' '=' string_or_array ;
+		// branches_kvp : 'branches' '=' string_or_array ;
+		{"types_kvp", "version = 3\nworkflow \"w\" { types = [1] }", []string{"line 2: strict grammar: expected string, got number"}},
+		{"branches_kvp", "version = 3\nworkflow \"w\" { branches = { } }", []string{"line 2: strict grammar: expected string, got `{'"}},
+
+		// on_kvp : 'on' '=' (on_value | on_array);
+		// on_value : event_string | SCHEDULE_STRING ;
+		// on_array : '[' ((on_value ',')* on_value ','?)? ']' ;
+		// event_string : QUOTED_IDENTIFIER ;
+		{"on_kvp", `workflow "w" { on = "pull_request" }`, nil},
+		{"on_kvp", `workflow "w" { on = "schedule(*/15 * * * *)" }`, nil},
+		{"on_kvp", `workflow "w" { on = "pull-request" }`, []string{"line 1: strict grammar: expected event name or schedule expression, got \"pull-request\""}},
+		{"on_kvp", "workflow \"w\" {\n  on = <<EOF\npush\nEOF\n}", []string{"line 2: strict grammar: expected string, got heredoc"}},
+		{"on_array", "version = 2\nworkflow \"w\" { on = [\"push\", \"schedule(0 * * * *)\",] }", nil},
+		{"on_array", "version = 2\nworkflow \"w\" { on = [\"push\", \"pull-request\"] }", []string{"line 2: strict grammar: expected event name or schedule expression, got \"pull-request\""}},
+		{"on_array", `workflow "w" { on = ["push"] }`, []string{"line 1: strict grammar: expected string, got `['"}},
+
+		// resolves_kvp : 'resolves' '=' string_or_array ;
+		// string_or_array : str | string_array ;
+		// string_array : '[' (( str ',' )* str ','?)? ']' ;
+		{"string_array", `workflow "w" { resolves = "a" }`, nil},
+		{"string_array", `workflow "w" { resolves = [] }`, nil},
+		{"string_array", `workflow "w" { resolves = ["a", "b",] }`, nil},
+		{"string_array", `workflow "w" { resolves = [1] }`, []string{"line 1: strict grammar: expected string, got number"}},
+		{"string_array", `workflow "w" { resolves = [,] }`, []string{"line 1: strict grammar: expected string, got `,'"}},
+
+		// action : 'action' str '{' action_kvps '}' ;
+		// action_kvps : (uses_kvp | needs_kvp | runs_kvp | args_kvp | env_kvp | secrets_kvp | extends_kvp | matrix_kvp | timeout_kvp | retries_kvp | if_kvp)*;
+		{"action", `action "a" { uses = "./a" needs = "b" runs = "x" args = ["y"] env = {} secrets = [] }`, nil},
+		{"action", `action "a" { uses { } }`, []string{"line 1: strict grammar: expected `=', got `{'"}},
+		{"action", `action "a" { uses = "./a" color = "blue" }`, []string{"line 1: strict grammar: unexpected attribute `color'"}},
+		{"action", `action "a" { uses = "./a" extends = "t" }`, []string{"line 1: strict grammar: unexpected attribute `extends'"}},
+
+		// template : 'template' str '{' action_kvps '}' ;
+		// extends_kvp : 'extends' '=' string_or_array ;
+		{"template", "version = 4\ntemplate \"t\" { env = { A = \"1\" } }\naction \"a\" { uses = \"./a\" extends = [\"t\"] }", nil},
+		{"template", "version = 4\ntemplate \"t\" { extends = \"u\" on = \"push\" }", []string{"line 2: strict grammar: unexpected attribute `on'"}},
+		{"template", "version = 4\nhello \"t\" { }", []string{"line 2: strict grammar: expected `workflow', `action', or `template', got identifier `hello'"}},
+		{"template", `template "t" { }`, []string{"line 1: strict grammar: expected `workflow' or `action', got identifier `template'"}},
+
+		// include : 'include' '=' LOCAL_USES ;
+		{"include", "version = 5\ninclude = \"./a.workflow\"\ninclude = \"./b.workflow\"\naction \"a\" { uses = \"./a\" }", nil},
+		{"include", "version = 5\ninclude = \"a.workflow\"", []string{"line 2: strict grammar: expected path, got \"a.workflow\""}},
+		{"include", "version = 4\ninclude = \"./a.workflow\"", []string{"line 2: strict grammar: expected `workflow', `action', or `template', got identifier `include'"}},
+
+		// matrix_kvp : 'matrix' '=' '{' matrix_var* '}' ;
+		// matrix_var : IDENTIFIER '=' string_or_array ','? ;
+		{"matrix", "version = 6\ntemplate \"t\" { matrix = { A = \"1\", B = [\"2\", \"3\"] } }\naction \"a\" { extends = \"t\" matrix = {} }", nil},
+		{"matrix", "version = 6\naction \"a\" { uses = \"./a\" matrix = { A = 1 } }", []string{"line 2: strict grammar: expected string, got number"}},
+		{"matrix", "version = 6\naction \"a\" { uses = \"./a\" matrix = { \"A\" = \"1\" } }", []string{"line 2: strict grammar: expected identifier, got string"}},
+		{"matrix", "version = 5\naction \"a\" { uses = \"./a\" matrix = { A = \"1\" } }", []string{"line 2: strict grammar: unexpected attribute `matrix'"}},
+
+		// timeout_kvp : 'timeout' '=' TIMEOUT_STRING ;
+		// retries_kvp : 'retries' '=' INTEGER ;
+		{"timeout", "version = 7\naction \"a\" { uses = \"./a\" timeout = \"1h30m\" retries = 2 }", nil},
+		{"timeout", "version = 7\naction \"a\" { uses = \"./a\" timeout = \"90 minutes\" }", []string{"line 2: strict grammar: expected duration such as \"10m\", got \"90 minutes\""}},
+		{"timeout", "version = 7\naction \"a\" { uses = \"./a\" retries = \"2\" }", []string{"line 2: strict grammar: expected decimal integer, got string"}},
+		{"timeout", "version = 6\naction \"a\" { uses = \"./a\" timeout = \"10m\" }", []string{"line 2: strict grammar: unexpected attribute `timeout'"}},
+
+		// if_kvp : 'if' '=' str ;
+		{"if", "version = 8\naction \"a\" { uses = \"./a\" if = \"event.ref == 'refs/heads/main'\" }", nil},
+		{"if", "version = 8\naction \"a\" { uses = \"./a\" if = true }", []string{"line 2: strict grammar: expected string, got bool"}},
+		{"if", "version = 7\naction \"a\" { uses = \"./a\" if = \"true\" }", []string{"line 2: strict grammar: unexpected attribute `if'"}},
+
+		// locals : 'locals' '{' local_var* '}' ;
+		{"locals", "version = 9\nlocals { A = \"1\", B = \"${local.A}\" }\naction \"a\" { uses = \"./a\" env = { B = \"${local.B}\" } }", nil},
+		{"locals", "version = 9\nlocals { A = [\"1\"] }", []string{"line 2: strict grammar: expected string, got `['"}},
+		{"locals", "version = 9\nhello \"t\" { }", []string{"line 2: strict grammar: expected `workflow', `action', `template', or `locals', got identifier `hello'"}},
+		{"locals", "version = 8\nlocals { A = \"1\" }", []string{"line 2: strict grammar: expected `workflow', `action', or `template', got identifier `locals'"}},
+
+		// uses_kvp : 'uses' '=' (DOCKER_USES | LOCAL_USES | REMOTE_USES) ;
+		{"DOCKER_USES", `action "a" { uses = "docker://alpine" }`, nil},
+		{"DOCKER_USES", `action "a" { uses = "docker://alpine:latest" }`, nil},
+		{"DOCKER_USES", `action "a" { uses = "docker://alpine:3.8" }`, nil},
+		{"DOCKER_USES", `action "a" { uses = "docker://node:10-alpine" }`, nil},
+		{"DOCKER_USES", `action "a" { uses = "docker://alpine:.8" }`, []string{"line 1: strict grammar: expected docker image, path, or owner/repo@ref"}},
+		{"DOCKER_USES", `action "a" { uses = "docker://gcr.io:443/my-project/image_name@sha256:abc123" }`, nil},
+		{"DOCKER_USES", `action "a" { uses = "docker://Alpine--" }`, []string{"line 1: strict grammar: expected docker image, path, or owner/repo@ref"}},
+		{"LOCAL_USES", `action "a" { uses = "./" }`, nil},
+		{"LOCAL_USES", `action "a" { uses = "./path/to dir" }`, nil},
+		{"LOCAL_USES", `action "a" { uses = "./x \" y" }`, []string{"line 1: strict grammar: expected docker image, path, or owner/repo@ref"}},
+		{"REMOTE_USES", `action "a" { uses = "actions/bin/filter@master" }`, nil},
+		{"REMOTE_USES", `action "a" { uses = "owner/repo@v1.0.0" }`, nil},
+		{"REMOTE_USES", `action "a" { uses = "-owner/repo@master" }`, []string{"line 1: strict grammar: expected docker image, path, or owner/repo@ref"}},
+		{"REMOTE_USES", `action "a" { uses = "owner/repo@a b" }`, []string{"line 1: strict grammar: expected docker image, path, or owner/repo@ref"}},
+		{"REMOTE_USES", `action "a" { uses = "owner@master" }`, []string{"line 1: strict grammar: expected docker image, path, or owner/repo@ref"}},
+
+		// needs_kvp, runs_kvp, args_kvp : '...' '=' string_or_array ;
+		{"runs_kvp", `action "a" { uses = "./a" runs = ["a", "b"] }`, nil},
+		{"runs_kvp", "action \"a\" {\n  uses = \"./a\"\n  runs = <<EOF\nx\nEOF\n}", []string{"line 3: strict grammar: expected string, got heredoc"}},
+		{"needs_kvp", `action "a" { uses = "./a" needs = true }`, []string{"line 1: strict grammar: expected string, got bool"}},
+		{"args_kvp", `action "a" { uses = "./a" args = 1.5 }`, []string{"line 1: strict grammar: expected string, got float"}},
+
+		// env_kvp : 'env' '=' '{' env_var* '}' ;
+		// env_var : IDENTIFIER '=' str ','? ;
+		{"env_var", `action "a" { uses = "./a" env = { A = "1", B = "2" C = "3", } }`, nil},
+		{"env_var", `action "a" { uses = "./a" env = { "A" = "1" } }`, []string{"line 1: strict grammar: expected identifier, got string"}},
+		{"env_var", `action "a" { uses = "./a" env = { A = 1 } }`, []string{"line 1: strict grammar: expected string, got number"}},
+		{"env_var", `action "a" { uses = "./a" env = { A { } } }`, []string{"line 1: strict grammar: expected `=', got `{'"}},
+
+		// secrets_kvp : 'secrets' '=' ident_array ;
+		// ident_array : '[' ((QUOTED_IDENTIFIER ',')* QUOTED_IDENTIFIER ','?)? ']';
+		{"ident_array", `action "a" { uses = "./a" secrets = ["A", "_b1",] }`, nil},
+		{"ident_array", `action "a" { uses = "./a" secrets = "A" }`, []string{"line 1: strict grammar: expected `[', got string"}},
+		{"ident_array", `action "a" { uses = "./a" secrets = ["0_o"] }`, []string{"line 1: strict grammar: expected quoted identifier, got \"0_o\""}},
+
+		// STRING : '"' ( ESC | SAFECODEPOINT )* '"' ;
+		{"STRING", `action "a \"b\" \\ \t" { uses = "./a" }`, nil},
+		{"STRING", `action "é" { uses = "./a" }`, nil},
+		{"STRING", `action "\u0041" { uses = "./a" }`, []string{"line 1: strict grammar: invalid escape sequence in string"}},
+		{"STRING", "action \"a\x7fb\" { uses = \"./a\" }", []string{"line 1: strict grammar: control character in string"}},
+
+		// LINE_COMMENT : ('#' | '//') ~[\r\n]* -> skip ;
+		{"LINE_COMMENT", "# one\n// two\naction \"a\" { uses = \"./a\" } # three", nil},
+		{"LINE_COMMENT", "/* block */\naction \"a\" { uses = \"./a\" }", []string{"line 1: strict grammar: block comments are not allowed"}},
+	}
+
+	for _, tc := range cases {
+		name := fmt.Sprintf("%s: %s", tc.production, tc.src)
+		root, err := parseHCL([]byte(tc.src))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		p := parseAndValidate([]byte(tc.src), root, WithStrictGrammar(), WithSuppressWarnings())
+		var actual []string
+		for _, pe := range p.errors {
+			if pe.Code == CodeGrammar {
+				actual = append(actual, strings.ToLower(pe.Error()))
+			}
+		}
+		if assert.Equal(t, len(tc.errors), len(actual), name+"\n%v", actual) {
+			for i := range tc.errors {
+				assert.Contains(t, actual[i], strings.ToLower(tc.errors[i]), name)
+			}
+		}
+	}
+}
+
+func TestStrictGrammarValidFixtures(t *testing.T) {
+	// These fixtures use HCL features that the grammar does not allow.
+	nonconforming := map[string]bool{
+		"escaping.workflow": true,
+		"heredocs.workflow": true,
+	}
+	for _, filename := range fixtureFiles(t, "valid") {
+		bytes, err := ioutil.ReadFile("../tests/valid/" + filename)
+		require.NoError(t, err)
+		options := []OptionFunc{WithStrictGrammar()}
+		for _, a := range parseAssertions(t, string(bytes)) {
+			for _, name := range a.Options {
+				options = append(options, fixtureOptions[name])
+			}
+		}
+		p := parseAndValidateFile(t, "valid/"+filename, options...)
+		if nonconforming[filename] {
+			assert.NotEmpty(t, p.errors, filename)
+		} else {
+			assert.Empty(t, p.errors, filename)
+		}
+	}
+}
+
+func TestStrictGrammarOff(t *testing.T) {
+	workflow, err := parseString(`"action" "a" { "uses" = "./a" env = { "A" = "1" } }`)
+	assertParseSuccess(t, err, 1, 0, workflow)
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+)
+
+// suppression is an inline `workflow:ignore' comment.  It silences
+// diagnostics with the listed codes on its own line and on the line
+// that follows it.
+type suppression struct {
+	comment *ast.Comment
+	codes   []Code
+	used    map[Code]bool
+}
+
+var suppressionRe = regexp.MustCompile(`\A(?:#|//)\s*workflow:ignore\b(.*)\z`)
+var codeRe = regexp.MustCompile(`\AWF[0-9]{3}\z`)
+
+// parseSuppressions collects the `workflow:ignore' directives from the
+// comments in a file.  A directive lists one or more codes, separated by
+// spaces or commas:
+//
+//	# workflow:ignore WF203, WF210
+func (p *Parser) parseSuppressions(groups []*ast.CommentGroup) {
+	for _, group := range groups {
+		for _, comment := range group.List {
+			m := suppressionRe.FindStringSubmatch(strings.TrimSpace(comment.Text))
+			if m == nil {
+				continue
+			}
+
+			fields := strings.FieldsFunc(m[1], func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+			if len(fields) == 0 {
+				p.addDiagnostic(newWarning(posFromComment(comment), CodeInvalidSuppression,
+					"`workflow:ignore' must list at least one diagnostic code"))
+				continue
+			}
+
+			s := &suppression{comment: comment, used: make(map[Code]bool, len(fields))}
+			for _, field := range fields {
+				if !codeRe.MatchString(field) {
+					p.addDiagnostic(newWarning(posFromComment(comment), CodeInvalidSuppression,
+						"Invalid diagnostic code `%s' in `workflow:ignore'", field))
+					continue
+				}
+				s.codes = append(s.codes, Code(field))
+			}
+			p.suppressions = append(p.suppressions, s)
+		}
+	}
+}
+
+// applySuppressions removes diagnostics silenced by inline comments,
+// reports comments that silenced nothing, and finally drops diagnostics
+// below the level set by WithSuppressWarnings or WithSuppressErrors, and
+// those with a code in WithIgnoredCodes.
+func (p *Parser) applySuppressions() {
+	kept := p.errors[:0]
+	for _, pe := range p.errors {
+		if !p.suppressed(pe) {
+			kept = append(kept, pe)
+		}
+	}
+	p.errors = kept
+
+	for _, s := range p.suppressions {
+		for _, code := range s.codes {
+			if !s.used[code] {
+				p.addDiagnostic(newWarning(posFromComment(s.comment), CodeUnusedSuppression,
+					"`workflow:ignore' for %s does not match any diagnostic", code))
+			}
+		}
+	}
+
+	kept = p.errors[:0]
+	for _, pe := range p.errors {
+		if pe.Severity > p.suppressSeverity && !p.ignoredCodes[pe.Code] {
+			kept = append(kept, pe)
+		}
+	}
+	p.errors = kept
+}
+
+func (p *Parser) suppressed(pe *ParseError) bool {
+	if pe.Code == CodeInvalidSuppression {
+		return false
+	}
+	ret := false
+	for _, s := range p.suppressions {
+		line := s.comment.Start.Line
+		if pe.Pos.File != s.comment.Start.Filename || (pe.Pos.Line != line && pe.Pos.Line != line+1) {
+			continue
+		}
+		for _, code := range s.codes {
+			if code == pe.Code {
+				s.used[code] = true
+				ret = true
+			}
+		}
+	}
+	return ret
+}
+
+// posFromComment returns an ErrorPos for a comment.
+func posFromComment(comment *ast.Comment) ErrorPos {
+	return ErrorPos{File: comment.Start.Filename, Line: comment.Start.Line, Column: comment.Start.Column}
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticCodes(t *testing.T) {
+	_, err := parseString(`action "a" { uses="./x" secrets=["A", "A"] foo="bar" }`)
+	pe := extractParserError(t, err)
+	require.Len(t, pe.Errors, 2)
+	assert.Equal(t, CodeUnknownActionAttribute, pe.Errors[0].Code)
+	assert.Equal(t, CodeSecretRedefined, pe.Errors[1].Code)
+
+	_, err = parseString(`action "a" {`)
+	pe = extractParserError(t, err)
+	require.Len(t, pe.Errors, 1)
+	assert.Equal(t, CodeSyntax, pe.Errors[0].Code)
+}
+
+func TestCodeSeverityOverrides(t *testing.T) {
+	src := `action "a" { uses="./x" secrets=["A", "A"] foo="bar" }`
+
+	workflow, err := parseString(src,
+		WithCodeSeverity(CodeSecretRedefined, ERROR),
+		WithIgnoredCodes(CodeUnknownActionAttribute))
+	assertParseError(t, err, 1, 0, workflow, "secret `a' redefined")
+	assert.Equal(t, Severity(ERROR), extractParserError(t, err).Errors[0].Severity)
+
+	// The promoted diagnostic survives suppression of warnings.
+	workflow, err = parseString(src,
+		WithCodeSeverity(CodeSecretRedefined, ERROR),
+		WithSuppressWarnings())
+	assertParseError(t, err, 1, 0, workflow, "secret `a' redefined")
+
+	workflow, err = parseString(src, WithIgnoredCodes(CodeSecretRedefined, CodeUnknownActionAttribute))
+	assertParseSuccess(t, err, 1, 0, workflow)
+}
+
+func TestInlineSuppression(t *testing.T) {
+	workflow, err := parseString(`
+action "a" {
+	uses = "./x"
+	# workflow:ignore WF202
+	foo = "bar"
+	secrets = ["A", "A"] // workflow:ignore WF210
+}
+`)
+	assertParseSuccess(t, err, 1, 0, workflow)
+
+	// Only the listed codes are silenced, and only on the next line.
+	workflow, err = parseString(`
+action "a" {
+	uses = "./x"
+	# workflow:ignore WF210, WF202
+	foo = "bar"
+
+	bar = "baz"
+}
+`)
+	assertParseError(t, err, 1, 0, workflow,
+		"line 4: `workflow:ignore' for wf210 does not match any diagnostic",
+		"line 7: unknown action attribute `bar'",
+	)
+	pe := extractParserError(t, err)
+	assert.Equal(t, CodeUnusedSuppression, pe.Errors[0].Code)
+
+	// A diagnostic with an ignored code still uses the comment.
+	workflow, err = parseString(`
+action "a" {
+	uses = "./x"
+	# workflow:ignore WF202
+	foo = "bar"
+	bar = "baz"
+}
+`, WithIgnoredCodes(CodeUnknownActionAttribute))
+	assertParseSuccess(t, err, 1, 0, workflow)
+}
+
+func TestInvalidInlineSuppression(t *testing.T) {
+	workflow, err := parseString(`
+# workflow:ignore
+# workflow:ignore bogus WF202
+action "a" { uses = "./x" }
+`)
+	assertParseError(t, err, 1, 0, workflow,
+		"line 2: `workflow:ignore' must list at least one diagnostic code",
+		"line 3: invalid diagnostic code `bogus' in `workflow:ignore'",
+		"line 3: `workflow:ignore' for wf202 does not match any diagnostic",
+	)
+}
@@ -0,0 +1,333 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+)
+
+// syntaxParser is a recursive-descent parser for the subset of HCL that
+// .workflow files use.  It builds the same AST as parseHCL, so the rest
+// of the package works unchanged on its output, but it reports
+// syntax errors in terms of the .workflow language, accepts heredocs
+// anywhere a string can go, and keeps the exact byte offset of every
+// token.
+//
+// It accepts everything that the HCL parser accepts for a .workflow
+// file, including quoted keys, commas between attributes, and values
+// that are not strings; rejecting those is up to the validation that
+// follows, or to WithStrictGrammar.
+//
+// Alongside the AST, it builds the SyntaxTree, which keeps the tokens
+// that the AST leaves out.
+type syntaxParser struct {
+	// tokens are all the tokens of the file, trivia included, ending with
+	// a TokenEOF.  peek and next skip over the trivia, adding it to the
+	// current node as they go.
+	tokens []lexToken
+	pos    int
+
+	// node is the SyntaxNode being built.
+	node *SyntaxNode
+
+	comments []*ast.CommentGroup
+}
+
+// parseNative parses src with the purpose-built lexer and parser in this
+// package.  A syntax error is returned as a FATAL *ParseError.
+func parseNative(src []byte) (*ast.File, *SyntaxTree, error) {
+	s := newSyntaxParser(lex(src))
+	list, pe := s.file()
+	if pe != nil {
+		return nil, nil, pe
+	}
+	return &ast.File{Node: list, Comments: s.comments}, &SyntaxTree{Root: s.node}, nil
+}
+
+func newSyntaxParser(tokens []lexToken) *syntaxParser {
+	return &syntaxParser{tokens: tokens, comments: commentGroups(tokens)}
+}
+
+// commentGroups returns the comments among tokens, grouped as the HCL
+// parser would: comments on consecutive lines, with nothing but
+// whitespace between them, are in the same group.
+func commentGroups(tokens []lexToken) []*ast.CommentGroup {
+	var groups []*ast.CommentGroup
+	var group *ast.CommentGroup
+	lastLine := 0
+	for _, tok := range tokens {
+		switch {
+		case tok.kind == TokenComment:
+			comment := &ast.Comment{Start: tok.pos, Text: strings.TrimRight(tok.text, "\r")}
+			if group == nil || tok.pos.Line > lastLine+1 {
+				group = &ast.CommentGroup{}
+				groups = append(groups, group)
+			}
+			group.List = append(group.List, comment)
+			lastLine = tok.pos.Line + strings.Count(tok.text, "\n")
+		case tok.kind == TokenWhitespace:
+		default:
+			group = nil
+		}
+	}
+	return groups
+}
+
+// file : (item ','?)* EOF ;
+func (s *syntaxParser) file() (*ast.ObjectList, *ParseError) {
+	s.node = &SyntaxNode{Kind: FileNode}
+	list := &ast.ObjectList{}
+	for s.peek().kind != TokenEOF {
+		item, pe := s.objectItem()
+		if pe != nil {
+			return nil, pe
+		}
+		list.Add(item)
+		s.accept(TokenComma)
+	}
+	s.next()
+	s.node.ast = list
+	return list, nil
+}
+
+// item : key ('=' value | key* object) ;
+// key : IDENT | STRING ;
+func (s *syntaxParser) objectItem() (*ast.ObjectItem, *ParseError) {
+	node := s.open(AttributeNode)
+	item := &ast.ObjectItem{}
+	for s.peek().kind == TokenIdent || s.peek().kind == TokenString {
+		item.Keys = append(item.Keys, &ast.ObjectKey{Token: s.next().astToken()})
+	}
+	if len(item.Keys) == 0 {
+		return nil, s.unexpected(s.peek(), "a name, like `action' or `uses'")
+	}
+
+	var pe *ParseError
+	switch tok := s.peek(); {
+	case tok.kind == TokenAssign && len(item.Keys) > 1:
+		return nil, s.errorf(tok, "Unexpected `=' after `%s'; use `{' to start a block, or `=' after a single name", keysText(item.Keys))
+	case tok.kind == TokenAssign:
+		item.Assign = s.next().pos
+		item.Val, pe = s.value(keysText(item.Keys))
+	case tok.kind == TokenLBrace:
+		node.Kind = BlockNode
+		item.Val, pe = s.object()
+	default:
+		return nil, s.unexpected(tok, fmt.Sprintf("`{' or `=' after `%s'", keysText(item.Keys)))
+	}
+	if pe != nil {
+		return nil, pe
+	}
+	s.close(node, item)
+	return item, nil
+}
+
+// value : STRING | HEREDOC | NUMBER | FLOAT | BOOL | object | list ;
+func (s *syntaxParser) value(name string) (ast.Node, *ParseError) {
+	switch tok := s.peek(); tok.kind {
+	case TokenString, TokenHeredoc, TokenNumber, TokenFloat, TokenBool:
+		node := s.open(LiteralNode)
+		literal := &ast.LiteralType{Token: s.next().astToken()}
+		s.close(node, literal)
+		return literal, nil
+	case TokenLBrace:
+		return s.object()
+	case TokenLBrack:
+		return s.list(name)
+	default:
+		return nil, s.unexpected(tok, fmt.Sprintf("a value for `%s'", name))
+	}
+}
+
+// object : '{' (item ','?)* '}' ;
+func (s *syntaxParser) object() (*ast.ObjectType, *ParseError) {
+	node := s.open(ObjectNode)
+	lbrace := s.next()
+	obj := &ast.ObjectType{Lbrace: lbrace.pos, List: &ast.ObjectList{}}
+	for {
+		switch tok := s.peek(); tok.kind {
+		case TokenRBrace:
+			obj.Rbrace = s.next().pos
+			s.close(node, obj)
+			return obj, nil
+		case TokenEOF:
+			return nil, s.errorf(tok, "Expected `}' to close the block opened on line %d, got end of file", lbrace.pos.Line)
+		}
+
+		item, pe := s.objectItem()
+		if pe != nil {
+			return nil, pe
+		}
+		obj.List.Add(item)
+		s.accept(TokenComma)
+	}
+}
+
+// list : '[' (value (',' value)* ','?)? ']' ;
+func (s *syntaxParser) list(name string) (*ast.ListType, *ParseError) {
+	node := s.open(ListNode)
+	lbrack := s.next()
+	list := &ast.ListType{Lbrack: lbrack.pos}
+	for {
+		if tok := s.peek(); tok.kind == TokenRBrack {
+			list.Rbrack = s.next().pos
+			s.close(node, list)
+			return list, nil
+		}
+
+		elem, pe := s.value(name)
+		if pe != nil {
+			return nil, pe
+		}
+		list.Add(elem)
+
+		switch tok := s.peek(); tok.kind {
+		case TokenComma:
+			s.next()
+		case TokenRBrack:
+		default:
+			return nil, s.unexpected(tok, fmt.Sprintf("`,' or `]' in the list opened on line %d", lbrack.pos.Line))
+		}
+	}
+}
+
+// peek returns the next significant token, without consuming it.
+func (s *syntaxParser) peek() lexToken {
+	pos := s.pos
+	for s.tokens[pos].isTrivia() {
+		pos++
+	}
+	return s.tokens[pos]
+}
+
+// next consumes the next significant token and any trivia before it,
+// adding them to the current node.
+func (s *syntaxParser) next() lexToken {
+	s.skipTrivia()
+	tok := s.tokens[s.pos]
+	if tok.kind != TokenEOF {
+		s.node.Children = append(s.node.Children, newSyntaxToken(tok))
+		s.pos++
+	}
+	return tok
+}
+
+func (s *syntaxParser) skipTrivia() {
+	for s.tokens[s.pos].isTrivia() {
+		s.node.Children = append(s.node.Children, newSyntaxToken(s.tokens[s.pos]))
+		s.pos++
+	}
+}
+
+// open starts a new SyntaxNode as the last child of the current one.
+// Trivia before the node's first token goes to the current node.
+func (s *syntaxParser) open(kind SyntaxKind) *SyntaxNode {
+	s.skipTrivia()
+	node := &SyntaxNode{Kind: kind, Parent: s.node}
+	s.node.Children = append(s.node.Children, node)
+	s.node = node
+	return node
+}
+
+// close finishes a node started with open, recording the AST node built
+// from it.
+func (s *syntaxParser) close(node *SyntaxNode, astNode ast.Node) {
+	node.ast = astNode
+	s.node = node.Parent
+}
+
+func (s *syntaxParser) accept(kind TokenKind) bool {
+	if s.peek().kind == kind {
+		s.next()
+		return true
+	}
+	return false
+}
+
+// unexpected returns an error for a token that does not fit, or the
+// lexer's description of the problem if the token is not valid at all.
+func (s *syntaxParser) unexpected(tok lexToken, expected string) *ParseError {
+	if tok.kind == TokenIllegal {
+		return s.errorf(tok, "%s", tok.err)
+	}
+	return s.errorf(tok, "Expected %s, got %s", expected, tok.describe())
+}
+
+func (s *syntaxParser) errorf(tok lexToken, format string, a ...interface{}) *ParseError {
+	pos := ErrorPos{Line: tok.pos.Line, Column: tok.pos.Column}
+	return newFatal(pos, CodeSyntax, format, a...)
+}
+
+// astToken converts a token to the token of the AST, as the HCL parser
+// would have produced it.  Heredocs always end in a newline and never
+// contain carriage returns, because HCL normalizes line endings.
+func (tok lexToken) astToken() token.Token {
+	t := token.Token{Pos: tok.pos, Text: tok.text}
+	switch tok.kind {
+	case TokenIdent:
+		t.Type = token.IDENT
+	case TokenString:
+		t.Type = token.STRING
+	case TokenHeredoc:
+		t.Type = token.HEREDOC
+		t.Text = strings.Replace(t.Text, "\r\n", "\n", -1)
+		if !strings.HasSuffix(t.Text, "\n") {
+			t.Text += "\n"
+		}
+	case TokenNumber:
+		t.Type = token.NUMBER
+	case TokenFloat:
+		t.Type = token.FLOAT
+	case TokenBool:
+		t.Type = token.BOOL
+	case TokenLBrace:
+		t.Type = token.LBRACE
+	case TokenRBrace:
+		t.Type = token.RBRACE
+	case TokenLBrack:
+		t.Type = token.LBRACK
+	case TokenRBrack:
+		t.Type = token.RBRACK
+	case TokenAssign:
+		t.Type = token.ASSIGN
+	case TokenComma:
+		t.Type = token.COMMA
+	case TokenComment:
+		t.Type = token.COMMENT
+	case TokenEOF:
+		t.Type = token.EOF
+	default:
+		t.Type = token.ILLEGAL
+	}
+	return t
+}
+
+// describe returns a description of a token for error messages.
+func (tok lexToken) describe() string {
+	switch tok.kind {
+	case TokenEOF:
+		return "end of file"
+	case TokenIdent:
+		return fmt.Sprintf("`%s'", tok.text)
+	case TokenString:
+		return fmt.Sprintf("string %s", tok.text)
+	case TokenHeredoc:
+		return "heredoc"
+	case TokenNumber, TokenFloat:
+		return fmt.Sprintf("number %s", tok.text)
+	case TokenBool:
+		return fmt.Sprintf("boolean %s", tok.text)
+	default:
+		return fmt.Sprintf("`%s'", tok.text)
+	}
+}
+
+func keysText(keys []*ast.ObjectKey) string {
+	texts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		texts = append(texts, key.Token.Text)
+	}
+	return strings.Join(texts, " ")
+}
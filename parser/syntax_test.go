@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNativeParserFixtures checks that the native parser gives the same
+// configuration and diagnostics as the HCL parser for every fixture.  The
+// wording of syntax errors is allowed to differ.
+func TestNativeParserFixtures(t *testing.T) {
+	for _, dir := range []string{"valid", "invalid"} {
+		for _, filename := range fixtureFiles(t, dir) {
+			bytes, err := ioutil.ReadFile("../tests/" + dir + "/" + filename)
+			require.NoError(t, err)
+
+			var options []OptionFunc
+			for _, a := range parseAssertions(t, string(bytes)) {
+				for _, name := range a.Options {
+					options = append(options, fixtureOptions[name])
+				}
+			}
+
+			name := dir + "/" + filename
+			expected, err := ParseWithResult(strings.NewReader(string(bytes)), options...)
+			require.NoError(t, err, name)
+			actual, err := ParseWithResult(strings.NewReader(string(bytes)), append(options, WithNativeParser())...)
+			require.NoError(t, err, name)
+
+			assert.Equal(t, expected.Configuration, actual.Configuration, name)
+			if len(expected.Errors) > 0 && expected.Errors[0].Code == CodeSyntax {
+				if assert.Len(t, actual.Errors, 1, name) {
+					assert.Equal(t, CodeSyntax, actual.Errors[0].Code, name)
+					assert.Equal(t, Severity(FATAL), actual.Errors[0].Severity, name)
+				}
+				continue
+			}
+			assert.Equal(t, describeErrors(expected.Errors), describeErrors(actual.Errors), name)
+		}
+	}
+}
+
+func describeErrors(errors []*ParseError) []string {
+	ret := make([]string, 0, len(errors))
+	for _, pe := range errors {
+		ret = append(ret, fmt.Sprintf("%d:%d %d %s %s", pe.Pos.Line, pe.Pos.Column, pe.Severity, pe.Code, pe.message))
+	}
+	return ret
+}
+
+func TestNativeSyntaxErrors(t *testing.T) {
+	cases := []struct {
+		src     string
+		line    int
+		column  int
+		message string
+	}{
+		{"this is definitely not valid HCL!", 1, 33, "Unexpected character `!'"},
+		{"action \"foo\"\n", 2, 1, "Expected `{' or `=' after `action \"foo\"', got end of file"},
+		{"action \"foo\" {\n", 2, 1, "Expected `}' to close the block opened on line 1, got end of file"},
+		{"action \"foo\" { uses=\" }", 1, 21, "String is not terminated"},
+		{"action \"a\" \"b\" = 1", 1, 16, "Unexpected `=' after `action \"a\" \"b\"'"},
+		{"action \"a\" {\n  uses =\n}", 3, 1, "Expected a value for `uses', got `}'"},
+		{"action \"a\" { needs = [\"b\" \"c\"] }", 1, 27, "Expected `,' or `]' in the list opened on line 1, got string \"c\""},
+		{"action \"a\" { uses = \"${\" }", 1, 21, "String has a `${' without a matching `}'"},
+		{"action \"a\" { uses = \"\\q\" }", 1, 21, "Invalid escape sequence `\\q'"},
+		{"version = 12abc", 1, 11, "Invalid number `12abc'"},
+		{"version = 99999999999999999999", 1, 11, "Number `99999999999999999999' is out of range"},
+		{"action \"a\" { env = { A = <<EOF\nfoo\n} }", 1, 26, "Heredoc is not terminated; expected a line containing only `EOF'"},
+		{"/* open", 1, 1, "Comment starting with `/*' is not terminated"},
+		{"{}", 1, 1, "Expected a name, like `action' or `uses', got `{'"},
+	}
+
+	for _, tc := range cases {
+		_, err := parseString(tc.src, WithNativeParser())
+		pe := extractParserError(t, err)
+		if assert.Len(t, pe.Errors, 1, tc.src) {
+			assert.Equal(t, ErrorPos{Line: tc.line, Column: tc.column}, pe.Errors[0].Pos, tc.src)
+			assert.Equal(t, CodeSyntax, pe.Errors[0].Code, tc.src)
+			assert.Contains(t, pe.Errors[0].message, tc.message, tc.src)
+		}
+	}
+}
+
+func TestNativeLineEndings(t *testing.T) {
+	src := "# comment\r\naction \"a\" {\r\n  uses = \"./a\"\r\n  runs = <<EOF\r\necho hi\r\nEOF\r\n}\r\n"
+	expected, err := parseString(src)
+	require.NoError(t, err)
+	actual, err := parseString(src, WithNativeParser())
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestLexerIsLossless(t *testing.T) {
+	sources := []string{
+		"action \"foo\" { uses=\" }",
+		"/* open",
+		"action \"a\" { env = { A = <<EOF\nfoo\n} }",
+		"\xff\x00 12abc \"\\q\"",
+	}
+	for _, dir := range []string{"valid", "invalid"} {
+		for _, filename := range fixtureFiles(t, dir) {
+			bytes, err := ioutil.ReadFile("../tests/" + dir + "/" + filename)
+			require.NoError(t, err)
+			sources = append(sources, string(bytes))
+		}
+	}
+
+	for _, src := range sources {
+		var text strings.Builder
+		for _, tok := range lex([]byte(src)) {
+			assert.Equal(t, len(text.String()), tok.pos.Offset, src)
+			text.WriteString(tok.text)
+		}
+		assert.Equal(t, src, text.String())
+	}
+}
@@ -0,0 +1,278 @@
+package parser
+
+import (
+	"reflect"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/model"
+)
+
+// parseExtends parses the `extends' attribute of an action or template,
+// which names one or more templates.
+func (p *Parser) parseExtends(action *model.Action, node ast.Node) {
+	if action.Extends != nil {
+		p.addWarning(node, CodeAttributeRedefined, "`extends' redefined in %s `%s'", p.blockType, action.Identifier)
+		// continue, allowing the redefinition
+	}
+	extends, ok := p.literalToStringArray(node, true)
+	if !ok {
+		p.addError(node, CodeInvalidExtends, "Invalid format for `extends' in %s `%s', expected list of strings", p.blockType, action.Identifier)
+		return
+	}
+	if len(extends) == 0 {
+		p.addError(node, CodeInvalidExtends, "`extends' in %s `%s' must list at least one template", p.blockType, action.Identifier)
+	}
+	action.Extends = uniqStrings(extends)
+	p.posMap[&action.Extends] = node
+}
+
+// templateExpander fills in the attributes that actions inherit from
+// templates.  Each template is expanded once, when it is first extended.
+type templateExpander struct {
+	p         *Parser
+	templates map[string]*model.Action
+	expanded  map[string]*model.Action
+
+	// expanding holds the templates whose expansion is in progress, to
+	// detect cycles.
+	expanding map[string]bool
+}
+
+// expandTemplates replaces each action that extends templates with a copy
+// that has the inherited attributes filled in.  The action as written is
+// kept in p.own.  Inherited attributes keep the positions of the template
+// lines they came from, so that diagnostics about them point there.
+//
+// The attributes `uses', `runs', `args', `matrix', `timeout', `retries',
+// and `if' are inherited if the action does not set them.  `env' is
+// merged variable by variable, and `needs' and `secrets' are combined,
+// with the action's own values winning.  If two templates that an action
+// extends disagree about an attribute or variable, and the action does
+// not set it, that is an error.
+func (p *Parser) expandTemplates() {
+	p.own = make(map[*model.Action]*model.Action)
+	e := &templateExpander{
+		p:         p,
+		templates: make(map[string]*model.Action, len(p.templates)),
+		expanded:  make(map[string]*model.Action, len(p.templates)),
+		expanding: make(map[string]bool),
+	}
+	for _, template := range p.templates {
+		if _, ok := e.templates[template.Identifier]; !ok {
+			e.templates[template.Identifier] = template
+		}
+	}
+	for _, template := range p.templates {
+		e.expand(template)
+	}
+
+	for i, action := range p.actions {
+		if action.Extends == nil {
+			continue
+		}
+		expanded := e.inherit(action, "action")
+		p.own[expanded] = action
+		p.actions[i] = expanded
+		delete(p.posMap, action)
+	}
+
+	// Templates are not part of the configuration, so nothing in the
+	// syntax tree links to them.
+	for _, template := range p.templates {
+		delete(p.posMap, template)
+	}
+	for _, template := range e.expanded {
+		delete(p.posMap, template)
+	}
+}
+
+// expand returns a copy of template with the attributes it inherits
+// filled in.
+func (e *templateExpander) expand(template *model.Action) *model.Action {
+	id := template.Identifier
+	if expanded, ok := e.expanded[id]; ok {
+		return expanded
+	}
+	e.expanding[id] = true
+	expanded := e.inherit(template, "template")
+	delete(e.expanding, id)
+	e.expanded[id] = expanded
+	return expanded
+}
+
+// inherit returns a copy of action, which is an action or a template, with
+// the attributes of the templates it extends filled in.
+func (e *templateExpander) inherit(action *model.Action, blockType string) *model.Action {
+	p := e.p
+	extendsNode := p.posMap[&action.Extends]
+	kind := "Action"
+	if blockType == "template" {
+		kind = "Template"
+	}
+	var parents []*model.Action
+	for _, name := range action.Extends {
+		template, ok := e.templates[name]
+		if !ok {
+			p.addError(extendsNode, CodeUnknownTemplate, "%s `%s' extends unknown template `%s'", kind, action.Identifier, name)
+			continue
+		}
+		if e.expanding[name] {
+			p.addError(extendsNode, CodeCircularTemplate, "Circular `extends' on template `%s'", name)
+			continue
+		}
+		parents = append(parents, e.expand(template))
+	}
+
+	ret := &model.Action{
+		Identifier: action.Identifier,
+		Uses:       action.Uses,
+		Runs:       action.Runs,
+		Args:       action.Args,
+		Extends:    action.Extends,
+		Matrix:     action.Matrix,
+		Timeout:    action.Timeout,
+		Retries:    action.Retries,
+		If:         action.If,
+	}
+	e.p.position(ret, action)
+	e.p.position(&ret.Extends, &action.Extends)
+	e.p.position(&ret.Runs, &action.Runs)
+	e.p.position(&ret.Args, &action.Args)
+	e.p.position(&ret.Matrix, &action.Matrix)
+	e.p.position(&ret.Timeout, &action.Timeout)
+	e.p.position(&ret.Retries, &action.Retries)
+	e.p.position(&ret.If, &action.If)
+
+	conflict := func(attribute string, a, b *model.Action) {
+		p.addError(extendsNode, CodeTemplateConflict, "%s `%s' inherits conflicting `%s' from templates `%s' and `%s'; set it in the %s to choose one", kind, action.Identifier, attribute, a.Identifier, b.Identifier, blockType)
+	}
+	var usesFrom, runsFrom, argsFrom, matrixFrom, timeoutFrom, retriesFrom, ifFrom *model.Action
+	_, ownTimeout := p.posMap[&action.Timeout]
+	_, ownRetries := p.posMap[&action.Retries]
+	for _, parent := range parents {
+		if action.Uses == nil && parent.Uses != nil {
+			if usesFrom == nil {
+				ret.Uses, usesFrom = parent.Uses, parent
+			} else if !reflect.DeepEqual(ret.Uses, parent.Uses) {
+				conflict("uses", usesFrom, parent)
+			}
+		}
+		if action.Runs == nil && parent.Runs != nil {
+			if runsFrom == nil {
+				ret.Runs, runsFrom = parent.Runs, parent
+				e.p.position(&ret.Runs, &parent.Runs)
+			} else if !reflect.DeepEqual(ret.Runs, parent.Runs) {
+				conflict("runs", runsFrom, parent)
+			}
+		}
+		if action.Args == nil && parent.Args != nil {
+			if argsFrom == nil {
+				ret.Args, argsFrom = parent.Args, parent
+				e.p.position(&ret.Args, &parent.Args)
+			} else if !reflect.DeepEqual(ret.Args, parent.Args) {
+				conflict("args", argsFrom, parent)
+			}
+		}
+		if action.Matrix == nil && parent.Matrix != nil {
+			if matrixFrom == nil {
+				ret.Matrix, matrixFrom = parent.Matrix, parent
+				e.p.position(&ret.Matrix, &parent.Matrix)
+			} else if !reflect.DeepEqual(ret.Matrix, parent.Matrix) {
+				conflict("matrix", matrixFrom, parent)
+			}
+		}
+		if _, ok := p.posMap[&parent.Timeout]; ok && !ownTimeout {
+			if timeoutFrom == nil {
+				ret.Timeout, timeoutFrom = parent.Timeout, parent
+				e.p.position(&ret.Timeout, &parent.Timeout)
+			} else if ret.Timeout != parent.Timeout {
+				conflict("timeout", timeoutFrom, parent)
+			}
+		}
+		if _, ok := p.posMap[&parent.Retries]; ok && !ownRetries {
+			if retriesFrom == nil {
+				ret.Retries, retriesFrom = parent.Retries, parent
+				e.p.position(&ret.Retries, &parent.Retries)
+			} else if ret.Retries != parent.Retries {
+				conflict("retries", retriesFrom, parent)
+			}
+		}
+		if action.If == nil && parent.If != nil {
+			if ifFrom == nil {
+				ret.If, ifFrom = parent.If, parent
+				e.p.position(&ret.If, &parent.If)
+			} else if ret.If.String() != parent.If.String() {
+				conflict("if", ifFrom, parent)
+			}
+		}
+	}
+
+	// env is merged variable by variable.
+	envFrom := make(map[string]*model.Action)
+	for _, parent := range parents {
+		for _, k := range sortedKeys(parent.Env) {
+			if _, own := action.Env[k]; own {
+				continue
+			}
+			if from, ok := envFrom[k]; ok {
+				if ret.Env[k] != parent.Env[k] {
+					conflict("env", from, parent)
+				}
+				continue
+			}
+			if ret.Env == nil {
+				ret.Env = make(map[string]string)
+				e.p.position(&ret.Env, &parent.Env)
+			}
+			ret.Env[k] = parent.Env[k]
+			envFrom[k] = parent
+		}
+	}
+	if action.Env != nil {
+		if ret.Env == nil {
+			ret.Env = make(map[string]string, len(action.Env))
+		}
+		for k, v := range action.Env {
+			ret.Env[k] = v
+		}
+		e.p.position(&ret.Env, &action.Env)
+	}
+
+	// needs and secrets are combined.
+	ret.Needs = e.combine(parents, action, func(a *model.Action) *[]string { return &a.Needs }, &ret.Needs)
+	ret.Secrets = e.combine(parents, action, func(a *model.Action) *[]string { return &a.Secrets }, &ret.Secrets)
+	return ret
+}
+
+// combine returns the values of a list attribute of action and of its
+// parents, parents first and without duplicates, and records the
+// position of the action's own value, or of the first parent's, for dest.
+func (e *templateExpander) combine(parents []*model.Action, action *model.Action, field func(*model.Action) *[]string, dest *[]string) []string {
+	var ret []string
+	found := false
+	for _, parent := range parents {
+		if values := *field(parent); values != nil {
+			if !found {
+				e.p.position(dest, field(parent))
+			}
+			ret = append(ret, values...)
+			found = true
+		}
+	}
+	if values := *field(action); values != nil {
+		ret = append(ret, values...)
+		e.p.position(dest, field(action))
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return uniqStrings(ret)
+}
+
+// position records the position of src, if it has one, as that of dest.
+func (p *Parser) position(dest, src interface{}) {
+	if node, ok := p.posMap[src]; ok {
+		p.posMap[dest] = node
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/model"
+)
+
+// The bounds of the `timeout' and `retries' attributes of an action.  The
+// messages in parseTimeout give minTimeout and maxTimeout as written.
+const (
+	minTimeout = time.Second
+	maxTimeout = 24 * time.Hour
+	maxRetries = 10
+)
+
+// timeoutRe matches the durations that `timeout' allows: whole numbers of
+// hours, minutes, and seconds, such as "10m" or "1h30m".
+var timeoutRe = regexp.MustCompile(`\A(?:[0-9]+h)?(?:[0-9]+m)?(?:[0-9]+s)?\z`)
+
+// parseTimeout parses the `timeout' attribute of an action or template.
+func (p *Parser) parseTimeout(action *model.Action, node ast.Node) {
+	if _, ok := p.posMap[&action.Timeout]; ok {
+		p.addWarning(node, CodeAttributeRedefined, "`timeout' redefined in %s `%s'", p.blockType, action.Identifier)
+		// continue, allowing the redefinition
+	}
+	str, ok := p.literalToString(node)
+	if !ok {
+		return
+	}
+	if str == "" || !timeoutRe.MatchString(str) {
+		p.addError(node, CodeInvalidTimeout, "`timeout' in %s `%s' must be a duration in hours, minutes, and seconds, such as `10m' or `1h30m', got `%s'", p.blockType, action.Identifier, str)
+		return
+	}
+	timeout, err := time.ParseDuration(str)
+	if err != nil || timeout < minTimeout || timeout > maxTimeout {
+		p.addError(node, CodeInvalidTimeout, "`timeout' in %s `%s' must be between 1s and 24h, got `%s'", p.blockType, action.Identifier, str)
+		return
+	}
+	action.Timeout = timeout
+	p.posMap[&action.Timeout] = node
+}
+
+// parseRetries parses the `retries' attribute of an action or template.
+func (p *Parser) parseRetries(action *model.Action, node ast.Node) {
+	if _, ok := p.posMap[&action.Retries]; ok {
+		p.addWarning(node, CodeAttributeRedefined, "`retries' redefined in %s `%s'", p.blockType, action.Identifier)
+		// continue, allowing the redefinition
+	}
+	retries, ok := p.literalToInt(node)
+	if !ok {
+		return
+	}
+	if retries < 0 || retries > maxRetries {
+		p.addError(node, CodeInvalidRetries, "`retries' in %s `%s' must be between 0 and %d, got %d", p.blockType, action.Identifier, maxRetries, retries)
+		return
+	}
+	action.Retries = int(retries)
+	p.posMap[&action.Retries] = node
+}
@@ -0,0 +1,745 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actions/workflow-parser/internal/ast"
+	"github.com/actions/workflow-parser/internal/token"
+	"github.com/actions/workflow-parser/internal/yaml"
+	"github.com/actions/workflow-parser/model"
+)
+
+// ParseYAML parses a GitHub Actions workflow file, one of the YAML files
+// under .github/workflows, into the configuration of the equivalent
+// .workflow file, and validates it the same way, so that both formats can
+// be checked, graphed, and compared with the same tools.
+//
+// Only what this language can express is read.  The workflow is named
+// after its `name'.  Each step that uses an action becomes an action that
+// needs the step before it, and the first step of a job needs the last
+// step of each job in the job's `needs'.  Steps that check out the
+// repository are left out, since actions always run with it checked out.
+// Everything else that cannot be expressed, such as `run' steps, is
+// reported with CodeUnsupportedYAML, as a WARNING, and values of the wrong
+// type with CodeInvalidYAML.  Diagnostics have the line and column in the
+// YAML.  The version of the configuration is the first one with all the
+// features it uses.
+//
+// As with ParseWithResult, the returned error is only non-nil for system
+// errors.
+func ParseYAML(reader io.Reader, options ...OptionFunc) (*ParseResult, error) {
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := yaml.Parse(b)
+	if err != nil {
+		se, ok := err.(*yaml.SyntaxError)
+		if !ok {
+			return nil, err
+		}
+		pos := ErrorPos{Line: se.Line, Column: se.Column}
+		return &ParseResult{
+			Configuration: &model.Configuration{},
+			Errors:        errorList{newFatal(pos, CodeYAMLSyntax, "%s", se.Message)},
+			message:       "unable to parse",
+			src:           b,
+			options:       options,
+			yaml:          true,
+		}, nil
+	}
+
+	p := newParser(options...)
+	p.parseYAMLWorkflow(doc)
+	p.validate()
+	p.applySuppressions()
+	p.errors.sort()
+	p.errors = p.errors.dedupe()
+	result := p.result(b, options)
+	result.yaml = true
+	return result, nil
+}
+
+// isYAMLFile returns true if filename is a GitHub Actions workflow file,
+// for ParseYAML, rather than a .workflow file.
+func isYAMLFile(filename string) bool {
+	return strings.HasSuffix(filename, ".yml") || strings.HasSuffix(filename, ".yaml")
+}
+
+// yamlJob is a job of a GitHub Actions workflow.  first and last are its
+// first and last actions, or nil if none of its steps became actions.
+type yamlJob struct {
+	id          string
+	needs       []string
+	needsNode   *yaml.Node
+	first, last *model.Action
+}
+
+// parseYAMLWorkflow fills in the Parser from a GitHub Actions workflow.
+func (p *Parser) parseYAMLWorkflow(doc *yaml.Node) {
+	if !p.yamlMapping(doc, "the workflow file") {
+		return
+	}
+	workflow := &model.Workflow{Identifier: "workflow"}
+	p.posMap[workflow] = yamlNode(doc)
+	if name := doc.Get("name"); name != nil {
+		if id, ok := p.yamlString(name, "`name'"); ok && id != "" {
+			workflow.Identifier = id
+			p.posMap[workflow] = yamlNode(name)
+		}
+	}
+
+	where := fmt.Sprintf("workflow `%s'", workflow.Identifier)
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, val := doc.Content[i], doc.Content[i+1]
+		switch key.Value {
+		case "name":
+		case "on":
+			p.parseYAMLOn(workflow, val)
+		case "env":
+			workflow.Env = p.parseYAMLEnv(val, where, nil)
+			p.posMap[&workflow.Env] = yamlNode(val)
+			p.requireVersion(versionWorkflowEnv)
+		case "jobs":
+			p.parseYAMLJobs(workflow, val)
+		case "run-name", "permissions", "concurrency", "defaults":
+			p.addWarning(yamlNode(key), CodeUnsupportedYAML, "`%s' in %s is not supported, so it was ignored", key.Value, where)
+		default:
+			p.addWarning(yamlNode(key), CodeUnknownWorkflowAttribute, "Unknown workflow attribute `%s'", key.Value)
+		}
+	}
+	if doc.Get("jobs") == nil {
+		p.addError(p.posMap[workflow], CodeInvalidYAML, "Workflow `%s' must have `jobs'", workflow.Identifier)
+	}
+	p.workflows = append(p.workflows, workflow)
+}
+
+// yamlEvent is an event in the `on' of a GitHub Actions workflow, with
+// its `types' and `branches', if any.
+type yamlEvent struct {
+	name    string
+	filters map[string]*yaml.Node
+}
+
+// parseYAMLOn parses the `on' of a GitHub Actions workflow, which is an
+// event, a list of events, or a mapping of events to their settings.
+// Only the `types' and `branches' settings can be expressed, and only if
+// every event they apply to has the same ones.
+func (p *Parser) parseYAMLOn(workflow *model.Workflow, node *yaml.Node) {
+	where := fmt.Sprintf("`on' of workflow `%s'", workflow.Identifier)
+	workflow.On = []model.On{}
+	var events []yamlEvent
+	addEvent := func(name string, node *yaml.Node, filters map[string]*yaml.Node) {
+		if !p.isAllowedEventType(name) {
+			p.addWarning(yamlNode(node), CodeUnsupportedYAML, "Event `%s' in %s is not supported, so it was left out", name, where)
+			return
+		}
+		workflow.On = append(workflow.On, p.onEvent(name))
+		events = append(events, yamlEvent{name: name, filters: filters})
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		addEvent(node.Value, node, nil)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if name, ok := p.yamlString(item, where); ok {
+				addEvent(name, item, nil)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			if key.Value == "schedule" {
+				p.parseYAMLSchedule(workflow, val)
+				continue
+			}
+			filters := make(map[string]*yaml.Node)
+			if !val.IsNull() && p.yamlMapping(val, fmt.Sprintf("`%s' in %s", key.Value, where)) {
+				for j := 0; j+1 < len(val.Content); j += 2 {
+					switch setting := val.Content[j]; setting.Value {
+					case "types", "branches":
+						if !val.Content[j+1].IsNull() {
+							filters[setting.Value] = val.Content[j+1]
+						}
+					default:
+						p.addWarning(yamlNode(setting), CodeUnsupportedYAML, "`%s' of event `%s' in workflow `%s' is not supported, so it was ignored", setting.Value, key.Value, workflow.Identifier)
+					}
+				}
+			}
+			addEvent(key.Value, key, filters)
+		}
+	}
+
+	if len(workflow.On) > 1 {
+		p.requireVersion(versionOnList)
+	}
+	var filterNode *yaml.Node
+	workflow.Types, filterNode = p.parseYAMLFilter(workflow, events, "types", func(et EventType) bool { return len(et.ActivityTypes) > 0 })
+	if workflow.Types != nil {
+		p.posMap[&workflow.Types] = yamlNode(filterNode)
+	}
+	workflow.Branches, filterNode = p.parseYAMLFilter(workflow, events, "branches", func(et EventType) bool { return et.Branches })
+	if workflow.Branches != nil {
+		p.posMap[&workflow.Branches] = yamlNode(filterNode)
+	}
+	if workflow.Types != nil || workflow.Branches != nil {
+		p.requireVersion(versionFilters)
+	}
+	p.checkFilters(workflow)
+}
+
+// parseYAMLFilter returns the `types' or `branches' of the events, and
+// the node of the first, if every event that has the filter, or that it
+// applies to, has the same values.  If not, the filter cannot be
+// expressed, since a workflow has one for all of its events.
+func (p *Parser) parseYAMLFilter(workflow *model.Workflow, events []yamlEvent, name string, applies func(EventType) bool) ([]string, *yaml.Node) {
+	var values []string
+	var first *yaml.Node
+	seen := false
+	for _, event := range events {
+		node := event.filters[name]
+		if node == nil && !applies(p.eventTypes[strings.ToLower(event.name)]) {
+			continue
+		}
+		var eventValues []string
+		if node != nil {
+			var ok bool
+			eventValues, ok = p.yamlStrings(node, fmt.Sprintf("`%s' of event `%s' in workflow `%s'", name, event.name, workflow.Identifier))
+			if !ok {
+				return nil, nil
+			}
+			if eventValues == nil {
+				eventValues = []string{}
+			}
+		}
+		if !seen {
+			values, first, seen = eventValues, node, true
+			continue
+		}
+		if !reflect.DeepEqual(values, eventValues) {
+			if node == nil {
+				node = first
+			}
+			p.addWarning(yamlNode(node), CodeUnsupportedYAML, "The events in `on' of workflow `%s' have different `%s', which is not supported, so `%s' was ignored", workflow.Identifier, name, name)
+			return nil, nil
+		}
+	}
+	return values, first
+}
+
+// parseYAMLSchedule parses the `schedule' event of a GitHub Actions
+// workflow, which lists `cron' expressions.
+func (p *Parser) parseYAMLSchedule(workflow *model.Workflow, node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		p.addError(yamlNode(node), CodeInvalidOn, "`schedule' in `on' of workflow `%s' must be a list of `cron' expressions", workflow.Identifier)
+		return
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode || item.Get("cron") == nil {
+			p.addError(yamlNode(item), CodeInvalidOn, "`schedule' in `on' of workflow `%s' must be a list of `cron' expressions", workflow.Identifier)
+			continue
+		}
+		cron, ok := p.yamlString(item.Get("cron"), "`cron'")
+		if !ok {
+			continue
+		}
+		expression := "schedule(" + cron + ")"
+		if !IsSchedule(expression) {
+			p.addError(yamlNode(item.Get("cron")), CodeInvalidOn, "Workflow `%s' has an invalid `cron' expression `%s'", workflow.Identifier, cron)
+			continue
+		}
+		workflow.On = append(workflow.On, &model.OnSchedule{Expression: expression})
+	}
+}
+
+// parseYAMLJobs parses the jobs of a GitHub Actions workflow into actions,
+// and sets the workflow to resolve the last action of each job that no
+// other job needs.
+func (p *Parser) parseYAMLJobs(workflow *model.Workflow, node *yaml.Node) {
+	if !p.yamlMapping(node, fmt.Sprintf("`jobs' in workflow `%s'", workflow.Identifier)) {
+		return
+	}
+	p.posMap[&workflow.Resolves] = yamlNode(node)
+	jobs := make(map[string]*yamlJob)
+	var order []*yamlJob
+	identifiers := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		job := p.parseYAMLJob(node.Content[i].Value, node.Content[i+1], identifiers)
+		jobs[job.id] = job
+		order = append(order, job)
+	}
+
+	needed := make(map[string]bool)
+	for _, job := range order {
+		for _, need := range job.needs {
+			if jobs[need] == nil {
+				p.addError(yamlNode(job.needsNode), CodeInvalidYAML, "Job `%s' needs nonexistent job `%s'", job.id, need)
+				continue
+			}
+			needed[need] = true
+			if job.first != nil {
+				job.first.Needs = append(job.first.Needs, lastActions(jobs, need, make(map[string]bool))...)
+				p.posMap[&job.first.Needs] = yamlNode(job.needsNode)
+			}
+		}
+	}
+	for _, job := range order {
+		if !needed[job.id] {
+			workflow.Resolves = append(workflow.Resolves, lastActions(jobs, job.id, make(map[string]bool))...)
+		}
+	}
+	workflow.Resolves = uniqStrings(workflow.Resolves)
+}
+
+// lastActions returns the identifier of the last action of the job id, or
+// if it has none, of the last actions of the jobs it needs.
+func lastActions(jobs map[string]*yamlJob, id string, seen map[string]bool) []string {
+	job := jobs[id]
+	if job == nil || seen[id] {
+		return nil
+	}
+	if job.last != nil {
+		return []string{job.last.Identifier}
+	}
+	seen[id] = true
+	var ret []string
+	for _, need := range job.needs {
+		ret = append(ret, lastActions(jobs, need, seen)...)
+	}
+	return ret
+}
+
+// parseYAMLJob parses a job of a GitHub Actions workflow, adding an action
+// for each of its steps that uses an action.  identifiers holds the
+// identifiers of the actions so far.
+func (p *Parser) parseYAMLJob(id string, node *yaml.Node, identifiers map[string]bool) *yamlJob {
+	job := &yamlJob{id: id}
+	where := fmt.Sprintf("job `%s'", id)
+	if !p.yamlMapping(node, where) {
+		return job
+	}
+	var cond, timeout, env, steps *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "needs":
+			job.needs, _ = p.yamlStrings(val, "`needs' in "+where)
+			job.needsNode = val
+		case "if":
+			cond = val
+		case "timeout-minutes":
+			timeout = val
+		case "env":
+			env = val
+		case "steps":
+			steps = val
+		case "name", "runs-on":
+			// The name and runner of a job do not change what its actions
+			// do.
+		default:
+			p.addWarning(yamlNode(key), CodeUnsupportedYAML, "`%s' in %s is not supported, so it was ignored", key.Value, where)
+		}
+	}
+	if steps == nil {
+		p.addError(yamlNode(node), CodeInvalidYAML, "Job `%s' must have `steps'", id)
+		return job
+	}
+	if steps.Kind != yaml.SequenceNode {
+		p.addError(yamlNode(steps), CodeInvalidYAML, "Expected a list for `steps' in %s, got %s", where, yamlKind(steps))
+		return job
+	}
+
+	var jobEnv map[string]string
+	var jobSecrets []string
+	if env != nil {
+		jobEnv = p.parseYAMLEnv(env, where, &jobSecrets)
+	}
+	var actions []*model.Action
+	for i, step := range steps.Content {
+		action := p.parseYAMLStep(job, i, step, cond, identifiers)
+		if action == nil {
+			continue
+		}
+		for k, v := range jobEnv {
+			if _, ok := action.Env[k]; !ok {
+				if action.Env == nil {
+					action.Env = make(map[string]string)
+					p.posMap[&action.Env] = yamlNode(env)
+				}
+				action.Env[k] = v
+			}
+		}
+		for _, secret := range jobSecrets {
+			if _, ok := action.Env[secret]; !ok && !containsString(action.Secrets, secret) {
+				if action.Secrets == nil {
+					p.posMap[&action.Secrets] = yamlNode(env)
+				}
+				action.Secrets = append(action.Secrets, secret)
+			}
+		}
+		if len(actions) > 0 {
+			action.Needs = []string{actions[len(actions)-1].Identifier}
+			p.posMap[&action.Needs] = yamlNode(step)
+		}
+		actions = append(actions, action)
+	}
+
+	if timeout != nil {
+		if len(actions) == 1 && actions[0].Timeout == 0 {
+			p.parseYAMLTimeout(actions[0], timeout, where)
+		} else {
+			p.addWarning(yamlNode(timeout), CodeUnsupportedYAML, "`timeout-minutes' in %s limits all of its steps together, which is not supported, so it was ignored", where)
+		}
+	}
+	if len(actions) > 0 {
+		job.first, job.last = actions[0], actions[len(actions)-1]
+	}
+	p.actions = append(p.actions, actions...)
+	return job
+}
+
+// parseYAMLStep parses a step of a job into an action, or returns nil if
+// the step does not use an action.  cond is the `if' of the job, if any.
+func (p *Parser) parseYAMLStep(job *yamlJob, idx int, node *yaml.Node, cond *yaml.Node, identifiers map[string]bool) *model.Action {
+	where := fmt.Sprintf("step %d of job `%s'", idx+1, job.id)
+	if !p.yamlMapping(node, where) {
+		return nil
+	}
+	base := ""
+	for _, key := range []string{"name", "id", "uses"} {
+		if val := node.Get(key); val != nil && base == "" {
+			base, _ = p.yamlString(val, fmt.Sprintf("`%s' in %s", key, where))
+		}
+	}
+	if base != "" {
+		where = fmt.Sprintf("step `%s' of job `%s'", base, job.id)
+	}
+	if run := node.Get("run"); run != nil {
+		p.addWarning(yamlNode(run), CodeUnsupportedYAML, "`run' in %s is not supported, so the step was left out", where)
+		return nil
+	}
+	usesNode := node.Get("uses")
+	if usesNode == nil {
+		p.addError(yamlNode(node), CodeInvalidYAML, "%s must have `uses' or `run'", strings.ToUpper(where[:1])+where[1:])
+		return nil
+	}
+	usesStr, ok := p.yamlString(usesNode, "`uses' in "+where)
+	if !ok {
+		return nil
+	}
+	uses := usesFromString(usesStr)
+	if repo, ok := uses.(*model.UsesRepository); ok && repo.Repository == "actions/checkout" {
+		if with := node.Get("with"); with != nil {
+			p.addWarning(yamlNode(with), CodeUnsupportedYAML, "`with' in %s is not supported, so it was ignored", where)
+		}
+		return nil
+	}
+
+	action := &model.Action{Identifier: yamlIdentifier(base, identifiers), Uses: uses}
+	p.posMap[action] = yamlNode(node)
+	if _, ok := uses.(*model.UsesInvalid); ok {
+		p.addError(yamlNode(usesNode), CodeInvalidUses, "The `uses' attribute must be a path, a Docker image, or owner/repo@ref")
+	}
+	var stepCond *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "name", "id", "uses":
+		case "with":
+			p.parseYAMLWith(action, val, where)
+		case "env":
+			action.Env = p.parseYAMLEnv(val, where, &action.Secrets)
+			p.posMap[&action.Env] = yamlNode(val)
+			p.posMap[&action.Secrets] = yamlNode(val)
+		case "if":
+			stepCond = val
+		case "timeout-minutes":
+			p.parseYAMLTimeout(action, val, where)
+		default:
+			p.addWarning(yamlNode(key), CodeUnsupportedYAML, "`%s' in %s is not supported, so it was ignored", key.Value, where)
+		}
+	}
+	p.parseYAMLIf(action, cond, stepCond)
+	return action
+}
+
+// yamlIdentifier returns base as the identifier of an action, with a
+// number added if another action already has it.
+func yamlIdentifier(base string, identifiers map[string]bool) string {
+	id := base
+	for i := 2; identifiers[id]; i++ {
+		id = fmt.Sprintf("%s (%d)", base, i)
+	}
+	identifiers[id] = true
+	return id
+}
+
+// parseYAMLWith parses the inputs of a step that uses a Docker action:
+// the `entrypoint', which becomes `runs', and the `args'.
+func (p *Parser) parseYAMLWith(action *model.Action, node *yaml.Node, where string) {
+	if !p.yamlMapping(node, "`with' in "+where) {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if key.Value != "entrypoint" && key.Value != "args" {
+			p.addWarning(yamlNode(key), CodeUnsupportedYAML, "`with.%s' in %s is not supported, so it was ignored", key.Value, where)
+			continue
+		}
+		str, ok := p.yamlString(val, fmt.Sprintf("`with.%s' in %s", key.Value, where))
+		if !ok {
+			continue
+		}
+		if strings.Contains(str, "${{") {
+			p.addWarning(yamlNode(val), CodeUnsupportedYAML, "`with.%s' in %s has an expression, which is not supported, so it was kept as written", key.Value, where)
+		}
+		switch {
+		case key.Value == "args":
+			action.Args = yamlCommand(str)
+		case strings.ContainsAny(str, " \t\n"):
+			action.Runs = &model.ListCommand{Values: []string{str}}
+		case str != "":
+			action.Runs = &model.StringCommand{Value: str}
+		}
+	}
+}
+
+// yamlCommand returns the `args' of a step as a command.  Arguments are
+// separated by whitespace, except in double quotes, in which a backslash
+// escapes the next character.
+func yamlCommand(str string) model.Command {
+	if !strings.ContainsAny(str, "\"\\") {
+		return &model.StringCommand{Value: str}
+	}
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(str):
+			i++
+			arg.WriteByte(str[i])
+		case c == '"':
+			quoted, inArg = !quoted, true
+		case !quoted && (c == ' ' || c == '\t' || c == '\n'):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return &model.ListCommand{Values: args}
+}
+
+// yamlSecretRe matches an environment variable set from a secret.
+var yamlSecretRe = regexp.MustCompile(`\A\$\{\{\s*secrets\.([A-Za-z_][A-Za-z_0-9]*)\s*\}\}\z`)
+
+// parseYAMLEnv parses the `env' of a workflow, job, or step.  If secrets
+// is not nil, variables set from the secret of the same name are added
+// to it, instead of the environment.
+func (p *Parser) parseYAMLEnv(node *yaml.Node, where string, secrets *[]string) map[string]string {
+	if !p.yamlMapping(node, "`env' in "+where) {
+		return nil
+	}
+	env := make(map[string]string)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		str, ok := p.yamlString(val, fmt.Sprintf("`%s' in `env' of %s", key.Value, where))
+		if !ok {
+			continue
+		}
+		if m := yamlSecretRe.FindStringSubmatch(str); m != nil && m[1] == key.Value && secrets != nil {
+			*secrets = append(*secrets, key.Value)
+			continue
+		}
+		if strings.Contains(str, "${{") {
+			p.addWarning(yamlNode(val), CodeUnsupportedYAML, "`%s' in `env' of %s has an expression, which is not supported, so it was left out", key.Value, where)
+			continue
+		}
+		env[key.Value] = str
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return env
+}
+
+// parseYAMLTimeout parses the `timeout-minutes' of a step or job.
+func (p *Parser) parseYAMLTimeout(action *model.Action, node *yaml.Node, where string) {
+	minutes, err := strconv.Atoi(node.Value)
+	if node.Kind != yaml.ScalarNode || err != nil || minutes <= 0 {
+		p.addError(yamlNode(node), CodeInvalidTimeout, "`timeout-minutes' in %s must be a whole number of minutes, got `%s'", where, node.Value)
+		return
+	}
+	timeout := time.Duration(minutes) * time.Minute
+	if timeout > maxTimeout {
+		p.addError(yamlNode(node), CodeInvalidTimeout, "`timeout-minutes' in %s must be at most %d, got %d", where, maxTimeout/time.Minute, minutes)
+		return
+	}
+	action.Timeout = timeout
+	p.posMap[&action.Timeout] = yamlNode(node)
+	p.requireVersion(versionTimeout)
+}
+
+// parseYAMLIf parses the `if' of a step and of its job, either of which
+// may be nil, into the condition of an action.
+func (p *Parser) parseYAMLIf(action *model.Action, nodes ...*yaml.Node) {
+	var conds []string
+	var node *yaml.Node
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		str, ok := p.yamlString(n, fmt.Sprintf("`if' in action `%s'", action.Identifier))
+		if !ok {
+			return
+		}
+		src := yamlCondition(str)
+		if _, err := model.CompileCondition(src); err != nil {
+			msg := err.Error()
+			if ce, ok := err.(*model.ConditionError); ok {
+				msg = ce.Message
+			}
+			p.addError(yamlNode(n), CodeInvalidCondition, "Invalid `if' in action `%s': %s", action.Identifier, msg)
+			return
+		}
+		conds = append(conds, src)
+		node = n
+	}
+	if len(conds) == 0 {
+		return
+	}
+	src := conds[0]
+	if len(conds) == 2 {
+		src = "(" + conds[0] + ") && (" + conds[1] + ")"
+	}
+	// Both conditions compiled, so together they do too.
+	action.If, _ = model.CompileCondition(src)
+	p.posMap[&action.If] = yamlNode(node)
+	p.requireVersion(versionIf)
+}
+
+// yamlCondition returns the source of an `if' in GitHub Actions in the
+// language of conditions here: without `${{ }}' around it, and with
+// github.event written as event.
+func yamlCondition(src string) string {
+	src = strings.TrimSpace(src)
+	if strings.HasPrefix(src, "${{") && strings.HasSuffix(src, "}}") {
+		src = strings.TrimSpace(src[3 : len(src)-2])
+	}
+	const context = "github.event"
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\'' {
+			quoted = !quoted
+		}
+		if !quoted && strings.HasPrefix(src[i:], context) &&
+			(i == 0 || !isYAMLNameByte(src[i-1])) &&
+			(i+len(context) == len(src) || src[i+len(context)] == '.' || !isYAMLNameByte(src[i+len(context)])) {
+			b.WriteString("event")
+			i += len(context) - 1
+			continue
+		}
+		b.WriteByte(src[i])
+	}
+	return b.String()
+}
+
+// isYAMLNameByte returns true if c can be part of a name in a GitHub
+// Actions expression.
+func isYAMLNameByte(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// requireVersion raises the version of a configuration read from YAML to
+// one that has a feature it uses.
+func (p *Parser) requireVersion(version int) {
+	if version > p.version {
+		p.version = version
+	}
+}
+
+// yamlNode returns an AST node at the position of n, for diagnostics and
+// p.posMap.
+func yamlNode(n *yaml.Node) ast.Node {
+	return &ast.LiteralType{Token: token.Token{Type: token.STRING, Pos: token.Pos{Line: n.Line, Column: n.Column}}}
+}
+
+// yamlKind returns the kind of n, for diagnostics.
+func yamlKind(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	}
+	return "string"
+}
+
+// yamlMapping returns true if n is a mapping, and otherwise reports what
+// it is instead.
+func (p *Parser) yamlMapping(n *yaml.Node, what string) bool {
+	if n.Kind != yaml.MappingNode {
+		p.addError(yamlNode(n), CodeInvalidYAML, "Expected a mapping for %s, got %s", what, yamlKind(n))
+		return false
+	}
+	return true
+}
+
+// yamlString returns the value of n if it is a scalar, and otherwise
+// reports what it is instead.
+func (p *Parser) yamlString(n *yaml.Node, what string) (string, bool) {
+	if n.Kind != yaml.ScalarNode {
+		p.addError(yamlNode(n), CodeInvalidYAML, "Expected a string for %s, got %s", what, yamlKind(n))
+		return "", false
+	}
+	return n.Value, true
+}
+
+// yamlStrings returns the values of n if it is a scalar or a list of
+// scalars.  A null is no values.
+func (p *Parser) yamlStrings(n *yaml.Node, what string) ([]string, bool) {
+	switch {
+	case n.IsNull():
+		return nil, true
+	case n.Kind == yaml.ScalarNode:
+		return []string{n.Value}, true
+	case n.Kind != yaml.SequenceNode:
+		p.addError(yamlNode(n), CodeInvalidYAML, "Expected a string or a list for %s, got %s", what, yamlKind(n))
+		return nil, false
+	}
+	ret := make([]string, 0, len(n.Content))
+	for _, item := range n.Content {
+		if str, ok := p.yamlString(item, what); ok {
+			ret = append(ret, str)
+		}
+	}
+	return ret, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/workflow-parser/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlSource = `name: CI
+on:
+  push:
+    branches: [main]
+  pull_request:
+    types: [opened]
+    branches: [main]
+env:
+  LEVEL: debug
+jobs:
+  build:
+    steps:
+      - uses: actions/checkout@v4
+      - name: Build
+        uses: docker://golang:1.12
+        with:
+          entrypoint: go
+          args: build ./...
+        env:
+          TOKEN: ${{ secrets.TOKEN }}
+      - uses: ./test
+  deploy:
+    needs: build
+    if: github.event.ref == 'refs/heads/main'
+    steps:
+      - name: Deploy
+        uses: ./deploy
+        timeout-minutes: 5
+`
+
+func TestParseYAML(t *testing.T) {
+	result, err := ParseYAML(strings.NewReader(yamlSource))
+	require.NoError(t, err)
+	assert.Empty(t, describeErrors(result.Errors))
+
+	config := result.Configuration
+	require.Len(t, config.Workflows, 1)
+	workflow := config.Workflows[0]
+	assert.Equal(t, "CI", workflow.Identifier)
+	assert.Equal(t, []model.On{&model.OnEvent{Event: "push", Branches: true}, &model.OnEvent{Event: "pull_request", Branches: true}}, workflow.On)
+	assert.Equal(t, []string{"opened"}, workflow.Types)
+	assert.Equal(t, []string{"main"}, workflow.Branches)
+	assert.Equal(t, map[string]string{"LEVEL": "debug"}, workflow.Env)
+	assert.Equal(t, []string{"Deploy"}, workflow.Resolves)
+
+	require.Len(t, config.Actions, 3)
+	build := config.GetAction("Build")
+	require.NotNil(t, build)
+	assert.Equal(t, &model.UsesDockerImage{Image: "golang:1.12"}, build.Uses)
+	assert.Equal(t, &model.StringCommand{Value: "go"}, build.Runs)
+	assert.Equal(t, &model.StringCommand{Value: "build ./..."}, build.Args)
+	assert.Equal(t, []string{"TOKEN"}, build.Secrets)
+	assert.Nil(t, build.Env)
+	assert.Empty(t, build.Needs)
+
+	test := config.GetAction("./test")
+	require.NotNil(t, test)
+	assert.Equal(t, []string{"Build"}, test.Needs)
+
+	deploy := config.GetAction("Deploy")
+	require.NotNil(t, deploy)
+	assert.Equal(t, []string{"./test"}, deploy.Needs)
+	assert.Equal(t, 5*time.Minute, deploy.Timeout)
+	require.NotNil(t, deploy.If)
+	assert.Equal(t, "event.ref == 'refs/heads/main'", deploy.If.String())
+
+	assert.Equal(t, versionIf, config.Version)
+}
+
+func TestParseYAMLDiagnostics(t *testing.T) {
+	result, err := ParseYAML(strings.NewReader(`name: CI
+on:
+  push:
+    paths: [src]
+  workflow_dispatch:
+permissions: read-all
+colour: blue
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    strategy:
+      matrix: {go: [1, 2]}
+    steps:
+      - run: make
+      - uses: actions/checkout@v4
+        with:
+          fetch-depth: 0
+      - uses: ./a
+        with:
+          input: 1
+        env:
+          SHA: ${{ github.sha }}
+      - uses: ./b
+        timeout-minutes: soon
+        if: ${{ github.sha == 'abc' }}
+      - name: nothing
+  test:
+    needs: [build, lint]
+    steps: none
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"4:5 1 WF701 `paths' of event `push' in workflow `CI' is not supported, so it was ignored",
+		"5:3 1 WF701 Event `workflow_dispatch' in `on' of workflow `CI' is not supported, so it was left out",
+		"6:1 1 WF701 `permissions' in workflow `CI' is not supported, so it was ignored",
+		"7:1 1 WF304 Unknown workflow attribute `colour'",
+		"11:5 1 WF701 `strategy' in job `build' is not supported, so it was ignored",
+		"14:14 1 WF701 `run' in step 1 of job `build' is not supported, so the step was left out",
+		"17:11 1 WF701 `with' in step `actions/checkout@v4' of job `build' is not supported, so it was ignored",
+		"20:11 1 WF701 `with.input' in step `./a' of job `build' is not supported, so it was ignored",
+		"22:16 1 WF701 `SHA' in `env' of step `./a' of job `build' has an expression, which is not supported, so it was left out",
+		"24:26 2 WF217 `timeout-minutes' in step `./b' of job `build' must be a whole number of minutes, got `soon'",
+		"25:13 2 WF219 Invalid `if' in action `./b': unknown name `github'; conditions can only refer to `event'",
+		"26:9 2 WF702 Step `nothing' of job `build' must have `uses' or `run'",
+		"28:12 2 WF702 Job `test' needs nonexistent job `lint'",
+		"29:12 2 WF702 Expected a list for `steps' in job `test', got string",
+	}, describeErrors(result.Errors))
+}
+
+func TestParseYAMLSyntaxError(t *testing.T) {
+	result, err := ParseYAML(strings.NewReader("on: push\njobs: [a\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3:1 3 WF700 unterminated flow collection"}, describeErrors(result.Errors))
+	assert.Equal(t, &model.Configuration{}, result.Configuration)
+}
+
+func TestReparseYAML(t *testing.T) {
+	result, err := ParseYAML(strings.NewReader(yamlSource))
+	require.NoError(t, err)
+	result, err = Reparse(result, replaceEdit(yamlSource, "./test", "./check"))
+	require.NoError(t, err)
+
+	expected, err := ParseYAML(strings.NewReader(strings.Replace(yamlSource, "./test", "./check", 1)))
+	require.NoError(t, err)
+	assert.Equal(t, expected.Configuration, result.Configuration)
+	assert.NotNil(t, result.Configuration.GetAction("./check"))
+}
+
+func TestParseFilesYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workflow-parser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "ci.yml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(yamlSource), 0644))
+
+	for _, options := range [][]OptionFunc{nil, {WithCache(NewLRUCache(10))}} {
+		results := ParseFiles([]string{filename}, options...)
+		require.Len(t, results, 1)
+		require.NoError(t, results[0].Err)
+		assert.Empty(t, results[0].Result.Errors)
+		assert.NotNil(t, results[0].Result.Configuration.GetWorkflow("CI"))
+	}
+}
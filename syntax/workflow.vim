@@ -18,9 +18,10 @@ endif
 
 syn case match
 
-syn keyword  gfTask        action
-syn keyword  gfAttribute   workflow on resolves
-syn keyword  gfAttribute   runs args needs secrets env uses
+syn keyword  gfTask        action template
+syn keyword  gfAttribute   version include locals
+syn keyword  gfAttribute   workflow on resolves env types branches
+syn keyword  gfAttribute   runs args needs secrets env uses extends matrix timeout retries if
 
 syn region      gfCommentL     start="//" end="$" keepend
 syn region      gfCommentL     start="#" end="$" keepend